@@ -0,0 +1,232 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/dmigwi/go-piparser/proposals"
+	"github.com/dmigwi/go-piparser/proposals/types"
+	"github.com/dmigwi/go-piparser/v1/export"
+	"github.com/dmigwi/go-piparser/v1/reconcile"
+)
+
+// syncStatus defines the sync command output.
+type syncStatus struct {
+	HeadSHA string `json:"head_sha"`
+	Layout  string `json:"layout"`
+}
+
+// runSync clones the repository or fetches its latest changes.
+func runSync(cfg *config, args []string, stdout io.Writer) error {
+	p, err := newParser(cfg)
+	if err != nil {
+		return err
+	}
+
+	status := syncStatus{HeadSHA: p.HeadSHA(), Layout: p.Layout()}
+	return writeRecords(stdout, cfg.output, []string{"head_sha", "layout"},
+		[][]string{{status.HeadSHA, status.Layout}}, status)
+}
+
+// runHistory writes the votes cast on the proposal token set.
+func runHistory(cfg *config, args []string, stdout io.Writer) error {
+	p, err := newParser(cfg)
+	if err != nil {
+		return err
+	}
+
+	w, err := newRowWriter(cfg.output, stdout)
+	if err != nil {
+		return err
+	}
+
+	return export.Proposal(p, args[0], cfg.since, w, cfg.filters()...)
+}
+
+// runTally writes the number of votes cast per vote option on the proposal
+// token set.
+func runTally(cfg *config, args []string, stdout io.Writer) error {
+	p, err := newParser(cfg)
+	if err != nil {
+		return err
+	}
+
+	history, err := p.ProposalHistorySince(args[0], cfg.since, cfg.filters()...)
+	if err != nil {
+		return err
+	}
+
+	tally, ok := types.TallyVotes(history)[args[0]]
+	if !ok {
+		tally = &types.Tally{Token: args[0], Options: map[string]int{}}
+	}
+
+	var rows [][]string
+	for _, option := range sortedKeys(tally.Options) {
+		rows = append(rows, []string{tally.Token, option,
+			fmt.Sprint(tally.Options[option])})
+	}
+
+	return writeRecords(stdout, cfg.output, []string{"token", "option", "votes"},
+		rows, tally)
+}
+
+// runTickets writes the votes cast by the ticket set on all the proposals.
+func runTickets(cfg *config, args []string, stdout io.Writer) error {
+	p, err := newParser(cfg)
+	if err != nil {
+		return err
+	}
+
+	w, err := newRowWriter(cfg.output, stdout)
+	if err != nil {
+		return err
+	}
+
+	filters := append(cfg.filters(), proposals.TicketFilter(args[0]))
+	return export.Proposals(p, cfg.since, w, filters...)
+}
+
+// runList writes the tokens of all the proposals found.
+func runList(cfg *config, args []string, stdout io.Writer) error {
+	p, err := newParser(cfg)
+	if err != nil {
+		return err
+	}
+
+	tokens, err := p.ProposalTokens()
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(tokens))
+	for _, token := range tokens {
+		rows = append(rows, []string{token})
+	}
+
+	return writeRecords(stdout, cfg.output, []string{"token"}, rows, tokens)
+}
+
+// runExport writes the votes of the proposal token set or of all proposals if
+// no token is set into the set file or the std output.
+func runExport(cfg *config, args []string, stdout io.Writer) (err error) {
+	format := cfg.output
+	switch format {
+	case "":
+		format = outputJSON
+	case outputJSON, outputCSV, outputParquet:
+	default:
+		return fmt.Errorf("unsupported export output %q", format)
+	}
+
+	p, err := newParser(cfg)
+	if err != nil {
+		return err
+	}
+
+	out := stdout
+	if cfg.file != "" {
+		f, err := os.Create(cfg.file)
+		if err != nil {
+			return fmt.Errorf("creating the export file failed: %v", err)
+		}
+
+		defer func() {
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+		out = f
+	}
+
+	w, err := export.NewWriter(export.Format(format), out)
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 0 {
+		return export.Proposal(p, args[0], cfg.since, w, cfg.filters()...)
+	}
+
+	return export.Proposals(p, cfg.since, w, cfg.filters()...)
+}
+
+// runReconcile compares the vote tallies of the proposals in the reference
+// tally source set to the parsed votes. An error is returned after the report
+// is written if any proposal mismatches.
+func runReconcile(cfg *config, args []string, stdout io.Writer) error {
+	refs, err := reconcile.Load(context.Background(), args[0])
+	if err != nil {
+		return err
+	}
+
+	p, err := newParser(cfg)
+	if err != nil {
+		return err
+	}
+
+	var history []*types.History
+	for token := range refs {
+		h, err := p.ProposalHistorySince(token, cfg.since, cfg.filters()...)
+		if err != nil {
+			return err
+		}
+		history = append(history, h...)
+	}
+
+	report := reconcile.Compare(history, refs)
+
+	var rows [][]string
+	for _, res := range report.Results {
+		status := "match"
+		if !res.Matches() {
+			status = "mismatch"
+		}
+
+		tickets := "-"
+		if res.TicketsCompared {
+			tickets = fmt.Sprintf("%d/%d/%d", len(res.MissingTickets),
+				len(res.ExtraTickets), len(res.ChangedTickets))
+		}
+
+		rows = append(rows, []string{res.Token, status, formatOptions(res),
+			fmt.Sprint(res.Parsed.Total), fmt.Sprint(res.Reference.Total), tickets})
+	}
+
+	err = writeRecords(stdout, cfg.output, []string{"token", "status", "options",
+		"parsed", "reference", "missing/extra/changed"}, rows, report)
+	if err != nil {
+		return err
+	}
+
+	if n := len(report.Mismatches()); n > 0 {
+		return fmt.Errorf("%d of %d proposals mismatch the reference tallies", n,
+			len(report.Results))
+	}
+	return nil
+}
+
+// formatOptions formats the parsed and the reference votes of each vote option
+// e.g. "No 2/3 Yes 4/4".
+func formatOptions(res *reconcile.Result) string {
+	options := make(map[string]int)
+	for option := range res.Parsed.Options {
+		options[option] = 0
+	}
+	for option := range res.Reference.Options {
+		options[option] = 0
+	}
+
+	var parts []string
+	for _, option := range sortedKeys(options) {
+		parts = append(parts, fmt.Sprintf("%s %d/%d", option,
+			res.Parsed.Options[option], res.Reference.Options[option]))
+	}
+	return strings.Join(parts, " ")
+}
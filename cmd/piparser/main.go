@@ -0,0 +1,233 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+// piparser is a command line tool that queries the Politeia votes data stored
+// in the proposals git repository.
+//
+// Usage:
+//
+//	piparser <command> [arguments] [flags]
+//
+// The commands are:
+//
+//	sync              clone the repository or fetch its latest changes
+//	history <token>   list the votes cast on a proposal
+//	tally <token>     count the votes cast per vote option on a proposal
+//	tickets <ticket>  list the votes cast by a ticket
+//	list              list the proposal tokens
+//	export [token]    write the votes of one or all proposals to a file
+//	reconcile <ref>   compare the vote tallies to a reference file or URL
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dmigwi/go-piparser/proposals"
+)
+
+const (
+	// outputJSON, outputCSV and outputTable are the supported query output
+	// formats. The export command also supports outputParquet.
+	outputJSON    = "json"
+	outputCSV     = "csv"
+	outputTable   = "table"
+	outputParquet = "parquet"
+
+	// defaultCloneDir is the directory created in the user cache directory
+	// that the repository is cloned into if no clone directory is set.
+	defaultCloneDir = "piparser"
+
+	// dateFormat is the short date format accepted by --since and --until.
+	dateFormat = "2006-01-02"
+)
+
+// errUsage is returned when the command line arguments are invalid.
+var errUsage = errors.New("invalid usage")
+
+// config holds the command line flags values.
+type config struct {
+	since    time.Time
+	until    time.Time
+	repoURL  string
+	cloneDir string
+	offline  bool
+	output   string
+	file     string
+}
+
+// command defines a subcommand. args holds the positional arguments names.
+type command struct {
+	args    []string
+	summary string
+	run     func(cfg *config, args []string, stdout io.Writer) error
+}
+
+// commands maps the subcommands names to their implementations.
+var commands = map[string]*command{
+	"sync":      {nil, "clone the repository or fetch its latest changes", runSync},
+	"history":   {[]string{"<token>"}, "list the votes cast on a proposal", runHistory},
+	"tally":     {[]string{"<token>"}, "count the votes cast per vote option on a proposal", runTally},
+	"tickets":   {[]string{"<ticket>"}, "list the votes cast by a ticket", runTickets},
+	"list":      {nil, "list the proposal tokens", runList},
+	"export":    {[]string{"[token]"}, "write the votes of one or all proposals to a file", runExport},
+	"reconcile": {[]string{"<ref>"}, "compare the vote tallies to a reference file or URL", runReconcile},
+}
+
+func main() {
+	err := run(os.Args[1:], os.Stdout, os.Stderr)
+	switch {
+	case err == errUsage:
+		os.Exit(2)
+
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "piparser: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run parses the command line arguments and runs the requested subcommand.
+func run(args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		usage(stderr)
+		return errUsage
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n", args[0])
+		usage(stderr)
+		return errUsage
+	}
+
+	cfg, positional, err := parseFlags(args[0], args[1:], stderr)
+	if err != nil {
+		return err
+	}
+
+	// Only the export token argument is optional.
+	required := len(cmd.args)
+	if args[0] == "export" {
+		required = 0
+	}
+
+	if len(positional) < required || len(positional) > len(cmd.args) {
+		fmt.Fprintf(stderr, "usage: piparser %s %s [flags]\n", args[0],
+			strings.Join(cmd.args, " "))
+		return errUsage
+	}
+
+	return cmd.run(cfg, positional, stdout)
+}
+
+// parseFlags parses the flags of the named subcommand. The flags may be set
+// before or after the positional arguments which are returned separately.
+func parseFlags(name string, args []string, stderr io.Writer) (*config, []string, error) {
+	cfg := new(config)
+	var since, until string
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&since, "since", "", "only include the votes flushed after this date (YYYY-MM-DD or RFC3339)")
+	fs.StringVar(&until, "until", "", "only include the votes flushed before this date (YYYY-MM-DD or RFC3339)")
+	fs.StringVar(&cfg.repoURL, "repo-url", "", "URL of the proposals repository to clone")
+	fs.StringVar(&cfg.cloneDir, "clone-dir", "", "directory the repository is cloned into")
+	fs.BoolVar(&cfg.offline, "offline", false, "only query the local clone without fetching any changes")
+	fs.StringVar(&cfg.output, "output", "", "output format: json, csv or table (export: json, csv or parquet)")
+	fs.StringVar(&cfg.file, "file", "", "file the export is written to instead of the std output")
+
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, nil, errUsage
+		}
+
+		if fs.NArg() == 0 {
+			break
+		}
+
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	var err error
+	if cfg.since, err = parseDate(since); err != nil {
+		return nil, nil, fmt.Errorf("invalid --since date: %v", err)
+	}
+
+	if cfg.until, err = parseDate(until); err != nil {
+		return nil, nil, fmt.Errorf("invalid --until date: %v", err)
+	}
+
+	return cfg, positional, nil
+}
+
+// parseDate parses the provided date in either the short date format or the
+// RFC3339 format. An empty string returns a zero time.
+func parseDate(str string) (time.Time, error) {
+	if str == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(dateFormat, str); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339, str)
+}
+
+// usage writes the command line usage into w.
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: piparser <command> [arguments] [flags]")
+	fmt.Fprintln(w, "\nThe commands are:")
+	for _, name := range []string{"sync", "history", "tally", "tickets", "list", "export", "reconcile"} {
+		cmd := commands[name]
+		fmt.Fprintf(w, "  %-18s %s\n", strings.TrimSpace(name+" "+strings.Join(cmd.args, " ")),
+			cmd.summary)
+	}
+	fmt.Fprintln(w, "\nRun 'piparser <command> -h' for the supported flags.")
+}
+
+// newParser returns the Parser configured with the command line flags. The
+// repository is cloned or updated unless the offline mode is set.
+func newParser(cfg *config) (*proposals.Parser, error) {
+	cloneDir := cfg.cloneDir
+	if cloneDir == "" {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("finding the user cache dir failed: %v", err)
+		}
+		cloneDir = filepath.Join(cacheDir, defaultCloneDir)
+	}
+
+	// NewParser only uses an existing clone directory.
+	if err := os.MkdirAll(cloneDir, 0755); err != nil {
+		return nil, fmt.Errorf("creating the clone dir failed: %v", err)
+	}
+
+	var opts []proposals.Option
+	if cfg.repoURL != "" {
+		opts = append(opts, proposals.WithRemoteURL(cfg.repoURL))
+	}
+
+	if cfg.offline {
+		opts = append(opts, proposals.WithOffline())
+	}
+
+	return proposals.NewParser("", "", cloneDir, opts...)
+}
+
+// filters returns the query filters set by the command line flags.
+func (cfg *config) filters() []proposals.Filter {
+	var filters []proposals.Filter
+	if !cfg.until.IsZero() {
+		filters = append(filters, proposals.UntilFilter(cfg.until))
+	}
+	return filters
+}
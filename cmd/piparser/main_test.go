@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/dmigwi/go-piparser/proposals/types"
+)
+
+// testToken is the proposal token whose votes are committed into the test
+// repository.
+const testToken = "27f87171d98b7923a1bd2bee6affed929fa2d2a6e178b5c80a9971a92a5c7f50"
+
+// newTestCloneDir creates a clone directory holding a repository with two
+// votes flush commits.
+func newTestCloneDir(t *testing.T) string {
+	cloneDir, err := ioutil.TempDir("", "piparser-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repoDir := filepath.Join(cloneDir, "prop-repo")
+	journal := filepath.Join(repoDir, testToken, "1", "plugins", "decred", "ballot.journal")
+	if err = os.MkdirAll(filepath.Dir(journal), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	git := func(env []string, args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repoDir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=Politeia",
+			"GIT_AUTHOR_EMAIL=noreply@decred.org", "GIT_COMMITTER_NAME=Politeia",
+			"GIT_COMMITTER_EMAIL=noreply@decred.org")
+		cmd.Env = append(cmd.Env, env...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v: %s", args, err, out)
+		}
+	}
+
+	git(nil, "init", "-q")
+	for i, date := range []string{"2018-11-05T17:58:13Z", "2018-11-06T17:58:13Z"} {
+		f, err := os.OpenFile(journal, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, bit := range []string{"1", "2"} {
+			ticket := strings.Repeat(strconv.Itoa(i)+bit, 32)
+			f.WriteString(`{"version":"1","action":"add"}{"castvote":{"token":"` +
+				testToken + `","ticket":"` + ticket + `","votebit":"` + bit +
+				`","signature":"1f23"},"receipt":"9f1c"}` + "\n")
+		}
+		f.Close()
+
+		git(nil, "add", "-A")
+		git([]string{"GIT_COMMITTER_DATE=" + date}, "commit", "-q", "--date", date,
+			"-m", types.DefaultVotesCommitMsg)
+	}
+
+	return cloneDir
+}
+
+// TestRun tests the subcommands output against a local clone.
+func TestRun(t *testing.T) {
+	cloneDir := newTestCloneDir(t)
+	defer os.RemoveAll(cloneDir)
+
+	ticket := strings.Repeat("12", 32)
+
+	td := []struct {
+		args     []string
+		contains []string
+		lines    int
+	}{
+		{[]string{"list"}, []string{"TOKEN", testToken}, 2},
+		{[]string{"history", testToken}, []string{"TICKET", ticket}, 5},
+		{[]string{"history", testToken, "--output", "csv", "--until", "2018-11-06"},
+			[]string{"token,version,ticket", strings.Repeat("01", 32)}, 3},
+		{[]string{"history", testToken, "--since", "2018-11-06T00:00:00Z", "--output", "json"},
+			[]string{`"ticket":"` + strings.Repeat("11", 32)}, 2},
+		{[]string{"tally", testToken}, []string{"No", "Yes"}, 3},
+		{[]string{"tickets", ticket, "--output", "json"}, []string{`"option":"Yes"`}, 1},
+		{[]string{"export", "--output", "csv"}, []string{"commit_sha"}, 5},
+	}
+
+	for i, val := range td {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			args := append(val.args, "--offline", "--clone-dir", cloneDir)
+
+			var stdout, stderr bytes.Buffer
+			if err := run(args, &stdout, &stderr); err != nil {
+				t.Fatalf("expected no error but found: %v: %s", err, stderr.String())
+			}
+
+			out := stdout.String()
+			for _, str := range val.contains {
+				if !strings.Contains(out, str) {
+					t.Fatalf("expected the output to contain %q but found: %s", str, out)
+				}
+			}
+
+			if lines := strings.Count(strings.TrimSpace(out), "\n") + 1; lines != val.lines {
+				t.Fatalf("expected %d output lines but found %d: %s", val.lines, lines, out)
+			}
+		})
+	}
+}
+
+// TestRunTallyJSON tests the tally json output.
+func TestRunTallyJSON(t *testing.T) {
+	cloneDir := newTestCloneDir(t)
+	defer os.RemoveAll(cloneDir)
+
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"tally", testToken, "--output", "json", "--offline",
+		"--clone-dir", cloneDir}, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	var tally types.Tally
+	if err = json.Unmarshal(stdout.Bytes(), &tally); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if tally.Total != 4 || tally.Options["Yes"] != 2 || tally.Options["No"] != 2 {
+		t.Fatalf("expected 2 Yes and 2 No votes but found %v", tally)
+	}
+}
+
+// TestRunReconcile tests the tallies comparison against a Politeia vote
+// results dump.
+func TestRunReconcile(t *testing.T) {
+	cloneDir := newTestCloneDir(t)
+	defer os.RemoveAll(cloneDir)
+
+	td := []struct {
+		tickets  []string
+		contains string
+		isError  bool
+	}{
+		{[]string{"01:1", "02:2", "11:1", "12:2"}, "match", false},
+		{[]string{"01:1", "02:1", "11:1"}, "mismatch", true},
+	}
+
+	for i, val := range td {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			var votes []string
+			for _, v := range val.tickets {
+				parts := strings.Split(v, ":")
+				votes = append(votes, `{"token":"`+testToken+`","ticket":"`+
+					strings.Repeat(parts[0], 32)+`","votebit":"`+parts[1]+`"}`)
+			}
+
+			reference := filepath.Join(cloneDir, "results-"+strconv.Itoa(i)+".json")
+			err := ioutil.WriteFile(reference, []byte(`{"castvotes":[`+
+				strings.Join(votes, ",")+`]}`), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var stdout, stderr bytes.Buffer
+			err = run([]string{"reconcile", reference, "--offline", "--clone-dir", cloneDir},
+				&stdout, &stderr)
+			if (err != nil) != val.isError {
+				t.Fatalf("expected an error to be %v but found: %v", val.isError, err)
+			}
+
+			if !strings.Contains(stdout.String(), testToken+"  "+val.contains) {
+				t.Fatalf("expected the proposal status %s but found: %s", val.contains,
+					stdout.String())
+			}
+		})
+	}
+}
+
+// TestRunUsage tests that invalid arguments return the usage error.
+func TestRunUsage(t *testing.T) {
+	td := [][]string{
+		nil,
+		{"unknown"},
+		{"history"},
+		{"tally", "a", "b"},
+		{"list", "--output"},
+		{"list", "--since", "yesterday"},
+	}
+
+	for i, args := range td {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if err := run(args, &stdout, &stderr); err == nil {
+				t.Fatalf("expected an error but found none")
+			}
+		})
+	}
+}
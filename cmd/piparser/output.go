@@ -0,0 +1,98 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dmigwi/go-piparser/v1/export"
+)
+
+// tableWriter writes the vote rows as aligned text columns.
+type tableWriter struct {
+	w          *tabwriter.Writer
+	headerDone bool
+}
+
+// newRowWriter returns the vote rows writer of the provided output format.
+func newRowWriter(output string, w io.Writer) (export.Writer, error) {
+	switch output {
+	case "", outputTable:
+		return &tableWriter{w: newTabWriter(w)}, nil
+	case outputJSON:
+		return export.NewJSONWriter(w), nil
+	case outputCSV:
+		return export.NewCSVWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unsupported output %q", output)
+	}
+}
+
+// Write writes the row as a single table line.
+func (t *tableWriter) Write(row *export.Row) error {
+	if !t.headerDone {
+		t.headerDone = true
+		fmt.Fprintln(t.w, "TOKEN\tVERSION\tTICKET\tOPTION\tCOMMIT\tTIMESTAMP")
+	}
+
+	_, err := fmt.Fprintf(t.w, "%s\t%s\t%s\t%s\t%s\t%s\n", row.Token, row.Version,
+		row.Ticket, row.Option, row.CommitSHA, row.Timestamp.UTC().Format(time.RFC3339))
+	return err
+}
+
+// Close flushes the table lines.
+func (t *tableWriter) Close() error {
+	return t.w.Flush()
+}
+
+// newTabWriter returns a tabwriter that aligns the table columns.
+func newTabWriter(w io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+}
+
+// writeRecords writes the provided records in the output format set. The json
+// output encodes v while the csv and table outputs write the header and rows.
+func writeRecords(w io.Writer, output string, header []string, rows [][]string,
+	v interface{}) error {
+	switch output {
+	case "", outputTable:
+		tw := newTabWriter(w)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(header, "\t")))
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+
+	case outputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+
+	case outputCSV:
+		cw := csv.NewWriter(w)
+		cw.Write(header)
+		cw.WriteAll(rows)
+		return cw.Error()
+
+	default:
+		return fmt.Errorf("unsupported output %q", output)
+	}
+}
+
+// sortedKeys returns the map keys in ascending order.
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
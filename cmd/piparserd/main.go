@@ -0,0 +1,382 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+// piparserd serves the Politeia votes data parsed from the proposals git
+// repository over an HTTP/JSON API. See the server package for the routes.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/dmigwi/go-piparser/proposals"
+	"github.com/dmigwi/go-piparser/proposals/types"
+	"github.com/dmigwi/go-piparser/v1/metrics"
+	"github.com/dmigwi/go-piparser/v1/notify"
+	"github.com/dmigwi/go-piparser/v1/rpcserver"
+	"github.com/dmigwi/go-piparser/v1/server"
+	"github.com/dmigwi/go-piparser/v1/webhook"
+	"google.golang.org/grpc"
+)
+
+const (
+	// defaultListenAddr is the address the API is served on if none is set.
+	defaultListenAddr = ":8080"
+
+	// defaultCloneDir is the directory created in the user cache directory
+	// that the repository is cloned into if no clone directory is set.
+	defaultCloneDir = "piparser"
+
+	// metricsPath is the path the Prometheus metrics are served on if
+	// enabled.
+	metricsPath = "/metrics"
+
+	// webhookPath is the path the push webhooks are received on if a webhook
+	// secret is set.
+	webhookPath = "/webhook"
+
+	// webhookSecretEnv is the environment variable that the webhook secret
+	// is read from if the flag is not set.
+	webhookSecretEnv = "PIPARSERD_WEBHOOK_SECRET"
+
+	// defaultPollInterval and defaultWebhookPollInterval are the intervals
+	// at which the updates are polled without and with the push webhooks.
+	defaultPollInterval        = 5 * time.Minute
+	defaultWebhookPollInterval = time.Hour
+
+	// readyCheckInterval is the interval at which the first sync is checked
+	// for completion.
+	readyCheckInterval = time.Second
+
+	// shutdownTimeout is how long the in-flight requests are given to
+	// complete once a shutdown signal is received.
+	shutdownTimeout = 10 * time.Second
+)
+
+// config holds the command line flags values.
+type config struct {
+	listen     string
+	grpcListen string
+	repoURL    string
+	cloneDir   string
+	offline    bool
+	metrics    bool
+	logLevel   string
+
+	webhookSecret string
+	webhookRepo   string
+	pollInterval  time.Duration
+	adaptive      bool
+
+	pinnedCommits  string
+	allowedSigners string
+	gpgHome        string
+	signingKeys    string
+
+	notifyStdout  bool
+	notifyWebhook string
+	notifyChat    string
+	notifyQuorum  int
+}
+
+func main() {
+	cfg := new(config)
+	flag.StringVar(&cfg.listen, "listen", defaultListenAddr, "address the API is served on")
+	flag.StringVar(&cfg.grpcListen, "grpc-listen", "", "address the gRPC service is served on, disabled if empty")
+	flag.StringVar(&cfg.repoURL, "repo-url", "", "URL of the proposals repository to clone")
+	flag.StringVar(&cfg.cloneDir, "clone-dir", "", "directory the repository is cloned into")
+	flag.BoolVar(&cfg.offline, "offline", false, "only serve the local clone without fetching any changes")
+	flag.BoolVar(&cfg.metrics, "metrics", false, "serve the Prometheus metrics on "+metricsPath)
+	flag.StringVar(&cfg.logLevel, "log-level", "info", "minimum level logged: debug, info, warn or error")
+	flag.StringVar(&cfg.webhookSecret, "webhook-secret", os.Getenv(webhookSecretEnv),
+		"secret of the push webhooks received on "+webhookPath+", disabled if empty (env "+webhookSecretEnv+")")
+	flag.StringVar(&cfg.webhookRepo, "webhook-repo", "",
+		"owner/name of the repository whose pushes trigger updates (default: the default repository if --repo-url is not set)")
+	flag.DurationVar(&cfg.pollInterval, "poll-interval", 0,
+		"interval at which the updates are polled (default 5m, or 1h if the webhook is enabled)")
+	flag.BoolVar(&cfg.adaptive, "adaptive-schedule", false,
+		"follow the votes flush cadence and back off while no vote is active")
+	flag.StringVar(&cfg.pinnedCommits, "pinned-commits", "",
+		"comma separated known-good commit SHAs that the repository history must hold")
+	flag.StringVar(&cfg.allowedSigners, "allowed-signers", "",
+		"SSH allowed signers file of the keys trusted to sign the new commits, enables the signature checks")
+	flag.StringVar(&cfg.gpgHome, "gpg-home", "",
+		"GnuPG home directory of the keys trusted to sign the new commits, enables the signature checks")
+	flag.StringVar(&cfg.signingKeys, "signing-keys", "",
+		"comma separated fingerprints of the only keys accepted to sign the new commits, enables the signature checks")
+	flag.BoolVar(&cfg.notifyStdout, "notify-stdout", false, "write the vote milestones to the std output")
+	flag.StringVar(&cfg.notifyWebhook, "notify-webhook", "", "URL that the vote milestones are posted to as JSON")
+	flag.StringVar(&cfg.notifyChat, "notify-chat", "", "Slack or Matrix compatible incoming webhook URL that the vote milestones are sent to")
+	flag.IntVar(&cfg.notifyQuorum, "notify-quorum", 0, "number of votes that the proposals quorum milestone is notified at, disabled if 0")
+	flag.Parse()
+
+	if err := run(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "piparserd: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run sets up the Parser and serves the API until an interrupt or terminate
+// signal is received.
+func run(cfg *config) error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.logLevel)); err != nil {
+		return fmt.Errorf("invalid log level %q found", cfg.logLevel)
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+
+	parser, err := newParser(cfg, logger)
+	if err != nil {
+		return err
+	}
+
+	api := server.New(parser, server.WithLogger(logger))
+
+	mux := http.NewServeMux()
+	mux.Handle("/", api)
+
+	if cfg.metrics {
+		mux.Handle(metricsPath, metrics.Handler(nil))
+	}
+
+	if cfg.webhookSecret != "" {
+		mux.Handle(webhookPath, webhook.Handler(cfg.webhookConfig(), parser))
+	}
+
+	srv := &http.Server{
+		Addr:              cfg.listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errChan := make(chan error, 2)
+	go func() {
+		logger.Info("serving the votes API", "addr", cfg.listen)
+		errChan <- srv.ListenAndServe()
+	}()
+
+	if cfg.grpcListen != "" {
+		lis, err := net.Listen("tcp", cfg.grpcListen)
+		if err != nil {
+			return fmt.Errorf("listening on %s failed: %v", cfg.grpcListen, err)
+		}
+
+		grpcSrv := grpc.NewServer()
+		rpcserver.Register(grpcSrv, parser)
+		defer stopGRPC(grpcSrv)
+
+		go func() {
+			logger.Info("serving the votes gRPC service", "addr", cfg.grpcListen)
+			errChan <- grpcSrv.Serve(lis)
+		}()
+	}
+
+	if sinks := cfg.notifySinks(); len(sinks) > 0 {
+		n := notify.New(notify.Config{
+			Rules:  []notify.Rule{{Quorum: cfg.notifyQuorum}},
+			Sinks:  sinks,
+			Logger: logger,
+		})
+
+		notifyCtx, stopNotify := context.WithCancel(context.Background())
+		defer stopNotify()
+
+		go func() {
+			// The votes already cast are seeded from the first sync.
+			if err := waitReady(notifyCtx, parser); err != nil {
+				return
+			}
+
+			if err := n.Run(notifyCtx, parser); err != nil && err != context.Canceled {
+				logger.Error("notifying the vote milestones failed", "error", err)
+			}
+		}()
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case err = <-errChan:
+		return err
+
+	case sig := <-sigChan:
+		logger.Info("shutting down", "signal", sig)
+	}
+
+	// Disconnect the push clients so that their requests do not hold up the
+	// shutdown.
+	api.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	return srv.Shutdown(ctx)
+}
+
+// newParser returns the Parser configured with the command line flags. Its
+// events are written to the provided logger. It returns before the repository
+// is synced.
+func newParser(cfg *config, logger *slog.Logger) (*proposals.Parser, error) {
+	cloneDir := cfg.cloneDir
+	if cloneDir == "" {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("finding the user cache dir failed: %v", err)
+		}
+		cloneDir = filepath.Join(cacheDir, defaultCloneDir)
+	}
+
+	// NewParser only uses an existing clone directory.
+	if err := os.MkdirAll(cloneDir, 0755); err != nil {
+		return nil, fmt.Errorf("creating the clone dir failed: %v", err)
+	}
+
+	// The first sync is made in the background so that the health probes are
+	// served while it runs, and report the failures rather than exiting.
+	opts := []proposals.Option{proposals.WithLogger(logger),
+		proposals.WithPollInterval(cfg.poll()), proposals.WithBackgroundSync()}
+	if cfg.repoURL != "" {
+		opts = append(opts, proposals.WithRemoteURL(cfg.repoURL))
+	}
+
+	if cfg.offline {
+		opts = append(opts, proposals.WithOffline())
+	}
+
+	if cfg.adaptive {
+		opts = append(opts, proposals.WithAdaptiveSchedule())
+	}
+
+	if cfg.pinnedCommits != "" {
+		opts = append(opts, proposals.WithPinnedCommits(splitList(cfg.pinnedCommits)...))
+	}
+
+	if cfg.allowedSigners != "" || cfg.gpgHome != "" || cfg.signingKeys != "" {
+		opts = append(opts, proposals.WithSignedCommits(proposals.SignaturePolicy{
+			GPGHome:            cfg.gpgHome,
+			AllowedSignersFile: cfg.allowedSigners,
+			Fingerprints:       splitList(cfg.signingKeys),
+		}))
+	}
+
+	if !cfg.metrics {
+		return proposals.NewParser("", "", cloneDir, opts...)
+	}
+
+	// The collector is set before the Parser is created so that the initial
+	// clone or update is measured too.
+	collector, err := metrics.New(nil)
+	if err != nil {
+		return nil, fmt.Errorf("registering the metrics failed: %v", err)
+	}
+
+	parser, err := proposals.NewParser("", "", cloneDir,
+		append(opts, proposals.WithMetrics(collector))...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = collector.WatchHead(parser.HeadCommitTime); err != nil {
+		return nil, fmt.Errorf("registering the HEAD age metric failed: %v", err)
+	}
+
+	return parser, nil
+}
+
+// waitReady blocks until the first sync of the Parser succeeds or the context
+// is cancelled.
+func waitReady(ctx context.Context, parser *proposals.Parser) error {
+	ticker := time.NewTicker(readyCheckInterval)
+	defer ticker.Stop()
+
+	for !parser.Status().Ready() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// splitList returns the non-empty items of the comma separated list.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// poll returns the interval at which the updates are polled. The polling is
+// only a fallback if the push webhooks are received thus it is less frequent.
+func (cfg *config) poll() time.Duration {
+	switch {
+	case cfg.pollInterval > 0:
+		return cfg.pollInterval
+	case cfg.webhookSecret != "":
+		return defaultWebhookPollInterval
+	default:
+		return defaultPollInterval
+	}
+}
+
+// webhookConfig returns the push webhooks validation config. The pushes to the
+// default repository are accepted if neither --webhook-repo nor --repo-url is
+// set, and to any repository if only --repo-url is set.
+func (cfg *config) webhookConfig() webhook.Config {
+	repo := cfg.webhookRepo
+	if repo == "" && cfg.repoURL == "" {
+		repo = types.DefaultRepoOwner + "/" + types.DefaultRepo
+	}
+
+	return webhook.Config{Secret: []byte(cfg.webhookSecret), Repository: repo}
+}
+
+// notifySinks returns the sinks that the vote milestones are sent to.
+func (cfg *config) notifySinks() []notify.Sink {
+	var sinks []notify.Sink
+	if cfg.notifyStdout {
+		sinks = append(sinks, notify.NewWriterSink(os.Stdout))
+	}
+
+	if cfg.notifyWebhook != "" {
+		sinks = append(sinks, &notify.WebhookSink{URL: cfg.notifyWebhook})
+	}
+
+	if cfg.notifyChat != "" {
+		sinks = append(sinks, &notify.ChatSink{URL: cfg.notifyChat})
+	}
+
+	return sinks
+}
+
+// stopGRPC stops the gRPC server gracefully. The open streams are closed if
+// they do not complete within the shutdown timeout.
+func stopGRPC(s *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(shutdownTimeout):
+		s.Stop()
+	}
+}
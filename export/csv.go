@@ -0,0 +1,54 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"time"
+)
+
+// csvWriter writes the rows as comma separated values.
+type csvWriter struct {
+	w          *csv.Writer
+	headerDone bool
+}
+
+// NewCSVWriter returns a writer that writes the rows as comma separated values
+// into w. The first line written is the header row.
+func NewCSVWriter(w io.Writer) Writer {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+// Write writes the row as a single CSV record. The header row is written
+// before the first record.
+func (c *csvWriter) Write(row *Row) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	return c.w.Write([]string{row.Token, row.Version, row.Ticket, row.Option,
+		row.CommitSHA, row.Timestamp.UTC().Format(time.RFC3339), row.Author})
+}
+
+// Close flushes the buffered rows. The header row is written if no rows were
+// written.
+func (c *csvWriter) Close() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// writeHeader writes the header row if it has not been written yet.
+func (c *csvWriter) writeHeader() error {
+	if c.headerDone {
+		return nil
+	}
+
+	c.headerDone = true
+	return c.w.Write(columns)
+}
@@ -0,0 +1,159 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+// Package export writes the votes history parsed by the proposals.Parser into
+// files. One row is written per vote cast. The history is streamed from the
+// parser to the writers so that the full history never needs to be held in
+// memory. At most walkBuffer commits are queued while a writer falls behind
+// the parser, which then waits for the writer.
+package export
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/dmigwi/go-piparser/proposals"
+	"github.com/dmigwi/go-piparser/proposals/types"
+)
+
+// Format defines the file formats that the votes data can be exported to.
+type Format string
+
+const (
+	// FormatJSON writes one JSON object per line (NDJSON).
+	FormatJSON Format = "json"
+
+	// FormatCSV writes comma separated values with a header row.
+	FormatCSV Format = "csv"
+
+	// FormatParquet writes an Apache Parquet file.
+	FormatParquet Format = "parquet"
+)
+
+// walkBuffer is the number of commits queued while a writer falls behind the
+// parser.
+const walkBuffer = 64
+
+// columns defines the names of the exported fields in the order they are
+// written.
+var columns = []string{"token", "version", "ticket", "option", "commit_sha",
+	"timestamp", "author"}
+
+// Row defines a single vote exported. Timestamp is the date of the commit that
+// flushed the vote.
+type Row struct {
+	Token     string    `json:"token"`
+	Version   string    `json:"version"`
+	Ticket    string    `json:"ticket"`
+	Option    string    `json:"option"`
+	CommitSHA string    `json:"commit_sha"`
+	Timestamp time.Time `json:"timestamp"`
+	Author    string    `json:"author"`
+}
+
+// Writer defines the methods implemented by the export formats writers. Close
+// flushes any buffered rows. It does not close the underlying io.Writer.
+type Writer interface {
+	Write(row *Row) error
+	Close() error
+}
+
+// Walker defines the proposals.Parser streaming queries used to read the votes
+// history exported.
+type Walker interface {
+	WalkProposalHistory(proposalToken string, since time.Time,
+		fn proposals.WalkFunc, filters ...proposals.Filter) error
+	WalkProposalsHistory(since time.Time, fn proposals.WalkFunc,
+		filters ...proposals.Filter) error
+}
+
+// NewWriter returns the writer of the provided format that writes into w.
+func NewWriter(format Format, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatJSON:
+		return NewJSONWriter(w), nil
+	case FormatCSV:
+		return NewCSVWriter(w), nil
+	case FormatParquet:
+		return NewParquetWriter(w)
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// Rows returns a row for each vote found in the provided history.
+func Rows(h *types.History) []*Row {
+	var rows []*Row
+	for _, f := range h.Patch {
+		for _, v := range f.VotesInfo {
+			if v.PiVote == nil {
+				continue
+			}
+
+			timestamp := v.FlushedAt
+			if timestamp.IsZero() {
+				timestamp = h.Date
+			}
+
+			rows = append(rows, &Row{
+				Token:     f.Token,
+				Version:   f.Version,
+				Ticket:    v.Ticket,
+				Option:    string(v.VoteBit),
+				CommitSHA: h.CommitSHA,
+				Timestamp: timestamp,
+				Author:    h.Author,
+			})
+		}
+	}
+	return rows
+}
+
+// Proposal writes the votes cast on the provided proposal token after the
+// since time, if set, into w. The writer is closed once all the votes are
+// written.
+func Proposal(p Walker, proposalToken string, since time.Time, w Writer,
+	filters ...proposals.Filter) error {
+	walk, wait := proposals.BufferedWalk(writeHistory(w), walkBuffer)
+	err := p.WalkProposalHistory(proposalToken, since, walk, filters...)
+	return closeWriter(w, waitWrites(wait, err))
+}
+
+// Proposals writes the votes cast on all the proposals after the since time,
+// if set, into w. The writer is closed once all the votes are written.
+func Proposals(p Walker, since time.Time, w Writer, filters ...proposals.Filter) error {
+	walk, wait := proposals.BufferedWalk(writeHistory(w), walkBuffer)
+	err := p.WalkProposalsHistory(since, walk, filters...)
+	return closeWriter(w, waitWrites(wait, err))
+}
+
+// waitWrites waits for the history queued to be written and returns the first
+// error found. The write errors also stop the walk thus they take precedence.
+func waitWrites(wait func() error, walkErr error) error {
+	if err := wait(); err != nil {
+		return err
+	}
+	return walkErr
+}
+
+// writeHistory returns the walk function that writes each history's votes into w.
+func writeHistory(w Writer) proposals.WalkFunc {
+	return func(h *types.History) error {
+		for _, row := range Rows(h) {
+			if err := w.Write(row); err != nil {
+				return fmt.Errorf("writing the vote row failed: %v", err)
+			}
+		}
+		return nil
+	}
+}
+
+// closeWriter closes w and returns the first error found.
+func closeWriter(w Writer, err error) error {
+	closeErr := w.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
@@ -0,0 +1,256 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dmigwi/go-piparser/proposals"
+	"github.com/dmigwi/go-piparser/proposals/types"
+	"github.com/dmigwi/go-piparser/v1/data"
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+// testWalker streams the history fixtures in place of a proposals.Parser.
+type testWalker []*types.History
+
+func (w testWalker) WalkProposalHistory(proposalToken string, since time.Time,
+	fn proposals.WalkFunc, filters ...proposals.Filter) error {
+	for _, h := range w {
+		for _, f := range h.Patch {
+			if f.Token != proposalToken {
+				continue
+			}
+
+			item := *h
+			item.Patch = []*types.File{f}
+			if err := fn(&item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (w testWalker) WalkProposalsHistory(since time.Time, fn proposals.WalkFunc,
+	filters ...proposals.Filter) error {
+	for _, h := range w {
+		if err := fn(h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// countVotes returns the number of votes in the history fixtures.
+func countVotes(history []*types.History) (count int) {
+	for _, h := range history {
+		count += len(Rows(h))
+	}
+	return
+}
+
+// TestExportFormats checks that each format writes a row per vote found.
+func TestExportFormats(t *testing.T) {
+	history := testWalker(data.AllTokensVotesData)
+	expected := countVotes(history)
+
+	td := []struct {
+		format Format
+		count  func(t *testing.T, b []byte) int
+	}{
+		{FormatJSON, func(t *testing.T, b []byte) int {
+			lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+			for _, line := range lines {
+				var row Row
+				if err := json.Unmarshal([]byte(line), &row); err != nil {
+					t.Fatalf("expected no error but found: %v", err)
+				}
+			}
+			return len(lines)
+		}},
+		{FormatCSV, func(t *testing.T, b []byte) int {
+			records, err := csv.NewReader(bytes.NewReader(b)).ReadAll()
+			if err != nil {
+				t.Fatalf("expected no error but found: %v", err)
+			}
+
+			if strings.Join(records[0], ",") != strings.Join(columns, ",") {
+				t.Fatalf("expected the header row %v but found %v", columns, records[0])
+			}
+			return len(records) - 1
+		}},
+		{FormatParquet, func(t *testing.T, b []byte) int {
+			pf, err := buffer.NewBufferFile(b)
+			if err != nil {
+				t.Fatalf("expected no error but found: %v", err)
+			}
+
+			pr, err := reader.NewParquetReader(pf, new(parquetRow), 1)
+			if err != nil {
+				t.Fatalf("expected no error but found: %v", err)
+			}
+			defer pr.ReadStop()
+
+			rows := make([]parquetRow, pr.GetNumRows())
+			if err = pr.Read(&rows); err != nil {
+				t.Fatalf("expected no error but found: %v", err)
+			}
+
+			if rows[0].Ticket == "" || rows[0].Timestamp == 0 {
+				t.Fatalf("expected the parquet row to be set but found: %v", rows[0])
+			}
+			return len(rows)
+		}},
+	}
+
+	for i, val := range td {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			var b bytes.Buffer
+			w, err := NewWriter(val.format, &b)
+			if err != nil {
+				t.Fatalf("expected no error but found: %v", err)
+			}
+
+			if err = Proposals(history, time.Time{}, w); err != nil {
+				t.Fatalf("expected no error but found: %v", err)
+			}
+
+			if count := val.count(t, b.Bytes()); count != expected {
+				t.Fatalf("expected %d %s rows but found %d", expected, val.format, count)
+			}
+		})
+	}
+}
+
+// TestExportProposal checks that only the votes of the set token are exported.
+func TestExportProposal(t *testing.T) {
+	token := "27f87171d98b7923a1bd2bee6affed929fa2d2a6e178b5c80a9971a92a5c7f50"
+
+	var b bytes.Buffer
+	err := Proposal(testWalker(data.AllTokensVotesData), token, time.Time{}, NewJSONWriter(&b))
+	if err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		var row Row
+		if err = json.Unmarshal([]byte(line), &row); err != nil {
+			t.Fatalf("expected no error but found: %v", err)
+		}
+
+		if row.Token != token {
+			t.Fatalf("expected only token %s votes but found %s", token, row.Token)
+		}
+	}
+}
+
+// TestExportWalkError checks that the walk errors are returned.
+func TestExportWalkError(t *testing.T) {
+	_, err := NewWriter(Format("xml"), nil)
+	if err == nil {
+		t.Fatalf("expected an unsupported format error but found none")
+	}
+
+	w := NewCSVWriter(failingWriter{})
+	err = Proposals(testWalker(data.AllTokensVotesData), time.Time{}, w)
+	if err == nil {
+		t.Fatalf("expected a write error but found none")
+	}
+}
+
+// failingWriter fails all the writes.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+// TestRowsTimestamp checks that the rows are timestamped with the vote flush
+// date and fall back to the commit date.
+func TestRowsTimestamp(t *testing.T) {
+	date := time.Date(2018, 11, 5, 17, 58, 13, 0, time.UTC)
+	flushedAt := date.Add(time.Hour)
+
+	h := &types.History{CommitSHA: "f00d", Date: date, Patch: []*types.File{{
+		Token: "27f87171",
+		VotesInfo: types.Votes{
+			{PiVote: &types.PiVote{Ticket: "aa", VoteBit: "Yes"}, FlushedAt: flushedAt},
+			{PiVote: &types.PiVote{Ticket: "bb", VoteBit: "No"}},
+		},
+	}}}
+
+	rows := Rows(h)
+	if len(rows) != 2 || !rows[0].Timestamp.Equal(flushedAt) || !rows[1].Timestamp.Equal(date) {
+		t.Fatalf("expected the rows timestamped at %v and %v but found %v", flushedAt,
+			date, rows)
+	}
+}
+
+// blockingWriter blocks all the row writes until it is released.
+type blockingWriter struct {
+	release chan struct{}
+	rows    int
+}
+
+func (w *blockingWriter) Write(row *Row) error {
+	<-w.release
+	w.rows++
+	return nil
+}
+
+func (w *blockingWriter) Close() error { return nil }
+
+// countingWalker counts the history items walked.
+type countingWalker struct {
+	testWalker
+	walked *int64
+}
+
+func (w countingWalker) WalkProposalsHistory(since time.Time, fn proposals.WalkFunc,
+	filters ...proposals.Filter) error {
+	return w.testWalker.WalkProposalsHistory(since, func(h *types.History) error {
+		atomic.AddInt64(w.walked, 1)
+		return fn(h)
+	}, filters...)
+}
+
+// TestExportSlowWriter checks that the walk waits for a blocked writer once
+// walkBuffer commits are queued, and that all the rows are written once it is
+// released.
+func TestExportSlowWriter(t *testing.T) {
+	var history testWalker
+	for len(history) < 4*walkBuffer {
+		history = append(history, data.AllTokensVotesData...)
+	}
+
+	walker := countingWalker{history, new(int64)}
+	w := &blockingWriter{release: make(chan struct{})}
+
+	exported := make(chan error)
+	go func() { exported <- Proposals(walker, time.Time{}, w) }()
+
+	// The writer holds the first commit with votes and the queue the next
+	// walkBuffer commits. The walk blocks on the next one.
+	time.Sleep(100 * time.Millisecond)
+	if walked := atomic.LoadInt64(walker.walked); walked > 2*walkBuffer {
+		t.Fatalf("expected the walk to wait for the writer but %d of %d commits were walked",
+			walked, len(history))
+	}
+
+	close(w.release)
+	if err := <-exported; err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if expected := countVotes(history); w.rows != expected {
+		t.Fatalf("expected %d rows but found %d", expected, w.rows)
+	}
+}
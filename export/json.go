@@ -0,0 +1,32 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+)
+
+// jsonWriter writes the rows as newline delimited JSON objects.
+type jsonWriter struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+// NewJSONWriter returns a writer that writes one JSON object per row into w.
+func NewJSONWriter(w io.Writer) Writer {
+	buf := bufio.NewWriter(w)
+	return &jsonWriter{buf: buf, enc: json.NewEncoder(buf)}
+}
+
+// Write writes the row as a single line JSON object.
+func (j *jsonWriter) Write(row *Row) error {
+	return j.enc.Encode(row)
+}
+
+// Close flushes the buffered rows.
+func (j *jsonWriter) Close() error {
+	return j.buf.Flush()
+}
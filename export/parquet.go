@@ -0,0 +1,71 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package export
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+const (
+	// parquetRowGroupSize defines the size in bytes of the row groups buffered
+	// before they are flushed into the parquet file. It caps the memory used
+	// while exporting.
+	parquetRowGroupSize = 16 * 1024 * 1024
+
+	// parquetParallelism defines the number of goroutines used to marshal the
+	// rows.
+	parquetParallelism = 1
+)
+
+// parquetRow defines the parquet schema of the rows exported.
+type parquetRow struct {
+	Token     string `parquet:"name=token, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Version   string `parquet:"name=version, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Ticket    string `parquet:"name=ticket, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Option    string `parquet:"name=option, type=BYTE_ARRAY, convertedtype=UTF8"`
+	CommitSHA string `parquet:"name=commit_sha, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Timestamp int64  `parquet:"name=timestamp, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	Author    string `parquet:"name=author, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// parquetWriter writes the rows into an Apache Parquet file.
+type parquetWriter struct {
+	pw *writer.ParquetWriter
+}
+
+// NewParquetWriter returns a writer that writes the rows into w as a snappy
+// compressed parquet file. The rows are flushed in row groups.
+func NewParquetWriter(w io.Writer) (Writer, error) {
+	pw, err := writer.NewParquetWriterFromWriter(w, new(parquetRow), parquetParallelism)
+	if err != nil {
+		return nil, fmt.Errorf("creating the parquet writer failed: %v", err)
+	}
+
+	pw.RowGroupSize = parquetRowGroupSize
+	pw.CompressionType = parquet.CompressionCodec_SNAPPY
+
+	return &parquetWriter{pw: pw}, nil
+}
+
+// Write appends the row to the current row group.
+func (p *parquetWriter) Write(row *Row) error {
+	return p.pw.Write(parquetRow{
+		Token:     row.Token,
+		Version:   row.Version,
+		Ticket:    row.Ticket,
+		Option:    row.Option,
+		CommitSHA: row.CommitSHA,
+		Timestamp: row.Timestamp.UnixNano() / 1e6,
+		Author:    row.Author,
+	})
+}
+
+// Close flushes the last row group and writes the parquet file footer.
+func (p *parquetWriter) Close() error {
+	return p.pw.WriteStop()
+}
@@ -0,0 +1,171 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+// Package metrics collects the Parser metrics in the Prometheus format and
+// exposes them via an optional http handler.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/dmigwi/go-piparser/proposals"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	// namespace prefixes all the metric names.
+	namespace = "piparser"
+
+	// success and failure are the result label values.
+	success = "success"
+	failure = "failure"
+)
+
+// Collector implements proposals.Metrics by recording the Parser activity in
+// Prometheus metrics. It is safe for concurrent use.
+type Collector struct {
+	lastSuccess    prometheus.Gauge
+	updateDuration *prometheus.HistogramVec
+	gitDuration    *prometheus.HistogramVec
+	gitFailures    *prometheus.CounterVec
+	commitsParsed  prometheus.Counter
+	votesParsed    prometheus.Counter
+	skippedLines   prometheus.Counter
+	queryDuration  *prometheus.HistogramVec
+	registerer     prometheus.Registerer
+}
+
+// Ensure that Collector implements proposals.Metrics.
+var _ proposals.Metrics = (*Collector)(nil)
+
+// New creates a Collector and registers its metrics with the provided
+// registerer. If registerer is nil, prometheus.DefaultRegisterer is used.
+func New(registerer prometheus.Registerer) (*Collector, error) {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	c := &Collector{
+		lastSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "last_successful_update_timestamp_seconds",
+			Help:      "Unix time of the last successful repository update.",
+		}),
+		updateDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "update_duration_seconds",
+			Help:      "Duration of the repository updates.",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 2, 12),
+		}, []string{"result"}),
+		gitDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "git_command_duration_seconds",
+			Help:      "Duration of the git commands by subcommand.",
+			Buckets:   prometheus.ExponentialBuckets(0.005, 2, 14),
+		}, []string{"subcommand"}),
+		gitFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "git_command_failures_total",
+			Help:      "Number of failed git commands by subcommand.",
+		}, []string{"subcommand"}),
+		commitsParsed: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "commits_parsed_total",
+			Help:      "Number of commits parsed.",
+		}),
+		votesParsed: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "votes_parsed_total",
+			Help:      "Number of votes parsed.",
+		}),
+		skippedLines: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "malformed_lines_skipped_total",
+			Help:      "Number of malformed journal lines skipped while parsing.",
+		}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "query_duration_seconds",
+			Help:      "Latency of the Parser queries by API method.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 16),
+		}, []string{"method", "result"}),
+		registerer: registerer,
+	}
+
+	collectors := []prometheus.Collector{c.lastSuccess, c.updateDuration,
+		c.gitDuration, c.gitFailures, c.commitsParsed, c.votesParsed,
+		c.skippedLines, c.queryDuration}
+
+	for _, collector := range collectors {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// WatchHead registers a gauge reporting the age in seconds of the commit that
+// the queries are currently pinned to. headTime is normally the Parser's
+// HeadCommitTime method. The gauge reports zero while no commit is pinned.
+func (c *Collector) WatchHead(headTime func() time.Time) error {
+	return c.registerer.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "head_commit_age_seconds",
+		Help:      "Age of the HEAD commit that the queries are pinned to.",
+	}, func() float64 {
+		t := headTime()
+		if t.IsZero() {
+			return 0
+		}
+		return time.Since(t).Seconds()
+	}))
+}
+
+// ObserveUpdate records the repository update duration and the time of the
+// last successful update.
+func (c *Collector) ObserveUpdate(duration time.Duration, err error) {
+	c.updateDuration.WithLabelValues(result(err)).Observe(duration.Seconds())
+	if err == nil {
+		c.lastSuccess.SetToCurrentTime()
+	}
+}
+
+// ObserveGitCommand records the git command duration and failure if any.
+func (c *Collector) ObserveGitCommand(subcommand string, duration time.Duration, err error) {
+	c.gitDuration.WithLabelValues(subcommand).Observe(duration.Seconds())
+	if err != nil {
+		c.gitFailures.WithLabelValues(subcommand).Inc()
+	}
+}
+
+// ObserveQuery records the Parser query latency.
+func (c *Collector) ObserveQuery(method string, duration time.Duration, err error) {
+	c.queryDuration.WithLabelValues(method, result(err)).Observe(duration.Seconds())
+}
+
+// ObserveParsed records a commit parsed with its votes and malformed lines.
+func (c *Collector) ObserveParsed(votes, skippedLines int) {
+	c.commitsParsed.Inc()
+	c.votesParsed.Add(float64(votes))
+	c.skippedLines.Add(float64(skippedLines))
+}
+
+// Handler returns the http handler serving the metrics collected by the
+// provided gatherer. If gatherer is nil, prometheus.DefaultGatherer is used.
+func Handler(gatherer prometheus.Gatherer) http.Handler {
+	if gatherer == nil {
+		gatherer = prometheus.DefaultGatherer
+	}
+	return promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})
+}
+
+// result returns the result label value matching the provided error.
+func result(err error) string {
+	if err != nil {
+		return failure
+	}
+	return success
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TestCollector tests that the observed Parser activity is exposed by the
+// metrics handler.
+func TestCollector(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	c, err := New(registry)
+	if err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	head := time.Now().Add(-time.Hour)
+	if err = c.WatchHead(func() time.Time { return head }); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	c.ObserveUpdate(time.Second, nil)
+	c.ObserveGitCommand("fetch", time.Second, errors.New("network down"))
+	c.ObserveGitCommand("log", time.Millisecond, nil)
+	c.ObserveQuery("ProposalHistory", time.Millisecond, nil)
+	c.ObserveParsed(3, 1)
+	c.ObserveParsed(2, 0)
+
+	rec := httptest.NewRecorder()
+	Handler(registry).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	body, _ := ioutil.ReadAll(rec.Body)
+
+	td := []string{
+		`piparser_update_duration_seconds_count{result="success"} 1`,
+		`piparser_git_command_failures_total{subcommand="fetch"} 1`,
+		`piparser_git_command_duration_seconds_count{subcommand="log"} 1`,
+		`piparser_query_duration_seconds_count{method="ProposalHistory",result="success"} 1`,
+		`piparser_commits_parsed_total 2`,
+		`piparser_votes_parsed_total 5`,
+		`piparser_malformed_lines_skipped_total 1`,
+		`piparser_last_successful_update_timestamp_seconds`,
+		`piparser_head_commit_age_seconds 3600`,
+	}
+
+	for i, want := range td {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			if !strings.Contains(string(body), want) {
+				t.Fatalf("expected the metric %q to be exposed but found none", want)
+			}
+		})
+	}
+
+	if _, err = New(registry); err == nil {
+		t.Fatalf("expected a duplicate registration error but found none")
+	}
+}
@@ -0,0 +1,587 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+// Package notify evaluates the votes parsed by every Parser update against the
+// per proposal rules and dispatches the vote milestones found, such as the
+// quorum being reached or the majority flipping, to pluggable sinks.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dmigwi/go-piparser/proposals"
+	"github.com/dmigwi/go-piparser/proposals/types"
+)
+
+// EventKind defines the vote milestones notified.
+type EventKind string
+
+const (
+	// VoteStarted is notified when the first votes of a proposal are parsed.
+	VoteStarted EventKind = "vote_started"
+
+	// QuorumReached is notified when the votes cast on a proposal reach the
+	// rule quorum.
+	QuorumReached EventKind = "quorum_reached"
+
+	// MajorityFlipped is notified when the leading vote option of a proposal
+	// changes.
+	MajorityFlipped EventKind = "majority_flipped"
+
+	// VoteFinished is notified once the rule end date has passed.
+	VoteFinished EventKind = "vote_finished"
+)
+
+const (
+	// defaultMaxRetries is the default number of times a failed delivery is
+	// retried.
+	defaultMaxRetries = 3
+
+	// defaultRetryBackoff is the default delay before the first delivery
+	// retry. It doubles on every consecutive retry.
+	defaultRetryBackoff = time.Second
+
+	// updatesBuffer is the number of parser updates buffered while the
+	// previous update is being dispatched.
+	updatesBuffer = 16
+
+	// finishCheckInterval is the interval at which the rules end dates are
+	// checked.
+	finishCheckInterval = time.Minute
+)
+
+// Rule defines the milestones notified for a proposal.
+type Rule struct {
+	// Token is the proposal token that the rule applies to. The rule with an
+	// empty token applies to all the proposals with no rule of their own.
+	Token string
+
+	// Quorum is the number of votes that the proposal needs to reach the
+	// quorum. QuorumReached is not notified if it is zero.
+	Quorum int
+
+	// EndsAt is the date that the proposal vote ends. VoteFinished is not
+	// notified if it is zero or the token is empty.
+	EndsAt time.Time
+
+	// Events lists the milestones notified. All of them are notified if it is
+	// empty.
+	Events []EventKind
+}
+
+// wants returns true if the rule notifies the provided milestone.
+func (r *Rule) wants(kind EventKind) bool {
+	if len(r.Events) == 0 {
+		return true
+	}
+
+	for _, k := range r.Events {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Event describes a vote milestone reached by a proposal.
+type Event struct {
+	Kind    EventKind `json:"kind"`
+	Token   string    `json:"token"`
+	HeadSHA string    `json:"head_sha,omitempty"`
+
+	// Time is the date of the flush commit that the milestone was reached in
+	// or the vote end date for VoteFinished.
+	Time time.Time `json:"time"`
+
+	// Majority is the leading vote option and PreviousMajority the one it
+	// replaced if the majority flipped.
+	Majority         string `json:"majority,omitempty"`
+	PreviousMajority string `json:"previous_majority,omitempty"`
+
+	// Quorum is the rule quorum.
+	Quorum int `json:"quorum,omitempty"`
+
+	// Tally holds the votes counted when the milestone was reached.
+	Tally *types.Tally `json:"tally"`
+}
+
+// String returns the human readable event message.
+func (e *Event) String() string {
+	var msg string
+	switch e.Kind {
+	case VoteStarted:
+		msg = "Voting started on proposal " + e.Token
+
+	case QuorumReached:
+		msg = fmt.Sprintf("Proposal %s reached the quorum of %d votes", e.Token, e.Quorum)
+
+	case MajorityFlipped:
+		msg = fmt.Sprintf("Proposal %s majority flipped from %s to %s", e.Token,
+			e.PreviousMajority, e.Majority)
+
+	case VoteFinished:
+		msg = "Voting finished on proposal " + e.Token
+
+	default:
+		msg = fmt.Sprintf("Proposal %s %s", e.Token, e.Kind)
+	}
+
+	return msg + ": " + formatTally(e.Tally)
+}
+
+// formatTally formats the votes counted per option e.g. "No 3, Yes 10 (13 votes)".
+func formatTally(t *types.Tally) string {
+	if t == nil {
+		return "0 votes"
+	}
+
+	options := make([]string, 0, len(t.Options))
+	for option := range t.Options {
+		options = append(options, option)
+	}
+	sort.Strings(options)
+
+	for i, option := range options {
+		options[i] = fmt.Sprintf("%s %d", option, t.Options[option])
+	}
+
+	return fmt.Sprintf("%s (%d votes)", strings.Join(options, ", "), t.Total)
+}
+
+// Source defines the proposals.Parser methods used to follow the new votes.
+type Source interface {
+	HeadSHA() string
+	Subscribe(buffer int) (<-chan *proposals.Update, func())
+	ProposalsHistory(filters ...proposals.Filter) ([]*types.History, error)
+}
+
+// Config defines the rules evaluated and the sinks that the events are
+// dispatched to.
+type Config struct {
+	Rules []Rule
+	Sinks []Sink
+
+	// MaxRetries and RetryBackoff define how many times a failed delivery is
+	// retried and the delay before the first retry. They default to 3 and 1s.
+	MaxRetries   int
+	RetryBackoff time.Duration
+
+	// Logger receives the delivery failures. They are dropped if it is nil.
+	Logger proposals.Logger
+}
+
+// countedVote is the vote of a ticket counted and the commit it was parsed
+// from.
+type countedVote struct {
+	commitSHA string
+	voteBit   string
+}
+
+// proposalState holds the votes counted on a proposal and the milestones it
+// has reached.
+type proposalState struct {
+	tally    *types.Tally
+	tickets  map[string]countedVote
+	majority string
+	started  bool
+	quorum   bool
+	finished bool
+}
+
+// Notifier evaluates the new votes against the rules and dispatches the events
+// found. It is safe for concurrent use.
+type Notifier struct {
+	cfg Config
+
+	// now returns the current time. It is replaced in tests.
+	now func() time.Time
+
+	mtx    sync.Mutex
+	states map[string]*proposalState
+}
+
+// New returns a Notifier configured with the provided rules and sinks.
+func New(cfg Config) *Notifier {
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = defaultMaxRetries
+	}
+
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = defaultRetryBackoff
+	}
+
+	return &Notifier{
+		cfg:    cfg,
+		now:    time.Now,
+		states: make(map[string]*proposalState),
+	}
+}
+
+// Seed counts the votes already cast without notifying any milestone. It keeps
+// the milestones reached before the Notifier started from being notified again.
+func (n *Notifier) Seed(history []*types.History) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	n.evaluate("", history, false)
+
+	// The votes that already ended are not notified.
+	n.finished(n.now())
+}
+
+// Evaluate counts the new votes parsed by an update and returns the milestones
+// they reach. The events are not dispatched.
+func (n *Notifier) Evaluate(headSHA string, history []*types.History) []*Event {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	return append(n.evaluate(headSHA, history, true), n.finished(n.now())...)
+}
+
+// Finished returns the VoteFinished events of the rules whose end date passed
+// since the last check.
+func (n *Notifier) Finished() []*Event {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	return n.finished(n.now())
+}
+
+// Rollback retracts the votes counted from the commits dropped from the
+// history. All the votes are retracted if the dropped commits are unknown. The
+// milestones already notified are kept thus they are not notified again, and
+// the majority flips are detected against the last majority notified.
+func (n *Notifier) Rollback(r *proposals.Rollback) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	if r.Unknown {
+		for _, s := range n.states {
+			s.tally = &types.Tally{Token: s.tally.Token, Options: make(map[string]int)}
+			s.tickets = make(map[string]countedVote)
+		}
+		return
+	}
+
+	for _, h := range r.History {
+		for _, f := range h.Patch {
+			s, ok := n.states[f.Token]
+			if !ok {
+				continue
+			}
+
+			for _, v := range f.VotesInfo {
+				// The repeated votes were not counted.
+				vote, ok := s.tickets[v.Ticket]
+				if v.PiVote == nil || !ok || vote.commitSHA != h.CommitSHA {
+					continue
+				}
+
+				delete(s.tickets, v.Ticket)
+				s.tally.Options[vote.voteBit]--
+				if s.tally.Options[vote.voteBit] == 0 {
+					delete(s.tally.Options, vote.voteBit)
+				}
+				s.tally.Total--
+			}
+		}
+	}
+}
+
+// evaluate counts the votes in the history per proposal. If notify is false no
+// events are returned. The lock must be held by the caller.
+func (n *Notifier) evaluate(headSHA string, history []*types.History, notify bool) []*Event {
+	var events []*Event
+	changed := make(map[string]time.Time)
+
+	for _, h := range history {
+		for _, f := range h.Patch {
+			s, ok := n.states[f.Token]
+			if !ok {
+				s = &proposalState{
+					tally:   &types.Tally{Token: f.Token, Options: make(map[string]int)},
+					tickets: make(map[string]countedVote),
+				}
+				n.states[f.Token] = s
+			}
+
+			counted := false
+			for _, v := range f.VotesInfo {
+				// Only the first vote cast by each ticket is counted.
+				if _, ok := s.tickets[v.Ticket]; v.PiVote == nil || ok {
+					continue
+				}
+
+				s.tickets[v.Ticket] = countedVote{h.CommitSHA, string(v.VoteBit)}
+				s.tally.Options[string(v.VoteBit)]++
+				s.tally.Total++
+				counted = true
+			}
+
+			if t, ok := changed[f.Token]; counted && (!ok || h.Date.After(t)) {
+				changed[f.Token] = h.Date
+			}
+		}
+	}
+
+	tokens := make([]string, 0, len(changed))
+	for token := range changed {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+
+	for _, token := range tokens {
+		s := n.states[token]
+		rule := n.rule(token)
+
+		newEvent := func(kind EventKind) *Event {
+			return &Event{Kind: kind, Token: token, HeadSHA: headSHA,
+				Time: changed[token], Majority: s.majority, Tally: copyTally(s.tally)}
+		}
+
+		previous := s.majority
+		s.majority = majority(s.tally, previous)
+
+		if !s.started {
+			s.started = true
+			if notify && rule != nil && rule.wants(VoteStarted) {
+				events = append(events, newEvent(VoteStarted))
+			}
+		}
+
+		if notify && rule != nil && previous != "" && previous != s.majority &&
+			rule.wants(MajorityFlipped) {
+			e := newEvent(MajorityFlipped)
+			e.PreviousMajority = previous
+			events = append(events, e)
+		}
+
+		if rule != nil && rule.Quorum > 0 && !s.quorum && s.tally.Total >= rule.Quorum {
+			s.quorum = true
+			if notify && rule.wants(QuorumReached) {
+				e := newEvent(QuorumReached)
+				e.Quorum = rule.Quorum
+				events = append(events, e)
+			}
+		}
+	}
+
+	return events
+}
+
+// finished returns the VoteFinished events of the rules whose end date has
+// passed. The lock must be held by the caller.
+func (n *Notifier) finished(now time.Time) []*Event {
+	var events []*Event
+	for i := range n.cfg.Rules {
+		rule := &n.cfg.Rules[i]
+		if rule.Token == "" || rule.EndsAt.IsZero() || now.Before(rule.EndsAt) {
+			continue
+		}
+
+		s, ok := n.states[rule.Token]
+		if !ok {
+			s = &proposalState{
+				tally:   &types.Tally{Token: rule.Token, Options: make(map[string]int)},
+				tickets: make(map[string]countedVote),
+			}
+			n.states[rule.Token] = s
+		}
+
+		if s.finished {
+			continue
+		}
+
+		s.finished = true
+		if rule.wants(VoteFinished) {
+			events = append(events, &Event{Kind: VoteFinished, Token: rule.Token,
+				Time: rule.EndsAt, Majority: s.majority, Tally: copyTally(s.tally)})
+		}
+	}
+	return events
+}
+
+// rule returns the rule of the provided token or the rule that applies to all
+// the proposals if it has none. A nil rule is returned if none applies.
+func (n *Notifier) rule(token string) *Rule {
+	var wildcard *Rule
+	for i := range n.cfg.Rules {
+		switch n.cfg.Rules[i].Token {
+		case token:
+			return &n.cfg.Rules[i]
+		case "":
+			wildcard = &n.cfg.Rules[i]
+		}
+	}
+	return wildcard
+}
+
+// majority returns the vote option with the most votes. On a tie, the previous
+// majority is kept if it is among the tied options otherwise the first tied
+// option in the alphabetical order is returned.
+func majority(t *types.Tally, previous string) string {
+	maxVotes := 0
+	for _, votes := range t.Options {
+		if votes > maxVotes {
+			maxVotes = votes
+		}
+	}
+
+	if maxVotes > 0 && t.Options[previous] == maxVotes {
+		return previous
+	}
+
+	leader := ""
+	for option, votes := range t.Options {
+		if votes == maxVotes && (leader == "" || option < leader) {
+			leader = option
+		}
+	}
+	return leader
+}
+
+// copyTally returns a copy of the tally that is safe to share.
+func copyTally(t *types.Tally) *types.Tally {
+	c := &types.Tally{Token: t.Token, Total: t.Total, Options: make(map[string]int, len(t.Options))}
+	for option, votes := range t.Options {
+		c.Options[option] = votes
+	}
+	return c
+}
+
+// Dispatch sends the events to all the sinks. The failed deliveries are retried
+// with an exponential backoff. The errors of the deliveries that still fail
+// are logged and returned joined.
+func (n *Notifier) Dispatch(ctx context.Context, events []*Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(n.cfg.Sinks))
+
+	for i, sink := range n.cfg.Sinks {
+		wg.Add(1)
+		go func(i int, sink Sink) {
+			defer wg.Done()
+
+			for _, e := range events {
+				if err := n.deliver(ctx, sink, e); err != nil {
+					errs[i] = errors.Join(errs[i], err)
+				}
+			}
+		}(i, sink)
+	}
+
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// deliver sends the event to the sink, retrying on failure.
+func (n *Notifier) deliver(ctx context.Context, sink Sink, e *Event) error {
+	backoff := n.cfg.RetryBackoff
+	for i := 0; ; i++ {
+		err := sink.Send(ctx, e)
+		if err == nil {
+			return nil
+		}
+
+		if i >= n.cfg.MaxRetries {
+			n.log().Error("delivering the event failed", "sink", sink.Name(),
+				"kind", e.Kind, "token", e.Token, "error", err)
+			return fmt.Errorf("%s: delivering the %s event of %s failed: %v",
+				sink.Name(), e.Kind, e.Token, err)
+		}
+
+		n.log().Warn("retrying the event delivery", "sink", sink.Name(),
+			"kind", e.Kind, "token", e.Token, "attempt", i+1, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// Run seeds the Notifier with the votes already cast and then evaluates and
+// dispatches the votes parsed by every update made by the source until the
+// context is cancelled. An update that does not follow the HEAD last
+// processed, i.e. the subscription dropped the updates in between, is
+// replaced by the complete history whose new milestones are notified.
+func (n *Notifier) Run(ctx context.Context, src Source) error {
+	// Subscribe before seeding so that no update is missed. The votes parsed
+	// twice are only counted once.
+	updates, cancel := src.Subscribe(updatesBuffer)
+	defer cancel()
+
+	// headSHA is the HEAD last processed. It is read before the history
+	// thus it never gets ahead of the votes counted.
+	headSHA := src.HeadSHA()
+	history, err := src.ProposalsHistory()
+	if err != nil {
+		return fmt.Errorf("seeding the votes cast failed: %v", err)
+	}
+	n.Seed(history)
+
+	ticker := time.NewTicker(finishCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		var events []*Event
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case u, ok := <-updates:
+			if !ok {
+				return nil
+			}
+
+			if u.PreviousSHA != headSHA {
+				n.log().Warn("updates missed, reseeding the votes cast",
+					"previous_sha", u.PreviousSHA, "last_sha", headSHA)
+
+				headSHA = src.HeadSHA()
+				history, err := src.ProposalsHistory()
+				if err != nil {
+					return fmt.Errorf("reseeding the votes cast failed: %v", err)
+				}
+
+				// The milestones already notified are not notified again.
+				n.Rollback(&proposals.Rollback{Unknown: true})
+				events = n.Evaluate(headSHA, history)
+				break
+			}
+
+			if u.Rollback != nil {
+				n.Rollback(u.Rollback)
+			}
+			events = n.Evaluate(u.HeadSHA, u.History)
+			headSHA = u.HeadSHA
+
+		case <-ticker.C:
+			events = n.Finished()
+		}
+
+		// The delivery failures are logged.
+		n.Dispatch(ctx, events)
+	}
+}
+
+// log returns the set logger or one that drops all the events.
+func (n *Notifier) log() proposals.Logger {
+	if n.cfg.Logger == nil {
+		return proposals.DiscardLogger{}
+	}
+	return n.cfg.Logger
+}
@@ -0,0 +1,335 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmigwi/go-piparser/proposals"
+	"github.com/dmigwi/go-piparser/proposals/types"
+)
+
+const testToken = "27f87171d98b7923a1bd2bee6affed929fa2d2a6e178b5c80a9971a92a5c7f50"
+
+// testDate is the date of the first test flush commit.
+var testDate = time.Date(2019, 3, 24, 10, 58, 0, 0, time.UTC)
+
+// testFlush returns a flush commit of the votes cast on the test token made
+// the provided number of hours after testDate. Each vote is a ticket prefix
+// and votebit pair e.g. "a:Yes".
+func testFlush(hours int, votes ...string) []*types.History {
+	f := &types.File{Token: testToken}
+	for _, v := range votes {
+		parts := strings.Split(v, ":")
+		f.VotesInfo = append(f.VotesInfo, types.CastVoteData{PiVote: &types.PiVote{
+			Ticket:  strings.Repeat(parts[0], 64),
+			VoteBit: types.ToBitcast(parts[1]),
+		}})
+	}
+
+	return []*types.History{{
+		CommitSHA: strconv.Itoa(hours),
+		Date:      testDate.Add(time.Duration(hours) * time.Hour),
+		Patch:     []*types.File{f},
+	}}
+}
+
+// kinds returns the kinds of the provided events.
+func kinds(events []*Event) []EventKind {
+	var k []EventKind
+	for _, e := range events {
+		k = append(k, e.Kind)
+	}
+	return k
+}
+
+// TestEvaluate tests the milestones found as the votes are parsed.
+func TestEvaluate(t *testing.T) {
+	endsAt := testDate.Add(24 * time.Hour)
+	n := New(Config{Rules: []Rule{{Token: testToken, Quorum: 3, EndsAt: endsAt}}})
+
+	now := testDate
+	n.now = func() time.Time { return now }
+
+	td := []struct {
+		history  []*types.History
+		after    time.Duration
+		expected []EventKind
+		majority string
+	}{
+		{testFlush(0, "a:Yes"), 0, []EventKind{VoteStarted}, "Yes"},
+		{testFlush(1, "b:No", "c:No"), 0, []EventKind{MajorityFlipped, QuorumReached}, "No"},
+		// The repeated votes are not counted.
+		{testFlush(2, "a:No"), 0, nil, ""},
+		// A tie keeps the previous majority.
+		{testFlush(3, "d:Yes"), 0, nil, ""},
+		{nil, 25 * time.Hour, []EventKind{VoteFinished}, "No"},
+		{nil, 26 * time.Hour, nil, ""},
+	}
+
+	for i, val := range td {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			now = testDate.Add(val.after)
+			events := n.Evaluate("sha", val.history)
+
+			if k := kinds(events); strings.Join(toStrings(k), ",") != strings.Join(toStrings(val.expected), ",") {
+				t.Fatalf("expected the events %v but found %v", val.expected, k)
+			}
+
+			if len(events) > 0 && events[len(events)-1].Majority != val.majority {
+				t.Fatalf("expected the majority %q but found %q", val.majority,
+					events[len(events)-1].Majority)
+			}
+		})
+	}
+}
+
+// toStrings converts the event kinds to strings.
+func toStrings(k []EventKind) []string {
+	s := make([]string, len(k))
+	for i, kind := range k {
+		s[i] = string(kind)
+	}
+	return s
+}
+
+// TestSeed tests that the milestones reached before the Notifier started are
+// not notified again.
+func TestSeed(t *testing.T) {
+	n := New(Config{Rules: []Rule{{Quorum: 3, Events: []EventKind{VoteStarted, QuorumReached}},
+		{Token: "other", EndsAt: testDate}}})
+	n.now = func() time.Time { return testDate.Add(time.Hour) }
+
+	n.Seed(append(testFlush(0, "a:Yes"), testFlush(1, "b:No", "c:No")...))
+
+	if events := n.Evaluate("sha", testFlush(2, "d:Yes", "e:Yes")); len(events) != 0 {
+		t.Fatalf("expected no events but found %v", kinds(events))
+	}
+
+	// The wildcard rule does not notify the majority flips.
+	n = New(Config{Rules: []Rule{{Events: []EventKind{VoteStarted}}}})
+	events := n.Evaluate("sha", append(testFlush(0, "a:Yes"), testFlush(1, "b:No", "c:No")...))
+	if len(events) != 1 || events[0].Kind != VoteStarted || events[0].Tally.Total != 3 {
+		t.Fatalf("expected a single vote started event but found %v", kinds(events))
+	}
+}
+
+// TestRollback tests that the votes of the dropped commits are retracted.
+func TestRollback(t *testing.T) {
+	n := New(Config{Rules: []Rule{{Token: testToken, Quorum: 3}}})
+	n.Evaluate("sha", append(testFlush(0, "a:Yes"), testFlush(1, "b:No", "c:No")...))
+
+	// The repeated vote of ticket a is kept since it was not counted.
+	n.Rollback(&proposals.Rollback{History: append(testFlush(1, "b:No", "c:No"),
+		testFlush(2, "a:No")...)})
+
+	events := n.Evaluate("sha", testFlush(3, "b:Yes"))
+	if len(events) != 1 || events[0].Kind != MajorityFlipped ||
+		events[0].Majority != "Yes" || events[0].Tally.Total != 2 {
+		t.Fatalf("expected the majority flipped to Yes with 2 votes but found %v", kinds(events))
+	}
+
+	// The milestones notified before are not notified again.
+	n.Rollback(&proposals.Rollback{Unknown: true})
+	events = n.Evaluate("sha", testFlush(4, "a:Yes", "b:Yes", "c:Yes"))
+	if len(events) != 0 {
+		t.Fatalf("expected no events but found %v", kinds(events))
+	}
+
+	if tally := n.states[testToken].tally; tally.Total != 3 || tally.Options["No"] != 0 {
+		t.Fatalf("expected 3 Yes votes counted but found %+v", tally)
+	}
+}
+
+// TestDispatch tests that the failed deliveries are retried.
+func TestDispatch(t *testing.T) {
+	events := []*Event{{Kind: VoteStarted, Token: testToken}}
+
+	td := []struct {
+		failFirst  int
+		isError    bool
+		deliveries int
+	}{
+		{0, false, 1},
+		{2, false, 1},
+		{5, true, 0},
+	}
+
+	for i, val := range td {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			sink := &TestSink{FailFirst: val.failFirst}
+			n := New(Config{Sinks: []Sink{sink}, MaxRetries: 2, RetryBackoff: time.Millisecond})
+
+			err := n.Dispatch(context.Background(), events)
+			if (err != nil) != val.isError {
+				t.Fatalf("expected an error to be %v but found: %v", val.isError, err)
+			}
+
+			if len(sink.Events()) != val.deliveries {
+				t.Fatalf("expected %d deliveries but found %d", val.deliveries,
+					len(sink.Events()))
+			}
+		})
+	}
+}
+
+// TestSinks tests the events delivered by the webhook, chat, SMTP and writer
+// sinks.
+func TestSinks(t *testing.T) {
+	e := &Event{Kind: QuorumReached, Token: testToken, Quorum: 3, Time: testDate,
+		Tally: &types.Tally{Token: testToken, Options: map[string]int{"Yes": 1, "No": 2}, Total: 3}}
+	message := "Proposal " + testToken + " reached the quorum of 3 votes: No 2, Yes 1 (3 votes)"
+
+	bodies := make(chan []byte, 2)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		buf.ReadFrom(r.Body)
+		bodies <- buf.Bytes()
+	}))
+	defer ts.Close()
+
+	if err := (&WebhookSink{URL: ts.URL}).Send(context.Background(), e); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	var received Event
+	if err := json.Unmarshal(<-bodies, &received); err != nil || received.Tally.Total != 3 {
+		t.Fatalf("expected the event posted but found %+v: %v", received, err)
+	}
+
+	if err := (&ChatSink{URL: ts.URL}).Send(context.Background(), e); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	var msg chatMessage
+	if err := json.Unmarshal(<-bodies, &msg); err != nil || msg.Text != message {
+		t.Fatalf("expected the message %q but found %q: %v", message, msg.Text, err)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+
+	if err := (&WebhookSink{URL: failing.URL}).Send(context.Background(), e); err == nil {
+		t.Fatalf("expected a response status error but found none")
+	}
+
+	var mail string
+	sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		mail = string(msg)
+		return nil
+	}
+	defer func() { sendMail = smtp.SendMail }()
+
+	sink := &SMTPSink{Addr: "localhost:25", From: "piparser@example.com", To: []string{"ops@example.com"}}
+	if err := sink.Send(context.Background(), e); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if !strings.Contains(mail, "Subject: [piparser] quorum_reached "+testToken) ||
+		!strings.Contains(mail, message) {
+		t.Fatalf("expected the event email but found %q", mail)
+	}
+
+	var buf bytes.Buffer
+	if err := NewWriterSink(&buf).Send(context.Background(), e); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if buf.String() != "2019-03-24T10:58:00Z "+message+"\n" {
+		t.Fatalf("expected the event line but found %q", buf.String())
+	}
+}
+
+// testSource sends the updates pushed on its channel. The reseed history is
+// returned once the history was read.
+type testSource struct {
+	history []*types.History
+	reseed  []*types.History
+	read    bool
+	updates chan *proposals.Update
+}
+
+func (s *testSource) HeadSHA() string { return "head" }
+
+func (s *testSource) Subscribe(buffer int) (<-chan *proposals.Update, func()) {
+	return s.updates, func() {}
+}
+
+func (s *testSource) ProposalsHistory(filters ...proposals.Filter) ([]*types.History, error) {
+	if s.read {
+		return s.reseed, nil
+	}
+	s.read = true
+	return s.history, nil
+}
+
+// TestRun tests that the updates are evaluated and dispatched until the
+// context is cancelled.
+func TestRun(t *testing.T) {
+	src := &testSource{history: testFlush(0, "a:Yes"), updates: make(chan *proposals.Update)}
+	sink := new(TestSink)
+	n := New(Config{Rules: []Rule{{Quorum: 2}}, Sinks: []Sink{sink}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- n.Run(ctx, src) }()
+
+	src.updates <- &proposals.Update{PreviousSHA: "head", HeadSHA: "sha",
+		History: testFlush(1, "b:Yes")}
+	cancel()
+
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("expected the context canceled error but found: %v", err)
+	}
+
+	events := sink.Events()
+	if len(events) != 1 || events[0].Kind != QuorumReached || events[0].HeadSHA != "sha" {
+		t.Fatalf("expected a single quorum reached event but found %v", kinds(events))
+	}
+}
+
+// TestRunMissedUpdates tests that an update that does not follow the HEAD last
+// processed is replaced by the complete history.
+func TestRunMissedUpdates(t *testing.T) {
+	src := &testSource{
+		history: testFlush(0, "a:Yes"),
+		reseed:  append(testFlush(0, "a:Yes"), testFlush(1, "b:Yes")...),
+		updates: make(chan *proposals.Update),
+	}
+	sink := new(TestSink)
+	n := New(Config{Rules: []Rule{{Quorum: 2}}, Sinks: []Sink{sink}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- n.Run(ctx, src) }()
+
+	// The history of the missed update is not counted.
+	src.updates <- &proposals.Update{PreviousSHA: "missed", HeadSHA: "sha",
+		History: testFlush(2, "c:Yes")}
+	// The next update follows the HEAD reseeded from thus it is evaluated
+	// and the quorum is not notified again.
+	src.updates <- &proposals.Update{PreviousSHA: "head", HeadSHA: "next"}
+	cancel()
+
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("expected the context canceled error but found: %v", err)
+	}
+
+	events := sink.Events()
+	if len(events) != 1 || events[0].Kind != QuorumReached || events[0].HeadSHA != "head" {
+		t.Fatalf("expected a single quorum reached event but found %v", kinds(events))
+	}
+
+	if events[0].Tally.Total != 2 {
+		t.Fatalf("expected 2 votes counted but found %d", events[0].Tally.Total)
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"sync"
+)
+
+// Sink defines a destination that the events are delivered to.
+type Sink interface {
+	// Name identifies the sink in the logs and errors.
+	Name() string
+
+	// Send delivers the event. An error is returned if the delivery failed
+	// and should be retried.
+	Send(ctx context.Context, e *Event) error
+}
+
+// WebhookSink delivers the events as JSON POST requests to the URL.
+type WebhookSink struct {
+	URL string
+
+	// Client is the http client used. http.DefaultClient is used if nil.
+	Client *http.Client
+}
+
+// Name implements the Sink interface.
+func (s *WebhookSink) Name() string { return "webhook" }
+
+// Send implements the Sink interface.
+func (s *WebhookSink) Send(ctx context.Context, e *Event) error {
+	return postJSON(ctx, s.Client, s.URL, e)
+}
+
+// ChatSink delivers the event messages to a Slack-compatible incoming webhook
+// URL, as used by Slack, Mattermost and the Matrix hookshot bridge.
+type ChatSink struct {
+	URL string
+
+	// Client is the http client used. http.DefaultClient is used if nil.
+	Client *http.Client
+}
+
+// chatMessage is the incoming webhook message payload.
+type chatMessage struct {
+	Text string `json:"text"`
+}
+
+// Name implements the Sink interface.
+func (s *ChatSink) Name() string { return "chat" }
+
+// Send implements the Sink interface.
+func (s *ChatSink) Send(ctx context.Context, e *Event) error {
+	return postJSON(ctx, s.Client, s.URL, chatMessage{Text: e.String()})
+}
+
+// postJSON posts v as the JSON request body to the URL. Responses other than
+// 2xx are returned as errors.
+func postJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Drain the body so that the connection can be reused.
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+	return nil
+}
+
+// SMTPSink delivers the event messages as plain text emails.
+type SMTPSink struct {
+	// Addr is the SMTP server host:port address.
+	Addr string
+
+	// Auth authenticates the sender. It can be nil.
+	Auth smtp.Auth
+
+	From string
+	To   []string
+}
+
+// sendMail sends the emails. It is replaced in tests.
+var sendMail = smtp.SendMail
+
+// Name implements the Sink interface.
+func (s *SMTPSink) Name() string { return "smtp" }
+
+// Send implements the Sink interface.
+func (s *SMTPSink) Send(ctx context.Context, e *Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: [piparser] %s %s\r\n"+
+		"Content-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		s.From, strings.Join(s.To, ", "), e.Kind, e.Token, e.String())
+
+	return sendMail(s.Addr, s.Auth, s.From, s.To, []byte(msg))
+}
+
+// WriterSink writes the event messages one per line to the writer e.g.
+// os.Stdout.
+type WriterSink struct {
+	mtx sync.Mutex
+	w   io.Writer
+}
+
+// NewWriterSink returns a sink that writes the event messages to w.
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+// Name implements the Sink interface.
+func (s *WriterSink) Name() string { return "writer" }
+
+// Send implements the Sink interface.
+func (s *WriterSink) Send(ctx context.Context, e *Event) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	_, err := fmt.Fprintf(s.w, "%s %s\n", e.Time.UTC().Format("2006-01-02T15:04:05Z"), e)
+	return err
+}
+
+// TestSink records the events delivered to it. It helps test the rules.
+type TestSink struct {
+	// FailFirst is the number of deliveries that fail before the events are
+	// recorded. It helps test the retries.
+	FailFirst int
+
+	mtx      sync.Mutex
+	attempts int
+	events   []*Event
+}
+
+// Name implements the Sink interface.
+func (s *TestSink) Name() string { return "test" }
+
+// Send implements the Sink interface.
+func (s *TestSink) Send(ctx context.Context, e *Event) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.attempts++
+	if s.attempts <= s.FailFirst {
+		return fmt.Errorf("delivery %d failed", s.attempts)
+	}
+
+	s.events = append(s.events, e)
+	return nil
+}
+
+// Events returns the events recorded.
+func (s *TestSink) Events() []*Event {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return append([]*Event(nil), s.events...)
+}
@@ -0,0 +1,9 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+// Package piparserpb holds the protobuf messages and the gRPC service
+// definitions of the votes data API. The code is generated from
+// piparser.proto, see the rpcserver package for the service implementation.
+package piparserpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative piparser.proto
@@ -0,0 +1,1141 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        (unknown)
+// source: piparser.proto
+
+package piparserpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// TimeRange is the estimated range within which a vote was cast.
+type TimeRange struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Earliest      *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=earliest,proto3" json:"earliest,omitempty"`
+	Latest        *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=latest,proto3" json:"latest,omitempty"`
+	Estimate      *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=estimate,proto3" json:"estimate,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TimeRange) Reset() {
+	*x = TimeRange{}
+	mi := &file_piparser_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TimeRange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TimeRange) ProtoMessage() {}
+
+func (x *TimeRange) ProtoReflect() protoreflect.Message {
+	mi := &file_piparser_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TimeRange.ProtoReflect.Descriptor instead.
+func (*TimeRange) Descriptor() ([]byte, []int) {
+	return file_piparser_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *TimeRange) GetEarliest() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Earliest
+	}
+	return nil
+}
+
+func (x *TimeRange) GetLatest() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Latest
+	}
+	return nil
+}
+
+func (x *TimeRange) GetEstimate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Estimate
+	}
+	return nil
+}
+
+// Vote is a single vote cast by a ticket.
+type Vote struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Ticket          string                 `protobuf:"bytes,1,opt,name=ticket,proto3" json:"ticket,omitempty"`
+	Option          string                 `protobuf:"bytes,2,opt,name=option,proto3" json:"option,omitempty"`
+	FlushedAt       *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=flushed_at,json=flushedAt,proto3" json:"flushed_at,omitempty"`
+	EstimatedCastAt *TimeRange             `protobuf:"bytes,4,opt,name=estimated_cast_at,json=estimatedCastAt,proto3" json:"estimated_cast_at,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Vote) Reset() {
+	*x = Vote{}
+	mi := &file_piparser_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Vote) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Vote) ProtoMessage() {}
+
+func (x *Vote) ProtoReflect() protoreflect.Message {
+	mi := &file_piparser_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Vote.ProtoReflect.Descriptor instead.
+func (*Vote) Descriptor() ([]byte, []int) {
+	return file_piparser_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Vote) GetTicket() string {
+	if x != nil {
+		return x.Ticket
+	}
+	return ""
+}
+
+func (x *Vote) GetOption() string {
+	if x != nil {
+		return x.Option
+	}
+	return ""
+}
+
+func (x *Vote) GetFlushedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FlushedAt
+	}
+	return nil
+}
+
+func (x *Vote) GetEstimatedCastAt() *TimeRange {
+	if x != nil {
+		return x.EstimatedCastAt
+	}
+	return nil
+}
+
+// FileVotes holds the votes read from a single proposal journal file.
+type FileVotes struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Token         string                 `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	Version       string                 `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
+	JournalType   string                 `protobuf:"bytes,3,opt,name=journal_type,json=journalType,proto3" json:"journal_type,omitempty"`
+	Change        string                 `protobuf:"bytes,4,opt,name=change,proto3" json:"change,omitempty"`
+	Votes         []*Vote                `protobuf:"bytes,5,rep,name=votes,proto3" json:"votes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileVotes) Reset() {
+	*x = FileVotes{}
+	mi := &file_piparser_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileVotes) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileVotes) ProtoMessage() {}
+
+func (x *FileVotes) ProtoReflect() protoreflect.Message {
+	mi := &file_piparser_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileVotes.ProtoReflect.Descriptor instead.
+func (*FileVotes) Descriptor() ([]byte, []int) {
+	return file_piparser_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *FileVotes) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *FileVotes) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *FileVotes) GetJournalType() string {
+	if x != nil {
+		return x.JournalType
+	}
+	return ""
+}
+
+func (x *FileVotes) GetChange() string {
+	if x != nil {
+		return x.Change
+	}
+	return ""
+}
+
+func (x *FileVotes) GetVotes() []*Vote {
+	if x != nil {
+		return x.Votes
+	}
+	return nil
+}
+
+// Commit holds the votes flushed by a single commit.
+type Commit struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sha           string                 `protobuf:"bytes,1,opt,name=sha,proto3" json:"sha,omitempty"`
+	Author        string                 `protobuf:"bytes,2,opt,name=author,proto3" json:"author,omitempty"`
+	Date          *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=date,proto3" json:"date,omitempty"`
+	Parents       []string               `protobuf:"bytes,4,rep,name=parents,proto3" json:"parents,omitempty"`
+	Files         []*FileVotes           `protobuf:"bytes,5,rep,name=files,proto3" json:"files,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Commit) Reset() {
+	*x = Commit{}
+	mi := &file_piparser_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Commit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Commit) ProtoMessage() {}
+
+func (x *Commit) ProtoReflect() protoreflect.Message {
+	mi := &file_piparser_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Commit.ProtoReflect.Descriptor instead.
+func (*Commit) Descriptor() ([]byte, []int) {
+	return file_piparser_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Commit) GetSha() string {
+	if x != nil {
+		return x.Sha
+	}
+	return ""
+}
+
+func (x *Commit) GetAuthor() string {
+	if x != nil {
+		return x.Author
+	}
+	return ""
+}
+
+func (x *Commit) GetDate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Date
+	}
+	return nil
+}
+
+func (x *Commit) GetParents() []string {
+	if x != nil {
+		return x.Parents
+	}
+	return nil
+}
+
+func (x *Commit) GetFiles() []*FileVotes {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+// VoteRecord is a single vote with its proposal and commit details.
+type VoteRecord struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Token         string                 `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	Version       string                 `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
+	Ticket        string                 `protobuf:"bytes,3,opt,name=ticket,proto3" json:"ticket,omitempty"`
+	Option        string                 `protobuf:"bytes,4,opt,name=option,proto3" json:"option,omitempty"`
+	CommitSha     string                 `protobuf:"bytes,5,opt,name=commit_sha,json=commitSha,proto3" json:"commit_sha,omitempty"`
+	Timestamp     *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Author        string                 `protobuf:"bytes,7,opt,name=author,proto3" json:"author,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VoteRecord) Reset() {
+	*x = VoteRecord{}
+	mi := &file_piparser_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VoteRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VoteRecord) ProtoMessage() {}
+
+func (x *VoteRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_piparser_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VoteRecord.ProtoReflect.Descriptor instead.
+func (*VoteRecord) Descriptor() ([]byte, []int) {
+	return file_piparser_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *VoteRecord) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *VoteRecord) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *VoteRecord) GetTicket() string {
+	if x != nil {
+		return x.Ticket
+	}
+	return ""
+}
+
+func (x *VoteRecord) GetOption() string {
+	if x != nil {
+		return x.Option
+	}
+	return ""
+}
+
+func (x *VoteRecord) GetCommitSha() string {
+	if x != nil {
+		return x.CommitSha
+	}
+	return ""
+}
+
+func (x *VoteRecord) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *VoteRecord) GetAuthor() string {
+	if x != nil {
+		return x.Author
+	}
+	return ""
+}
+
+type ListProposalsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListProposalsRequest) Reset() {
+	*x = ListProposalsRequest{}
+	mi := &file_piparser_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListProposalsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListProposalsRequest) ProtoMessage() {}
+
+func (x *ListProposalsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_piparser_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListProposalsRequest.ProtoReflect.Descriptor instead.
+func (*ListProposalsRequest) Descriptor() ([]byte, []int) {
+	return file_piparser_proto_rawDescGZIP(), []int{5}
+}
+
+type ListProposalsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	HeadSha       string                 `protobuf:"bytes,1,opt,name=head_sha,json=headSha,proto3" json:"head_sha,omitempty"`
+	Tokens        []string               `protobuf:"bytes,2,rep,name=tokens,proto3" json:"tokens,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListProposalsResponse) Reset() {
+	*x = ListProposalsResponse{}
+	mi := &file_piparser_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListProposalsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListProposalsResponse) ProtoMessage() {}
+
+func (x *ListProposalsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_piparser_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListProposalsResponse.ProtoReflect.Descriptor instead.
+func (*ListProposalsResponse) Descriptor() ([]byte, []int) {
+	return file_piparser_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ListProposalsResponse) GetHeadSha() string {
+	if x != nil {
+		return x.HeadSha
+	}
+	return ""
+}
+
+func (x *ListProposalsResponse) GetTokens() []string {
+	if x != nil {
+		return x.Tokens
+	}
+	return nil
+}
+
+// ProposalHistoryRequest selects the history streamed. An empty token selects
+// all the proposals. The unset since and until dates are ignored.
+type ProposalHistoryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Token         string                 `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	Since         *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=since,proto3" json:"since,omitempty"`
+	Until         *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=until,proto3" json:"until,omitempty"`
+	Version       string                 `protobuf:"bytes,4,opt,name=version,proto3" json:"version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProposalHistoryRequest) Reset() {
+	*x = ProposalHistoryRequest{}
+	mi := &file_piparser_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProposalHistoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProposalHistoryRequest) ProtoMessage() {}
+
+func (x *ProposalHistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_piparser_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProposalHistoryRequest.ProtoReflect.Descriptor instead.
+func (*ProposalHistoryRequest) Descriptor() ([]byte, []int) {
+	return file_piparser_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ProposalHistoryRequest) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *ProposalHistoryRequest) GetSince() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Since
+	}
+	return nil
+}
+
+func (x *ProposalHistoryRequest) GetUntil() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Until
+	}
+	return nil
+}
+
+func (x *ProposalHistoryRequest) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+type TallyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Token         string                 `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	Since         *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=since,proto3" json:"since,omitempty"`
+	Until         *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=until,proto3" json:"until,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TallyRequest) Reset() {
+	*x = TallyRequest{}
+	mi := &file_piparser_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TallyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TallyRequest) ProtoMessage() {}
+
+func (x *TallyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_piparser_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TallyRequest.ProtoReflect.Descriptor instead.
+func (*TallyRequest) Descriptor() ([]byte, []int) {
+	return file_piparser_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *TallyRequest) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *TallyRequest) GetSince() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Since
+	}
+	return nil
+}
+
+func (x *TallyRequest) GetUntil() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Until
+	}
+	return nil
+}
+
+type TallyResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Token         string                 `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	Options       map[string]int64       `protobuf:"bytes,2,rep,name=options,proto3" json:"options,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	Total         int64                  `protobuf:"varint,3,opt,name=total,proto3" json:"total,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TallyResponse) Reset() {
+	*x = TallyResponse{}
+	mi := &file_piparser_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TallyResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TallyResponse) ProtoMessage() {}
+
+func (x *TallyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_piparser_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TallyResponse.ProtoReflect.Descriptor instead.
+func (*TallyResponse) Descriptor() ([]byte, []int) {
+	return file_piparser_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *TallyResponse) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *TallyResponse) GetOptions() map[string]int64 {
+	if x != nil {
+		return x.Options
+	}
+	return nil
+}
+
+func (x *TallyResponse) GetTotal() int64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+type TicketVotesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ticket        string                 `protobuf:"bytes,1,opt,name=ticket,proto3" json:"ticket,omitempty"`
+	Since         *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=since,proto3" json:"since,omitempty"`
+	Until         *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=until,proto3" json:"until,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TicketVotesRequest) Reset() {
+	*x = TicketVotesRequest{}
+	mi := &file_piparser_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TicketVotesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TicketVotesRequest) ProtoMessage() {}
+
+func (x *TicketVotesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_piparser_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TicketVotesRequest.ProtoReflect.Descriptor instead.
+func (*TicketVotesRequest) Descriptor() ([]byte, []int) {
+	return file_piparser_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *TicketVotesRequest) GetTicket() string {
+	if x != nil {
+		return x.Ticket
+	}
+	return ""
+}
+
+func (x *TicketVotesRequest) GetSince() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Since
+	}
+	return nil
+}
+
+func (x *TicketVotesRequest) GetUntil() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Until
+	}
+	return nil
+}
+
+type TicketVotesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Votes         []*VoteRecord          `protobuf:"bytes,1,rep,name=votes,proto3" json:"votes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TicketVotesResponse) Reset() {
+	*x = TicketVotesResponse{}
+	mi := &file_piparser_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TicketVotesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TicketVotesResponse) ProtoMessage() {}
+
+func (x *TicketVotesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_piparser_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TicketVotesResponse.ProtoReflect.Descriptor instead.
+func (*TicketVotesResponse) Descriptor() ([]byte, []int) {
+	return file_piparser_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *TicketVotesResponse) GetVotes() []*VoteRecord {
+	if x != nil {
+		return x.Votes
+	}
+	return nil
+}
+
+// StreamVotesRequest selects the proposals whose new votes are streamed. No
+// tokens selects all the proposals.
+type StreamVotesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tokens        []string               `protobuf:"bytes,1,rep,name=tokens,proto3" json:"tokens,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamVotesRequest) Reset() {
+	*x = StreamVotesRequest{}
+	mi := &file_piparser_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamVotesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamVotesRequest) ProtoMessage() {}
+
+func (x *StreamVotesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_piparser_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamVotesRequest.ProtoReflect.Descriptor instead.
+func (*StreamVotesRequest) Descriptor() ([]byte, []int) {
+	return file_piparser_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *StreamVotesRequest) GetTokens() []string {
+	if x != nil {
+		return x.Tokens
+	}
+	return nil
+}
+
+// Rollback lists the commits dropped from the history when the repository was
+// force-pushed. The votes received from the dropped commits must be retracted.
+// If unknown is set, the dropped commits could not be listed thus all the votes
+// received so far must be discarded; the commits of the update then hold the
+// complete history.
+type Rollback struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ForkPoint     string                 `protobuf:"bytes,1,opt,name=fork_point,json=forkPoint,proto3" json:"fork_point,omitempty"`
+	DroppedShas   []string               `protobuf:"bytes,2,rep,name=dropped_shas,json=droppedShas,proto3" json:"dropped_shas,omitempty"`
+	Unknown       bool                   `protobuf:"varint,3,opt,name=unknown,proto3" json:"unknown,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Rollback) Reset() {
+	*x = Rollback{}
+	mi := &file_piparser_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Rollback) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Rollback) ProtoMessage() {}
+
+func (x *Rollback) ProtoReflect() protoreflect.Message {
+	mi := &file_piparser_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Rollback.ProtoReflect.Descriptor instead.
+func (*Rollback) Descriptor() ([]byte, []int) {
+	return file_piparser_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *Rollback) GetForkPoint() string {
+	if x != nil {
+		return x.ForkPoint
+	}
+	return ""
+}
+
+func (x *Rollback) GetDroppedShas() []string {
+	if x != nil {
+		return x.DroppedShas
+	}
+	return nil
+}
+
+func (x *Rollback) GetUnknown() bool {
+	if x != nil {
+		return x.Unknown
+	}
+	return false
+}
+
+// VotesUpdate holds the commits fetched by a single repository update. The
+// rollback, if set, must be applied before the commits.
+type VotesUpdate struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PreviousSha   string                 `protobuf:"bytes,1,opt,name=previous_sha,json=previousSha,proto3" json:"previous_sha,omitempty"`
+	HeadSha       string                 `protobuf:"bytes,2,opt,name=head_sha,json=headSha,proto3" json:"head_sha,omitempty"`
+	Commits       []*Commit              `protobuf:"bytes,3,rep,name=commits,proto3" json:"commits,omitempty"`
+	Rollback      *Rollback              `protobuf:"bytes,4,opt,name=rollback,proto3" json:"rollback,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VotesUpdate) Reset() {
+	*x = VotesUpdate{}
+	mi := &file_piparser_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VotesUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VotesUpdate) ProtoMessage() {}
+
+func (x *VotesUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_piparser_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VotesUpdate.ProtoReflect.Descriptor instead.
+func (*VotesUpdate) Descriptor() ([]byte, []int) {
+	return file_piparser_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *VotesUpdate) GetPreviousSha() string {
+	if x != nil {
+		return x.PreviousSha
+	}
+	return ""
+}
+
+func (x *VotesUpdate) GetHeadSha() string {
+	if x != nil {
+		return x.HeadSha
+	}
+	return ""
+}
+
+func (x *VotesUpdate) GetCommits() []*Commit {
+	if x != nil {
+		return x.Commits
+	}
+	return nil
+}
+
+func (x *VotesUpdate) GetRollback() *Rollback {
+	if x != nil {
+		return x.Rollback
+	}
+	return nil
+}
+
+var File_piparser_proto protoreflect.FileDescriptor
+
+const file_piparser_proto_rawDesc = "" +
+	"\n" +
+	"\x0epiparser.proto\x12\vpiparser.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xaf\x01\n" +
+	"\tTimeRange\x126\n" +
+	"\bearliest\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\bearliest\x122\n" +
+	"\x06latest\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x06latest\x126\n" +
+	"\bestimate\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\bestimate\"\xb5\x01\n" +
+	"\x04Vote\x12\x16\n" +
+	"\x06ticket\x18\x01 \x01(\tR\x06ticket\x12\x16\n" +
+	"\x06option\x18\x02 \x01(\tR\x06option\x129\n" +
+	"\n" +
+	"flushed_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\tflushedAt\x12B\n" +
+	"\x11estimated_cast_at\x18\x04 \x01(\v2\x16.piparser.v1.TimeRangeR\x0festimatedCastAt\"\x9f\x01\n" +
+	"\tFileVotes\x12\x14\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\x12\x18\n" +
+	"\aversion\x18\x02 \x01(\tR\aversion\x12!\n" +
+	"\fjournal_type\x18\x03 \x01(\tR\vjournalType\x12\x16\n" +
+	"\x06change\x18\x04 \x01(\tR\x06change\x12'\n" +
+	"\x05votes\x18\x05 \x03(\v2\x11.piparser.v1.VoteR\x05votes\"\xaa\x01\n" +
+	"\x06Commit\x12\x10\n" +
+	"\x03sha\x18\x01 \x01(\tR\x03sha\x12\x16\n" +
+	"\x06author\x18\x02 \x01(\tR\x06author\x12.\n" +
+	"\x04date\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x04date\x12\x18\n" +
+	"\aparents\x18\x04 \x03(\tR\aparents\x12,\n" +
+	"\x05files\x18\x05 \x03(\v2\x16.piparser.v1.FileVotesR\x05files\"\xdd\x01\n" +
+	"\n" +
+	"VoteRecord\x12\x14\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\x12\x18\n" +
+	"\aversion\x18\x02 \x01(\tR\aversion\x12\x16\n" +
+	"\x06ticket\x18\x03 \x01(\tR\x06ticket\x12\x16\n" +
+	"\x06option\x18\x04 \x01(\tR\x06option\x12\x1d\n" +
+	"\n" +
+	"commit_sha\x18\x05 \x01(\tR\tcommitSha\x128\n" +
+	"\ttimestamp\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12\x16\n" +
+	"\x06author\x18\a \x01(\tR\x06author\"\x16\n" +
+	"\x14ListProposalsRequest\"J\n" +
+	"\x15ListProposalsResponse\x12\x19\n" +
+	"\bhead_sha\x18\x01 \x01(\tR\aheadSha\x12\x16\n" +
+	"\x06tokens\x18\x02 \x03(\tR\x06tokens\"\xac\x01\n" +
+	"\x16ProposalHistoryRequest\x12\x14\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\x120\n" +
+	"\x05since\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x05since\x120\n" +
+	"\x05until\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x05until\x12\x18\n" +
+	"\aversion\x18\x04 \x01(\tR\aversion\"\x88\x01\n" +
+	"\fTallyRequest\x12\x14\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\x120\n" +
+	"\x05since\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x05since\x120\n" +
+	"\x05until\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x05until\"\xba\x01\n" +
+	"\rTallyResponse\x12\x14\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\x12A\n" +
+	"\aoptions\x18\x02 \x03(\v2'.piparser.v1.TallyResponse.OptionsEntryR\aoptions\x12\x14\n" +
+	"\x05total\x18\x03 \x01(\x03R\x05total\x1a:\n" +
+	"\fOptionsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\"\x90\x01\n" +
+	"\x12TicketVotesRequest\x12\x16\n" +
+	"\x06ticket\x18\x01 \x01(\tR\x06ticket\x120\n" +
+	"\x05since\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x05since\x120\n" +
+	"\x05until\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x05until\"D\n" +
+	"\x13TicketVotesResponse\x12-\n" +
+	"\x05votes\x18\x01 \x03(\v2\x17.piparser.v1.VoteRecordR\x05votes\",\n" +
+	"\x12StreamVotesRequest\x12\x16\n" +
+	"\x06tokens\x18\x01 \x03(\tR\x06tokens\"f\n" +
+	"\bRollback\x12\x1d\n" +
+	"\n" +
+	"fork_point\x18\x01 \x01(\tR\tforkPoint\x12!\n" +
+	"\fdropped_shas\x18\x02 \x03(\tR\vdroppedShas\x12\x18\n" +
+	"\aunknown\x18\x03 \x01(\bR\aunknown\"\xad\x01\n" +
+	"\vVotesUpdate\x12!\n" +
+	"\fprevious_sha\x18\x01 \x01(\tR\vpreviousSha\x12\x19\n" +
+	"\bhead_sha\x18\x02 \x01(\tR\aheadSha\x12-\n" +
+	"\acommits\x18\x03 \x03(\v2\x13.piparser.v1.CommitR\acommits\x121\n" +
+	"\brollback\x18\x04 \x01(\v2\x15.piparser.v1.RollbackR\brollback2\x8f\x03\n" +
+	"\bPiparser\x12V\n" +
+	"\rListProposals\x12!.piparser.v1.ListProposalsRequest\x1a\".piparser.v1.ListProposalsResponse\x12M\n" +
+	"\x0fProposalHistory\x12#.piparser.v1.ProposalHistoryRequest\x1a\x13.piparser.v1.Commit0\x01\x12>\n" +
+	"\x05Tally\x12\x19.piparser.v1.TallyRequest\x1a\x1a.piparser.v1.TallyResponse\x12P\n" +
+	"\vTicketVotes\x12\x1f.piparser.v1.TicketVotesRequest\x1a .piparser.v1.TicketVotesResponse\x12J\n" +
+	"\vStreamVotes\x12\x1f.piparser.v1.StreamVotesRequest\x1a\x18.piparser.v1.VotesUpdate0\x01B-Z+github.com/dmigwi/go-piparser/v1/piparserpbb\x06proto3"
+
+var (
+	file_piparser_proto_rawDescOnce sync.Once
+	file_piparser_proto_rawDescData []byte
+)
+
+func file_piparser_proto_rawDescGZIP() []byte {
+	file_piparser_proto_rawDescOnce.Do(func() {
+		file_piparser_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_piparser_proto_rawDesc), len(file_piparser_proto_rawDesc)))
+	})
+	return file_piparser_proto_rawDescData
+}
+
+var file_piparser_proto_msgTypes = make([]protoimpl.MessageInfo, 16)
+var file_piparser_proto_goTypes = []any{
+	(*TimeRange)(nil),              // 0: piparser.v1.TimeRange
+	(*Vote)(nil),                   // 1: piparser.v1.Vote
+	(*FileVotes)(nil),              // 2: piparser.v1.FileVotes
+	(*Commit)(nil),                 // 3: piparser.v1.Commit
+	(*VoteRecord)(nil),             // 4: piparser.v1.VoteRecord
+	(*ListProposalsRequest)(nil),   // 5: piparser.v1.ListProposalsRequest
+	(*ListProposalsResponse)(nil),  // 6: piparser.v1.ListProposalsResponse
+	(*ProposalHistoryRequest)(nil), // 7: piparser.v1.ProposalHistoryRequest
+	(*TallyRequest)(nil),           // 8: piparser.v1.TallyRequest
+	(*TallyResponse)(nil),          // 9: piparser.v1.TallyResponse
+	(*TicketVotesRequest)(nil),     // 10: piparser.v1.TicketVotesRequest
+	(*TicketVotesResponse)(nil),    // 11: piparser.v1.TicketVotesResponse
+	(*StreamVotesRequest)(nil),     // 12: piparser.v1.StreamVotesRequest
+	(*Rollback)(nil),               // 13: piparser.v1.Rollback
+	(*VotesUpdate)(nil),            // 14: piparser.v1.VotesUpdate
+	nil,                            // 15: piparser.v1.TallyResponse.OptionsEntry
+	(*timestamppb.Timestamp)(nil),  // 16: google.protobuf.Timestamp
+}
+var file_piparser_proto_depIdxs = []int32{
+	16, // 0: piparser.v1.TimeRange.earliest:type_name -> google.protobuf.Timestamp
+	16, // 1: piparser.v1.TimeRange.latest:type_name -> google.protobuf.Timestamp
+	16, // 2: piparser.v1.TimeRange.estimate:type_name -> google.protobuf.Timestamp
+	16, // 3: piparser.v1.Vote.flushed_at:type_name -> google.protobuf.Timestamp
+	0,  // 4: piparser.v1.Vote.estimated_cast_at:type_name -> piparser.v1.TimeRange
+	1,  // 5: piparser.v1.FileVotes.votes:type_name -> piparser.v1.Vote
+	16, // 6: piparser.v1.Commit.date:type_name -> google.protobuf.Timestamp
+	2,  // 7: piparser.v1.Commit.files:type_name -> piparser.v1.FileVotes
+	16, // 8: piparser.v1.VoteRecord.timestamp:type_name -> google.protobuf.Timestamp
+	16, // 9: piparser.v1.ProposalHistoryRequest.since:type_name -> google.protobuf.Timestamp
+	16, // 10: piparser.v1.ProposalHistoryRequest.until:type_name -> google.protobuf.Timestamp
+	16, // 11: piparser.v1.TallyRequest.since:type_name -> google.protobuf.Timestamp
+	16, // 12: piparser.v1.TallyRequest.until:type_name -> google.protobuf.Timestamp
+	15, // 13: piparser.v1.TallyResponse.options:type_name -> piparser.v1.TallyResponse.OptionsEntry
+	16, // 14: piparser.v1.TicketVotesRequest.since:type_name -> google.protobuf.Timestamp
+	16, // 15: piparser.v1.TicketVotesRequest.until:type_name -> google.protobuf.Timestamp
+	4,  // 16: piparser.v1.TicketVotesResponse.votes:type_name -> piparser.v1.VoteRecord
+	3,  // 17: piparser.v1.VotesUpdate.commits:type_name -> piparser.v1.Commit
+	13, // 18: piparser.v1.VotesUpdate.rollback:type_name -> piparser.v1.Rollback
+	5,  // 19: piparser.v1.Piparser.ListProposals:input_type -> piparser.v1.ListProposalsRequest
+	7,  // 20: piparser.v1.Piparser.ProposalHistory:input_type -> piparser.v1.ProposalHistoryRequest
+	8,  // 21: piparser.v1.Piparser.Tally:input_type -> piparser.v1.TallyRequest
+	10, // 22: piparser.v1.Piparser.TicketVotes:input_type -> piparser.v1.TicketVotesRequest
+	12, // 23: piparser.v1.Piparser.StreamVotes:input_type -> piparser.v1.StreamVotesRequest
+	6,  // 24: piparser.v1.Piparser.ListProposals:output_type -> piparser.v1.ListProposalsResponse
+	3,  // 25: piparser.v1.Piparser.ProposalHistory:output_type -> piparser.v1.Commit
+	9,  // 26: piparser.v1.Piparser.Tally:output_type -> piparser.v1.TallyResponse
+	11, // 27: piparser.v1.Piparser.TicketVotes:output_type -> piparser.v1.TicketVotesResponse
+	14, // 28: piparser.v1.Piparser.StreamVotes:output_type -> piparser.v1.VotesUpdate
+	24, // [24:29] is the sub-list for method output_type
+	19, // [19:24] is the sub-list for method input_type
+	19, // [19:19] is the sub-list for extension type_name
+	19, // [19:19] is the sub-list for extension extendee
+	0,  // [0:19] is the sub-list for field type_name
+}
+
+func init() { file_piparser_proto_init() }
+func file_piparser_proto_init() {
+	if File_piparser_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_piparser_proto_rawDesc), len(file_piparser_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   16,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_piparser_proto_goTypes,
+		DependencyIndexes: file_piparser_proto_depIdxs,
+		MessageInfos:      file_piparser_proto_msgTypes,
+	}.Build()
+	File_piparser_proto = out.File
+	file_piparser_proto_goTypes = nil
+	file_piparser_proto_depIdxs = nil
+}
@@ -0,0 +1,303 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: piparser.proto
+
+package piparserpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Piparser_ListProposals_FullMethodName   = "/piparser.v1.Piparser/ListProposals"
+	Piparser_ProposalHistory_FullMethodName = "/piparser.v1.Piparser/ProposalHistory"
+	Piparser_Tally_FullMethodName           = "/piparser.v1.Piparser/Tally"
+	Piparser_TicketVotes_FullMethodName     = "/piparser.v1.Piparser/TicketVotes"
+	Piparser_StreamVotes_FullMethodName     = "/piparser.v1.Piparser/StreamVotes"
+)
+
+// PiparserClient is the client API for Piparser service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Piparser serves the Politeia votes data parsed from the proposals git
+// repository. It mirrors the proposals.Parser queries.
+type PiparserClient interface {
+	// ListProposals lists the tokens of the proposals found in the repository.
+	ListProposals(ctx context.Context, in *ListProposalsRequest, opts ...grpc.CallOption) (*ListProposalsResponse, error)
+	// ProposalHistory streams the commits with votes of a single proposal or of
+	// all the proposals in the order they were made.
+	ProposalHistory(ctx context.Context, in *ProposalHistoryRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Commit], error)
+	// Tally counts the votes cast per vote option on a proposal.
+	Tally(ctx context.Context, in *TallyRequest, opts ...grpc.CallOption) (*TallyResponse, error)
+	// TicketVotes lists the votes cast by a ticket.
+	TicketVotes(ctx context.Context, in *TicketVotesRequest, opts ...grpc.CallOption) (*TicketVotesResponse, error)
+	// StreamVotes streams the commits fetched by each repository update as they
+	// are parsed.
+	StreamVotes(ctx context.Context, in *StreamVotesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[VotesUpdate], error)
+}
+
+type piparserClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPiparserClient(cc grpc.ClientConnInterface) PiparserClient {
+	return &piparserClient{cc}
+}
+
+func (c *piparserClient) ListProposals(ctx context.Context, in *ListProposalsRequest, opts ...grpc.CallOption) (*ListProposalsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListProposalsResponse)
+	err := c.cc.Invoke(ctx, Piparser_ListProposals_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *piparserClient) ProposalHistory(ctx context.Context, in *ProposalHistoryRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Commit], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Piparser_ServiceDesc.Streams[0], Piparser_ProposalHistory_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ProposalHistoryRequest, Commit]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Piparser_ProposalHistoryClient = grpc.ServerStreamingClient[Commit]
+
+func (c *piparserClient) Tally(ctx context.Context, in *TallyRequest, opts ...grpc.CallOption) (*TallyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TallyResponse)
+	err := c.cc.Invoke(ctx, Piparser_Tally_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *piparserClient) TicketVotes(ctx context.Context, in *TicketVotesRequest, opts ...grpc.CallOption) (*TicketVotesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TicketVotesResponse)
+	err := c.cc.Invoke(ctx, Piparser_TicketVotes_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *piparserClient) StreamVotes(ctx context.Context, in *StreamVotesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[VotesUpdate], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Piparser_ServiceDesc.Streams[1], Piparser_StreamVotes_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamVotesRequest, VotesUpdate]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Piparser_StreamVotesClient = grpc.ServerStreamingClient[VotesUpdate]
+
+// PiparserServer is the server API for Piparser service.
+// All implementations must embed UnimplementedPiparserServer
+// for forward compatibility.
+//
+// Piparser serves the Politeia votes data parsed from the proposals git
+// repository. It mirrors the proposals.Parser queries.
+type PiparserServer interface {
+	// ListProposals lists the tokens of the proposals found in the repository.
+	ListProposals(context.Context, *ListProposalsRequest) (*ListProposalsResponse, error)
+	// ProposalHistory streams the commits with votes of a single proposal or of
+	// all the proposals in the order they were made.
+	ProposalHistory(*ProposalHistoryRequest, grpc.ServerStreamingServer[Commit]) error
+	// Tally counts the votes cast per vote option on a proposal.
+	Tally(context.Context, *TallyRequest) (*TallyResponse, error)
+	// TicketVotes lists the votes cast by a ticket.
+	TicketVotes(context.Context, *TicketVotesRequest) (*TicketVotesResponse, error)
+	// StreamVotes streams the commits fetched by each repository update as they
+	// are parsed.
+	StreamVotes(*StreamVotesRequest, grpc.ServerStreamingServer[VotesUpdate]) error
+	mustEmbedUnimplementedPiparserServer()
+}
+
+// UnimplementedPiparserServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPiparserServer struct{}
+
+func (UnimplementedPiparserServer) ListProposals(context.Context, *ListProposalsRequest) (*ListProposalsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListProposals not implemented")
+}
+func (UnimplementedPiparserServer) ProposalHistory(*ProposalHistoryRequest, grpc.ServerStreamingServer[Commit]) error {
+	return status.Errorf(codes.Unimplemented, "method ProposalHistory not implemented")
+}
+func (UnimplementedPiparserServer) Tally(context.Context, *TallyRequest) (*TallyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Tally not implemented")
+}
+func (UnimplementedPiparserServer) TicketVotes(context.Context, *TicketVotesRequest) (*TicketVotesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TicketVotes not implemented")
+}
+func (UnimplementedPiparserServer) StreamVotes(*StreamVotesRequest, grpc.ServerStreamingServer[VotesUpdate]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamVotes not implemented")
+}
+func (UnimplementedPiparserServer) mustEmbedUnimplementedPiparserServer() {}
+func (UnimplementedPiparserServer) testEmbeddedByValue()                  {}
+
+// UnsafePiparserServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PiparserServer will
+// result in compilation errors.
+type UnsafePiparserServer interface {
+	mustEmbedUnimplementedPiparserServer()
+}
+
+func RegisterPiparserServer(s grpc.ServiceRegistrar, srv PiparserServer) {
+	// If the following call pancis, it indicates UnimplementedPiparserServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Piparser_ServiceDesc, srv)
+}
+
+func _Piparser_ListProposals_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListProposalsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PiparserServer).ListProposals(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Piparser_ListProposals_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PiparserServer).ListProposals(ctx, req.(*ListProposalsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Piparser_ProposalHistory_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ProposalHistoryRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PiparserServer).ProposalHistory(m, &grpc.GenericServerStream[ProposalHistoryRequest, Commit]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Piparser_ProposalHistoryServer = grpc.ServerStreamingServer[Commit]
+
+func _Piparser_Tally_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TallyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PiparserServer).Tally(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Piparser_Tally_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PiparserServer).Tally(ctx, req.(*TallyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Piparser_TicketVotes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TicketVotesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PiparserServer).TicketVotes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Piparser_TicketVotes_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PiparserServer).TicketVotes(ctx, req.(*TicketVotesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Piparser_StreamVotes_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamVotesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PiparserServer).StreamVotes(m, &grpc.GenericServerStream[StreamVotesRequest, VotesUpdate]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Piparser_StreamVotesServer = grpc.ServerStreamingServer[VotesUpdate]
+
+// Piparser_ServiceDesc is the grpc.ServiceDesc for Piparser service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Piparser_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "piparser.v1.Piparser",
+	HandlerType: (*PiparserServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListProposals",
+			Handler:    _Piparser_ListProposals_Handler,
+		},
+		{
+			MethodName: "Tally",
+			Handler:    _Piparser_Tally_Handler,
+		},
+		{
+			MethodName: "TicketVotes",
+			Handler:    _Piparser_TicketVotes_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ProposalHistory",
+			Handler:       _Piparser_ProposalHistory_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamVotes",
+			Handler:       _Piparser_StreamVotes_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "piparser.proto",
+}
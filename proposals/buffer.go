@@ -0,0 +1,60 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package proposals
+
+import (
+	"github.com/dmigwi/go-piparser/proposals/types"
+)
+
+// BufferedWalk returns a WalkFunc that queues the history walked and calls fn
+// with it, in the same order, from a separate goroutine. The history keeps being
+// parsed while a slow fn e.g. one writing to a network client, catches up. At
+// most size history items are queued: the walk blocks while the queue is full
+// so that a slow fn never causes the full history to be held in memory.
+//
+// wait must be called once the walk returns. It blocks until fn has been
+// called with all the queued history and returns the first error returned by
+// fn. The walk is stopped with that error too.
+func BufferedWalk(fn WalkFunc, size int) (walk WalkFunc, wait func() error) {
+	queue := make(chan *types.History, size)
+	stopped := make(chan struct{})
+	finished := make(chan struct{})
+
+	// err is only read once stopped or finished is closed.
+	var err error
+
+	go func() {
+		defer close(finished)
+
+		for h := range queue {
+			if err = fn(h); err != nil {
+				close(stopped)
+				return
+			}
+		}
+	}()
+
+	walk = func(h *types.History) error {
+		select {
+		case <-stopped:
+			return err
+		default:
+		}
+
+		select {
+		case queue <- h:
+			return nil
+		case <-stopped:
+			return err
+		}
+	}
+
+	wait = func() error {
+		close(queue)
+		<-finished
+		return err
+	}
+
+	return walk, wait
+}
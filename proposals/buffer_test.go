@@ -0,0 +1,87 @@
+package proposals
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dmigwi/go-piparser/proposals/types"
+)
+
+// TestBufferedWalk tests that the history is passed to a slow walk function
+// in order, and that the walk blocks once the queue holds size items.
+func TestBufferedWalk(t *testing.T) {
+	const size = 4
+
+	release := make(chan struct{})
+	var walked []*types.History
+	walk, wait := BufferedWalk(func(h *types.History) error {
+		<-release
+		walked = append(walked, h)
+		return nil
+	}, size)
+
+	history := make([]*types.History, 3*size)
+	for i := range history {
+		history[i] = &types.History{CommitSHA: string(rune('a' + i))}
+	}
+
+	queued := make(chan int)
+	go func() {
+		for _, h := range history {
+			walk(h)
+			queued <- 1
+		}
+		close(queued)
+	}()
+
+	// The walk function holds the first item, the queue the next size ones.
+	var count int
+	for count < size+1 {
+		select {
+		case <-queued:
+			count++
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected %d items to be queued but found %d", size+1, count)
+		}
+	}
+
+	select {
+	case <-queued:
+		t.Fatalf("expected the walk to block while the queue is full")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	for range queued {
+	}
+
+	if err := wait(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if len(walked) != len(history) {
+		t.Fatalf("expected %d items walked but found %d", len(history), len(walked))
+	}
+
+	for i, h := range walked {
+		if h != history[i] {
+			t.Fatalf("expected the items to be walked in order")
+		}
+	}
+
+	// The error returned by the walk function stops the walk.
+	errStop := errors.New("stop")
+	walk, wait = BufferedWalk(func(h *types.History) error {
+		return errStop
+	}, size)
+
+	walk(history[0])
+	if err := wait(); err != errStop {
+		t.Fatalf("expected error %v but found: %v", errStop, err)
+	}
+
+	if err := walk(history[1]); err != errStop {
+		t.Fatalf("expected error %v but found: %v", errStop, err)
+	}
+}
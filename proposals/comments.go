@@ -0,0 +1,75 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package proposals
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dmigwi/go-piparser/proposals/types"
+)
+
+// CommentsWalkFunc is the function called for each commit adding comments read
+// by WalkCommentsHistory. Returning an error stops the walk and the error is
+// returned by WalkCommentsHistory.
+type CommentsWalkFunc func(h *types.CommentsHistory) error
+
+// WalkCommentsHistory streams the comments journal entries of all the proposals
+// added after the since time, if set, to fn in the order the commits were made.
+// The comments are only stored in the git journal layout thus nothing is
+// walked in the other layouts. No lock is held while walking.
+func (p *Parser) WalkCommentsHistory(since time.Time, fn CommentsWalkFunc) (err error) {
+	defer p.observeQuery("WalkCommentsHistory", time.Now(), &err)
+
+	if err := p.ensureHistory(since); err != nil {
+		return err
+	}
+
+	view, err := p.pinnedView()
+	if err != nil {
+		return err
+	}
+
+	if view.layout.Name() != (types.GitJournalLayout{}).Name() {
+		return nil
+	}
+
+	args := []string{listCommitsArg, reverseOrder, commitPatchArg, noColorArg,
+		logFormatArg + types.LogFormat}
+	args = append(args, view.revs()...)
+
+	if !since.IsZero() {
+		args = append(args, sinceArg, since.Format(types.CmdDateFormat))
+	}
+
+	args = append(args, pathSeparatorArg, types.CommentsJournalPathspec("*"))
+
+	err = p.streamCommandOutput(gitCmd, types.RecordSeparator[0], func(entry string) error {
+		if len(strings.TrimSpace(entry)) == 0 {
+			return nil
+		}
+
+		var h types.CommentsHistory
+		if err := types.UnmarshalCommentsRecord(&h, entry, since); err != nil {
+			return fmt.Errorf("UnmarshalCommentsRecord failed: %v", err)
+		}
+
+		if h.SkippedLines > 0 {
+			p.log().Warn("skipped malformed comments journal lines",
+				"commit", h.CommitSHA, "lines", h.SkippedLines)
+		}
+
+		if len(h.Comments) == 0 {
+			return nil
+		}
+
+		return fn(&h)
+	}, args...)
+	if err != nil {
+		return fmt.Errorf("fetching the comments history failed: %v", err)
+	}
+
+	return nil
+}
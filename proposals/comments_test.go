@@ -0,0 +1,86 @@
+package proposals
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmigwi/go-piparser/proposals/types"
+)
+
+// testComment returns a comments journal entry adding the provided comment.
+func testComment(token, commentID, comment string) string {
+	return `{"version":"1","action":"add"}{"token":"` + token + `","parentid":"0",` +
+		`"comment":"` + comment + `","signature":"1f23","publickey":"3e4d",` +
+		`"commentid":"` + commentID + `","receipt":"9f1c","timestamp":1541440693}` + "\n"
+}
+
+// commitComments appends the provided entries into the comments journal of
+// the token and commits them at the provided date.
+func commitComments(t *testing.T, dir, token, date string, entries ...string) {
+	journal := filepath.Join(dir, token, "1", "plugins", "decred", "comments.journal")
+	if err := os.MkdirAll(filepath.Dir(journal), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(journal, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(strings.Join(entries, ""))
+	f.Close()
+
+	runGit(t, dir, "add", "-A")
+	runGitWithEnv(t, dir, []string{"GIT_COMMITTER_DATE=" + date}, "commit", "-q",
+		"--date", date, "-m", types.DefaultVotesCommitMsg+".\n\n"+token)
+}
+
+// TestWalkCommentsHistory tests that the comments added after the since time
+// are walked in the order they were committed.
+func TestWalkCommentsHistory(t *testing.T) {
+	p := newTestParser(t)
+	repoDir := filepath.Join(p.cloneDir, cloneRepoAlias)
+
+	commitComments(t, repoDir, testToken, "Mon Nov 5 18:58:13 2018 +0000",
+		testComment(testToken, "1", "first"))
+	commitComments(t, repoDir, testToken, "Mon Nov 5 19:58:13 2018 +0000",
+		testComment(testToken, "2", "second"), testComment(testToken, "3", "third"))
+
+	if err := p.pinSnapshot(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	td := []struct {
+		since    time.Time
+		comments []string
+	}{
+		{time.Time{}, []string{"1", "2", "3"}},
+		{time.Date(2018, 11, 5, 19, 0, 0, 0, time.UTC), []string{"2", "3"}},
+		{time.Date(2018, 11, 5, 20, 0, 0, 0, time.UTC), nil},
+	}
+
+	for i, val := range td {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			var comments []string
+			err := p.WalkCommentsHistory(val.since, func(h *types.CommentsHistory) error {
+				for _, c := range h.Comments {
+					if c.Token != testToken || c.Version != "1" || c.Action != types.CommentAdded {
+						t.Fatalf("expected a comment added to %s but found %+v", testToken, c)
+					}
+					comments = append(comments, c.CommentID)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("expected no error but found: %v", err)
+			}
+
+			if strings.Join(comments, ",") != strings.Join(val.comments, ",") {
+				t.Fatalf("expected comments %v but found %v", val.comments, comments)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package proposals
+
+import (
+	"time"
+
+	"github.com/dmigwi/go-piparser/proposals/types"
+)
+
+// Filter defines a function that sets a query filter. The filters narrow down
+// the history returned by the queries.
+type Filter func(*queryFilter)
+
+// queryFilter holds the filters set on a single query.
+type queryFilter struct {
+	version string
+	until   time.Time
+	ticket  string
+}
+
+// VersionFilter limits the history returned to the votes cast for the provided
+// proposal record version.
+func VersionFilter(version string) Filter {
+	return func(q *queryFilter) {
+		q.version = version
+	}
+}
+
+// UntilFilter limits the history returned to the commits made before or at the
+// provided date. The later commits are not read from the repository at all.
+func UntilFilter(until time.Time) Filter {
+	return func(q *queryFilter) {
+		q.until = until
+	}
+}
+
+// TicketFilter limits the history returned to the votes cast by the provided
+// ticket.
+func TicketFilter(ticket string) Filter {
+	return func(q *queryFilter) {
+		q.ticket = ticket
+	}
+}
+
+// newQueryFilter returns the query filter with the provided filters set.
+func newQueryFilter(filters []Filter) *queryFilter {
+	q := new(queryFilter)
+	for _, filter := range filters {
+		filter(q)
+	}
+	return q
+}
+
+// apply drops the history files and votes that do not match the query filter.
+// It returns false if no file is left in the history. The until date is also
+// passed to git by walkProposal thus it is only checked here as a safety net.
+func (q *queryFilter) apply(h *types.History) bool {
+	if !q.until.IsZero() && h.Date.After(q.until) {
+		return false
+	}
+
+	if q.version == "" && q.ticket == "" {
+		return true
+	}
+
+	var files []*types.File
+	for _, f := range h.Patch {
+		if q.version != "" && f.Version != q.version {
+			continue
+		}
+
+		if q.ticket != "" {
+			var votes types.Votes
+			for _, v := range f.VotesInfo {
+				if v.PiVote != nil && v.Ticket == q.ticket {
+					votes = append(votes, v)
+				}
+			}
+
+			if len(votes) == 0 {
+				continue
+			}
+			f.VotesInfo = votes
+		}
+
+		files = append(files, f)
+	}
+
+	h.Patch = files
+	return len(files) > 0
+}
@@ -0,0 +1,68 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package proposals
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// lockFileName is the name of the advisory lock file created in the root
+	// clone directory. Only the process holding a lock on it updates the
+	// cloned repository.
+	lockFileName = ".go-piparser.lock"
+
+	// lockRetryInterval defines how long to wait before attempting to acquire
+	// the clone directory lock again when LockWait is set.
+	lockRetryInterval = 500 * time.Millisecond
+)
+
+// ErrCloneDirLocked is returned if the clone directory lock is held by another
+// process and LockFail behavior is set.
+var ErrCloneDirLocked = errors.New("clone directory is locked by another process")
+
+// dirLock defines an advisory lock on a directory shared by several processes.
+// It is not safe for concurrent use; the Parser serializes access to it.
+type dirLock struct {
+	path string
+	file *os.File
+}
+
+// newDirLock returns a dirLock for the provided directory.
+func newDirLock(dir string) *dirLock {
+	return &dirLock{path: filepath.Join(dir, lockFileName)}
+}
+
+// lock acquires the directory lock. If the lock is held by another process,
+// it waits for the lock to be released only if wait is true. It returns
+// boolean false if the lock could not be acquired.
+func (l *dirLock) lock(wait bool) (bool, error) {
+	for {
+		isLocked, err := l.tryLock()
+		if err != nil || isLocked || !wait {
+			return isLocked, err
+		}
+
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// lockCloneDir acquires the clone directory lock according to the set lock
+// behavior. It returns boolean false if the update should be skipped because
+// another process is updating the repository.
+func (p *Parser) lockCloneDir() (bool, error) {
+	isLocked, err := p.cloneLock.lock(p.lockBehavior == LockWait)
+	if err != nil {
+		return false, err
+	}
+
+	if !isLocked && p.lockBehavior == LockFail {
+		return false, ErrCloneDirLocked
+	}
+
+	return isLocked, nil
+}
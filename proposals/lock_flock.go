@@ -0,0 +1,49 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package proposals
+
+import (
+	"os"
+	"syscall"
+)
+
+// tryLock attempts to acquire an exclusive flock(2) lock on the lock file
+// without blocking. The lock is released by the kernel if the process exits
+// without unlocking it.
+func (l *dirLock) tryLock() (bool, error) {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return false, err
+	}
+
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		f.Close()
+		return false, nil
+	}
+
+	if err != nil {
+		f.Close()
+		return false, err
+	}
+
+	l.file = f
+	return true, nil
+}
+
+// unlock releases the lock held.
+func (l *dirLock) unlock() error {
+	if l.file == nil {
+		return nil
+	}
+
+	err := syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	l.file.Close()
+	l.file = nil
+
+	return err
+}
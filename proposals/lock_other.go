@@ -0,0 +1,43 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package proposals
+
+import (
+	"fmt"
+	"os"
+)
+
+// tryLock attempts to exclusively create the lock file. On platforms without
+// flock(2) the lock file existence is the lock thus a lock file left behind by
+// a crashed process must be removed manually.
+func (l *dirLock) tryLock() (bool, error) {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if os.IsExist(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	fmt.Fprintf(f, "%d", os.Getpid())
+
+	l.file = f
+	return true, nil
+}
+
+// unlock releases the lock held.
+func (l *dirLock) unlock() error {
+	if l.file == nil {
+		return nil
+	}
+
+	l.file.Close()
+	l.file = nil
+
+	return os.Remove(l.path)
+}
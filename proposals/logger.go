@@ -0,0 +1,70 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package proposals
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+)
+
+// Logger defines the structured logger that the Parser reports its events to.
+// args holds alternating key and value pairs that describe the event. Its
+// method set matches the one of *slog.Logger thus it can be set directly.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// stdLogger is the Logger used if none was set. It writes the warnings and the
+// errors to the standard log package logger and drops the rest.
+type stdLogger struct{}
+
+func (stdLogger) Debug(string, ...interface{}) {}
+func (stdLogger) Info(string, ...interface{})  {}
+
+func (stdLogger) Warn(msg string, args ...interface{}) {
+	log.Print(formatEvent("WARN", msg, args))
+}
+
+func (stdLogger) Error(msg string, args ...interface{}) {
+	log.Print(formatEvent("ERROR", msg, args))
+}
+
+// DiscardLogger is a Logger that drops all the events. It is used if a nil
+// logger was set.
+type DiscardLogger struct{}
+
+func (DiscardLogger) Debug(string, ...interface{}) {}
+func (DiscardLogger) Info(string, ...interface{})  {}
+func (DiscardLogger) Warn(string, ...interface{})  {}
+func (DiscardLogger) Error(string, ...interface{}) {}
+
+// formatEvent formats the event as its level and message followed by the
+// key=value pairs e.g. `WARN updateEnv failed error="exit status 128"`.
+func formatEvent(level, msg string, args []interface{}) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s %s", level, msg)
+
+	for i := 0; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			fmt.Fprintf(&buf, " !BADKEY=%v", args[i])
+			break
+		}
+		fmt.Fprintf(&buf, " %v=%q", args[i], fmt.Sprint(args[i+1]))
+	}
+
+	return buf.String()
+}
+
+// log returns the set Logger. Parsers that were not created by NewParser
+// drop all the events.
+func (p *Parser) log() Logger {
+	if p.logger == nil {
+		return DiscardLogger{}
+	}
+	return p.logger
+}
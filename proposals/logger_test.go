@@ -0,0 +1,108 @@
+package proposals
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testLogger records the events logged to it as formatted strings.
+type testLogger struct {
+	sync.Mutex
+	events []string
+}
+
+func (l *testLogger) record(level, msg string, args []interface{}) {
+	l.Lock()
+	l.events = append(l.events, formatEvent(level, msg, args))
+	l.Unlock()
+}
+
+func (l *testLogger) Debug(msg string, args ...interface{}) { l.record("DEBUG", msg, args) }
+func (l *testLogger) Info(msg string, args ...interface{})  { l.record("INFO", msg, args) }
+func (l *testLogger) Warn(msg string, args ...interface{})  { l.record("WARN", msg, args) }
+func (l *testLogger) Error(msg string, args ...interface{}) { l.record("ERROR", msg, args) }
+
+// find returns the first recorded event with the provided prefix.
+func (l *testLogger) find(prefix string) (string, bool) {
+	l.Lock()
+	defer l.Unlock()
+
+	for _, e := range l.events {
+		if strings.HasPrefix(e, prefix) {
+			return e, true
+		}
+	}
+	return "", false
+}
+
+// TestFormatEvent tests the formatting of the events written by the default
+// logger.
+func TestFormatEvent(t *testing.T) {
+	td := []struct {
+		level  string
+		msg    string
+		args   []interface{}
+		output string
+	}{
+		{"WARN", "updateEnv failed", []interface{}{"error", fmt.Errorf("exit status 128")},
+			`WARN updateEnv failed error="exit status 128"`},
+		{"ERROR", "no args", nil, `ERROR no args`},
+		{"INFO", "pinned", []interface{}{"head_sha", "abc", "lines", 2},
+			`INFO pinned head_sha="abc" lines="2"`},
+		{"INFO", "odd args", []interface{}{"head_sha"}, `INFO odd args !BADKEY=head_sha`},
+	}
+
+	for i, val := range td {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			output := formatEvent(val.level, val.msg, val.args)
+			if output != val.output {
+				t.Fatalf("expected the event %q but found %q", val.output, output)
+			}
+		})
+	}
+}
+
+// TestLoggerEvents tests that the snapshot range processed and the parse
+// warnings are logged to the set logger.
+func TestLoggerEvents(t *testing.T) {
+	l := new(testLogger)
+	p := newTestParser(t, WithLogger(l))
+
+	if err := p.pinSnapshot(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+	previousSHA := p.HeadSHA()
+
+	repoDir := filepath.Join(p.cloneDir, cloneRepoAlias)
+	commitVotes(t, repoDir, testToken, "Mon Nov 5 18:58:13 2018 +0000",
+		testVote(testToken, strings.Repeat("c", 64), "2"), "malformed line\n")
+
+	if err := p.pinSnapshot(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	want := fmt.Sprintf(`INFO pinned a new snapshot previous_sha=%q head_sha=%q`,
+		previousSHA, p.HeadSHA())
+	if _, ok := l.find(want); !ok {
+		t.Fatalf("expected the event %q but found %v", want, l.events)
+	}
+
+	if _, err := p.ProposalHistory(testToken); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	want = `WARN skipped malformed journal lines token="` + testToken + `"`
+	if _, ok := l.find(want); !ok {
+		t.Fatalf("expected the event %q but found %v", want, l.events)
+	}
+
+	// A nil logger drops all the events.
+	p = newTestParser(t, WithLogger(nil))
+	if err := p.pinSnapshot(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package proposals
+
+import (
+	"time"
+)
+
+// Metrics defines the hooks that the Parser reports its activity to. It lets
+// the applications collect the Parser metrics without the proposals package
+// depending on any metrics library. The methods must be safe for concurrent
+// use.
+type Metrics interface {
+	// ObserveUpdate is called after every repository update attempt.
+	ObserveUpdate(duration time.Duration, err error)
+
+	// ObserveGitCommand is called after every git command run. subcommand is
+	// the git subcommand e.g. "log" or "fetch".
+	ObserveGitCommand(subcommand string, duration time.Duration, err error)
+
+	// ObserveQuery is called after every query. method is the name of the
+	// Parser method queried e.g. "ProposalHistory".
+	ObserveQuery(method string, duration time.Duration, err error)
+
+	// ObserveParsed is called for every commit parsed with the number of
+	// votes parsed and the number of malformed lines skipped.
+	ObserveParsed(votes, skippedLines int)
+}
+
+// noopMetrics is the Metrics used if none was set. It discards everything.
+type noopMetrics struct{}
+
+func (noopMetrics) ObserveUpdate(time.Duration, error)             {}
+func (noopMetrics) ObserveGitCommand(string, time.Duration, error) {}
+func (noopMetrics) ObserveQuery(string, time.Duration, error)      {}
+func (noopMetrics) ObserveParsed(int, int)                         {}
+
+// observer returns the set Metrics or one that discards everything if none
+// was set.
+func (p *Parser) observer() Metrics {
+	if p.metrics == nil {
+		return noopMetrics{}
+	}
+	return p.metrics
+}
+
+// observeQuery reports the duration and the error of the query method that
+// started at the provided time. It is meant to be deferred.
+func (p *Parser) observeQuery(method string, start time.Time, err *error) {
+	p.observer().ObserveQuery(method, time.Since(start), *err)
+}
+
+// observeCommand reports the duration and the error of the command that
+// started at the provided time.
+func (p *Parser) observeCommand(cmdName string, args []string, start time.Time, err error) {
+	if cmdName != gitCmd || len(args) == 0 {
+		return
+	}
+	p.observer().ObserveGitCommand(args[0], time.Since(start), err)
+}
@@ -0,0 +1,192 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package proposals
+
+import (
+	"time"
+
+	"github.com/dmigwi/go-piparser/proposals/types"
+)
+
+// Option defines a function that sets an optional Parser configuration. The
+// options are applied by NewParser before the environment is set up.
+type Option func(*Parser)
+
+// LockBehavior defines how the Parser reacts when the clone directory lock is
+// held by another process at the time an update is about to be made.
+type LockBehavior int
+
+const (
+	// LockWait blocks the update until the other process releases the lock.
+	// It is the default behavior.
+	LockWait LockBehavior = iota
+
+	// LockFail aborts the update with ErrCloneDirLocked.
+	LockFail
+
+	// LockReadOnly skips the update and only picks up the changes already
+	// made to the clone directory by the process holding the lock.
+	LockReadOnly
+)
+
+// String is the default stringer for the LockBehavior data type.
+func (b LockBehavior) String() string {
+	switch b {
+	case LockWait:
+		return "wait"
+	case LockFail:
+		return "fail"
+	case LockReadOnly:
+		return "read-only"
+	default:
+		return "unknown"
+	}
+}
+
+// WithLockBehavior sets how the Parser behaves when another process holds the
+// clone directory lock.
+func WithLockBehavior(b LockBehavior) Option {
+	return func(p *Parser) {
+		p.lockBehavior = b
+	}
+}
+
+// WithRemoteURL sets the URL of the remote repository to clone and fetch the
+// updates from. It overrides the github URL built from the repo owner and the
+// repo name. Any URL or path supported by git clone is accepted.
+func WithRemoteURL(url string) Option {
+	return func(p *Parser) {
+		p.repoURL = url
+	}
+}
+
+// WithRetries sets how many times a failed fetch or clone is retried and the
+// delay before the first retry. The delay doubles on every consecutive retry.
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(p *Parser) {
+		p.maxRetries = maxRetries
+		p.retryBackoff = backoff
+	}
+}
+
+// WithShallowSince limits the history fetched by the initial clone to the
+// commits made after the provided date. The older history is fetched when a
+// query requests it. It requires git v2.11.0 or later.
+func WithShallowSince(since time.Time) Option {
+	return func(p *Parser) {
+		p.shallowSince = since
+	}
+}
+
+// WithDepth limits the history fetched by the initial clone to the provided
+// number of the most recent commits. The older history is fetched when a query
+// requests it.
+func WithDepth(depth int) Option {
+	return func(p *Parser) {
+		p.shallowDepth = depth
+	}
+}
+
+// WithSeed sets the git bundle or tar snapshot (.tar, .tar.gz or .tgz) that the
+// clone directory is initialized from if the repository hasn't been cloned
+// yet. The tar snapshot must hold a complete git working directory.
+func WithSeed(seedPath string) Option {
+	return func(p *Parser) {
+		p.seedPath = seedPath
+	}
+}
+
+// WithLayout sets the layout that the repository records are stored in. By
+// default the layout is detected from the repository files of every snapshot
+// pinned.
+func WithLayout(l types.Layout) Option {
+	return func(p *Parser) {
+		p.layout = l
+		p.layoutFixed = l != nil
+	}
+}
+
+// WithOffline sets the Parser to only query the repository already cloned in
+// the clone directory. No changes are fetched from the remote repository and
+// the asynchronous updates are not started.
+func WithOffline() Option {
+	return func(p *Parser) {
+		p.offline = true
+	}
+}
+
+// WithBackgroundSync sets NewParser to return before the repository is cloned
+// or updated. The first update is made in the background and retried every
+// poll interval until it succeeds. Status().Ready() reports whether it has
+// succeeded; the queries made before then fail. In the offline mode, the
+// local clone is still pinned by NewParser.
+func WithBackgroundSync() Option {
+	return func(p *Parser) {
+		p.backgroundSync = true
+	}
+}
+
+// WithPollInterval sets the interval at which the updates are fetched in the
+// background. It defaults to 5 minutes. Applications that request the updates
+// on push webhooks via RequestUpdate can set a longer interval as a fallback.
+func WithPollInterval(d time.Duration) Option {
+	return func(p *Parser) {
+		if d > 0 {
+			p.pollInterval = d
+		}
+	}
+}
+
+// WithAdaptiveSchedule sets the background updates to follow the votes flush
+// cadence learnt from the flush commits dates. While a vote is active, the
+// updates are made every poll interval and just after every expected flush.
+// Otherwise the updates back off up to every 6 hours. See Parser.NextUpdate.
+func WithAdaptiveSchedule() Option {
+	return func(p *Parser) {
+		p.adaptive = true
+	}
+}
+
+// WithLogger sets the structured logger that the Parser reports its events to
+// e.g. the clone, pull and parse warnings. By default the warnings and errors
+// are written to the standard log package logger. A nil logger drops all the
+// events.
+func WithLogger(l Logger) Option {
+	return func(p *Parser) {
+		if l == nil {
+			l = DiscardLogger{}
+		}
+		p.logger = l
+	}
+}
+
+// WithMetrics sets the hooks that the Parser reports its updates, git commands,
+// queries and parsing activity to.
+func WithMetrics(m Metrics) Option {
+	return func(p *Parser) {
+		p.metrics = m
+	}
+}
+
+// WithPinnedCommits sets the known-good commits that the repository history
+// must hold. The updates whose history is missing any of them are refused
+// with a *VerificationError and the previous snapshot is kept. The history of
+// a shallow clone must be deep enough to hold them.
+func WithPinnedCommits(shas ...string) Option {
+	return func(p *Parser) {
+		p.pinnedSHAs = shas
+	}
+}
+
+// WithSignedCommits sets the Parser to only accept the commits signed by the
+// keys defined by the policy. The commits made after the previous snapshot are
+// verified on every update, and the complete history on the first one except
+// the commits reachable from the pinned commits, which are trusted. The
+// updates holding any commit that fails the verification are refused with a
+// *VerificationError and the previous snapshot is kept.
+func WithSignedCommits(policy SignaturePolicy) Option {
+	return func(p *Parser) {
+		p.signatures = &policy
+	}
+}
@@ -8,13 +8,17 @@
 package proposals
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -46,9 +50,25 @@ const (
 	// primarily used to check if git is installed on the underlying platform.
 	versionArg = "--version"
 
-	// pullChangesArg is an argument that helps pull latest changes from the
-	// remote repository set. git pull = git fetch + git merge.
-	pullChangesArg = "pull"
+	// fetchArg is an argument that helps download the latest changes from the
+	// remote repository set without modifying the working directory.
+	fetchArg = "fetch"
+
+	// resetArg is the argument used to move the current branch to another
+	// commit. With hardResetArg, the working directory is updated too.
+	resetArg = "reset"
+
+	// hardResetArg discards any changes made to the working directory when
+	// used with resetArg.
+	hardResetArg = "--hard"
+
+	// fetchHeadRef references the commit last fetched from the remote
+	// repository.
+	fetchHeadRef = "FETCH_HEAD"
+
+	// fsckArg verifies the connectivity and validity of the objects in the
+	// repository.
+	fsckArg = "fsck"
 
 	// remoteURLRef references the remote URL used to clone the repository.
 	// 'origin' is the default set.
@@ -86,16 +106,171 @@ const (
 
 	// remoteDef defines the remote argument
 	remoteDef = "remote"
+
+	// revParseArg is the git command used to resolve a revision name into the
+	// commit SHA it points to.
+	revParseArg = "rev-parse"
+
+	// headRef references the commit currently checked out in the working
+	// directory.
+	headRef = "HEAD"
+
+	// listTreeArg lists the contents of a tree object. With recursiveArg and
+	// nameOnlyArg, it lists the paths of all the files in a commit.
+	listTreeArg = "ls-tree"
+
+	// recursiveArg recurses into the sub-trees.
+	recursiveArg = "-r"
+
+	// dirsOnlyArg only lists the directories in a tree object.
+	dirsOnlyArg = "-d"
+
+	// nameOnlyArg only lists the file paths.
+	nameOnlyArg = "--name-only"
+
+	// findRenamesArg detects the files moved between the record directories so
+	// that only their changed lines are shown in the patch.
+	findRenamesArg = "-M"
+
+	// noColorArg ensures that the patch output isn't colored regardless of the
+	// git configuration set.
+	noColorArg = "--no-color"
+
+	// logFormatArg sets the format that each commit is listed in.
+	logFormatArg = "--format="
+
+	// authorTimeFormatArg formats each commit listed as its author unix
+	// timestamp.
+	authorTimeFormatArg = "--format=%at"
+
+	// untilArg with syntax "--until <date>" returns commits older than a
+	// specific date.
+	untilArg = "--until"
+
+	// excludeRevPrefix is prefixed to a revision to exclude it and its
+	// ancestors from the commits listed.
+	excludeRevPrefix = "^"
+
+	// pathSeparatorArg separates the revision arguments from the path arguments
+	// in a git command.
+	pathSeparatorArg = "--"
 )
 
 // Parser holds the clone directory, repo owner and repo name. This data is
-// used to query politeia data via git command line tool.
+// used to query politeia data via git command line tool. The embedded RWMutex
+// guards the repository snapshot (headSHA) that queries are run against. The
+// queries only hold the read lock while copying the snapshot state thus never
+// hold up the updates, which hold the write lock when swapping the snapshot or
+// when replacing the whole working directory.
 type Parser struct {
 	sync.RWMutex
 	cloneDir    string
 	repoName    string
 	repoOwner   string
 	triggerFlag int32
+
+	// headSHA is the commit SHA of the snapshot that all queries are pinned
+	// to. It is only updated once the repository updates were successfully
+	// fetched.
+	headSHA string
+
+	// pinnedSHAs lists the known-good commits that the history of every
+	// snapshot pinned must hold.
+	pinnedSHAs []string
+
+	// signatures if set, defines the keys that the commits made after the
+	// previous snapshot must be signed by.
+	signatures *SignaturePolicy
+
+	// headTime is the committer date of the snapshot commit.
+	headTime time.Time
+
+	// lastAttempt and lastSuccess are the start times of the last update and
+	// of the last successful update. lastErr is the error returned by the
+	// last update.
+	lastAttempt time.Time
+	lastSuccess time.Time
+	lastErr     error
+
+	// gitVersion is the git installation version checked by the last update.
+	gitVersion string
+
+	// updateMtx ensures that only one repository update runs at a time.
+	updateMtx sync.Mutex
+
+	// cloneLock ensures that only one process updates the repository cloned
+	// in the clone directory at a time.
+	cloneLock *dirLock
+
+	// lockBehavior defines what happens if the clone directory lock is held
+	// by another process.
+	lockBehavior LockBehavior
+
+	// repoURL if set, overrides the github URL built from the repo owner and
+	// the repo name.
+	repoURL string
+
+	// maxRetries defines how many times a failed git network command is
+	// retried before recovery is attempted.
+	maxRetries int
+
+	// retryBackoff defines the delay before the first retry. It doubles on
+	// every consecutive retry.
+	retryBackoff time.Duration
+
+	// shallowSince and shallowDepth if set, limit the history fetched by the
+	// initial clone. The older history is fetched on demand.
+	shallowSince time.Time
+	shallowDepth int
+
+	// seedPath if set, is the git bundle or tar snapshot that the clone
+	// directory is initialized from instead of cloning the remote repository.
+	seedPath string
+
+	// layout defines how the records are stored in the repository. Unless
+	// fixed by WithLayout (layoutFixed), it is detected from the files of the
+	// snapshot layoutSHA and detected again whenever a new snapshot is pinned
+	// since the repository may migrate to a different layout.
+	layout      types.Layout
+	layoutFixed bool
+	layoutSHA   string
+
+	// shallowSHAs holds the boundary commits of a shallow clone snapshot.
+	shallowSHAs []string
+
+	// availableSince is the date after which the full history is available
+	// locally. It is zero if the complete history is available.
+	availableSince time.Time
+
+	// offline if set, stops the Parser from fetching any changes from the
+	// remote repository.
+	offline bool
+
+	// pollInterval is the interval at which the updates are fetched in the
+	// background. updateReq receives the requests for immediate updates.
+	pollInterval time.Duration
+	updateReq    chan struct{}
+
+	// backgroundSync if set, makes the first update in the background rather
+	// than in NewParser.
+	backgroundSync bool
+
+	// adaptive if set, schedules the updates using the learnt flush cadence
+	// held by sched. nextUpdate is the date of the next scheduled update.
+	adaptive   bool
+	sched      schedule
+	nextUpdate time.Time
+
+	// logger receives the Parser events.
+	logger Logger
+
+	// metrics receives the Parser activity reports if set.
+	metrics Metrics
+
+	// subscribers holds the channels that the new history fetched by each
+	// update is sent to. subMtx protects it.
+	subscribers map[chan *Update]struct{}
+	subMtx      sync.Mutex
 }
 
 // triggerChan is a channel used to notify the client if updates are available.
@@ -108,8 +283,9 @@ var triggerChan chan struct{}
 // the repo if it doesn't exist or fetches the latest updates if it does. It
 // initiates an asynchronous fetch of hourly politeia updates and there after
 // triggers the client to fetch the new updates via a signal channel if the
-// trigger flag was set and the channel isn't blocked.
-func NewParser(repoOwner, repo, rootCloneDir string) (*Parser, error) {
+// trigger flag was set and the channel isn't blocked. The optional opts
+// arguments customize the Parser behavior.
+func NewParser(repoOwner, repo, rootCloneDir string, opts ...Option) (*Parser, error) {
 	// Trim trailing and leading whitespaces
 	repo = strings.TrimSpace(repo)
 	repoOwner = strings.TrimSpace(repoOwner)
@@ -139,6 +315,16 @@ func NewParser(repoOwner, repo, rootCloneDir string) (*Parser, error) {
 		repoName:  repo,
 		repoOwner: repoOwner,
 		cloneDir:  rootCloneDir,
+		cloneLock: newDirLock(rootCloneDir),
+
+		maxRetries:   defaultMaxRetries,
+		retryBackoff: defaultRetryBackoff,
+		pollInterval: defaultPollInterval,
+		logger:       stdLogger{},
+	}
+
+	for _, opt := range opts {
+		opt(p)
 	}
 
 	// If tests are running do not proceed further to clone the test git repos.
@@ -146,38 +332,31 @@ func NewParser(repoOwner, repo, rootCloneDir string) (*Parser, error) {
 		return p, nil
 	}
 
+	// The first update is made by the background updates loop. Only the
+	// local clone is pinned in the offline mode thus it is made right away.
+	if p.backgroundSync && !p.offline {
+		p.startUpdates(nil, true)
+		return p, nil
+	}
+
 	// For the first time, initiate git update outside the goroutine and on
 	// consecutive times at intervals of 1hr fetch the updates in a goroutine.
 	if err := p.updateEnv(); err != nil {
 		return nil, fmt.Errorf("updateEnv failed: %v", err)
 	}
 
-	// This git updates fetch is made asynchronous.
-	go func() {
-		// Politeia updates are made at minute 58 of each hour.
-		// https://github.com/decred/politeia/blob/5a6166cf6821be072af2bfe774dd5d12a2fe9d43/politeiad/backend/gitbe/gitbe.go#L74-L76
-		// However, other updates such as creation or editing of a proposal
-		// triggers an immediate commit. Update every 5 minutes.
-		timer := time.NewTicker(5 * time.Minute)
-		for range timer.C {
-			if err := p.updateEnv(); err != nil {
-				log.Printf("updateEnv failed: %v", err)
-				continue
-			}
+	// No updates are fetched in the offline mode.
+	if p.offline {
+		return p, nil
+	}
 
-			// If UpdateSignal() was invoked, the trigger flag must have
-			// been set, an indication that the client wants to fetch updates
-			// after the tool retrieves them.
-			if atomic.LoadInt32(&(p.triggerFlag)) == updateFlag {
-				// Attempt to send updates signal if the channel isn't blocked
-				// otherwise ignore it till next interval.
-				select {
-				case triggerChan <- struct{}{}:
-				default:
-				}
-			}
-		}
-	}()
+	// This git updates fetch is made asynchronous.
+	// Politeia updates are made at minute 58 of each hour.
+	// https://github.com/decred/politeia/blob/5a6166cf6821be072af2bfe774dd5d12a2fe9d43/politeiad/backend/gitbe/gitbe.go#L74-L76
+	// However, other updates such as creation or editing of a proposal
+	// triggers an immediate commit. Update every poll interval (5 minutes by
+	// default) or immediately if an update is requested.
+	p.startUpdates(nil, false)
 
 	return p, nil
 }
@@ -208,68 +387,263 @@ func (p *Parser) TriggerUpdates() error {
 }
 
 // ProposalHistory returns the all the commits history data associated with the
-// provided proposal token. This method is thread-safe.
-func (p *Parser) ProposalHistory(proposalToken string) ([]*types.History, error) {
-	p.Lock()
-	defer p.Unlock()
+// provided proposal token. This method is thread-safe and can be run
+// concurrently with other queries. The optional filters narrow down the
+// history returned.
+func (p *Parser) ProposalHistory(proposalToken string, filters ...Filter) (h []*types.History, err error) {
+	defer p.observeQuery("ProposalHistory", time.Now(), &err)
 
-	if err := types.SetProposalToken(proposalToken); err != nil {
+	if err := isTokenSet(proposalToken); err != nil {
 		// error returned, indicates that the proposal token was empty.
 		return nil, err
 	}
 
-	return p.proposal(proposalToken)
+	if err := p.ensureHistory(time.Time{}); err != nil {
+		return nil, err
+	}
+
+	return p.proposal(proposalToken, newQueryFilter(filters))
 }
 
 // ProposalHistorySince returns the commits history data associated with the
 // provided proposal token and was made after the since argument time provided.
-// This method is thread-safe.
-func (p *Parser) ProposalHistorySince(proposalToken string, since time.Time) ([]*types.History, error) {
-	p.Lock()
-	defer p.Unlock()
+// This method is thread-safe and can be run concurrently with other queries.
+// The optional filters narrow down the history returned.
+func (p *Parser) ProposalHistorySince(proposalToken string, since time.Time,
+	filters ...Filter) (h []*types.History, err error) {
+	defer p.observeQuery("ProposalHistorySince", time.Now(), &err)
 
-	if err := types.SetProposalToken(proposalToken); err != nil {
+	if err := isTokenSet(proposalToken); err != nil {
 		// error returned, indicates that the proposal token was empty.
 		return nil, err
 	}
 
-	return p.proposal(proposalToken, since)
+	if err := p.ensureHistory(since); err != nil {
+		return nil, err
+	}
+
+	return p.proposal(proposalToken, newQueryFilter(filters), since)
 }
 
 // ProposalsHistory returns all the commits history data for the current proposal
-// tokens available. This method is thread-safe.
-func (p *Parser) ProposalsHistory() ([]*types.History, error) {
-	p.Lock()
-	defer p.Unlock()
+// tokens available. This method is thread-safe and can be run concurrently
+// with other queries. The optional filters narrow down the history returned.
+func (p *Parser) ProposalsHistory(filters ...Filter) (h []*types.History, err error) {
+	defer p.observeQuery("ProposalsHistory", time.Now(), &err)
 
-	return p.proposal("")
+	if err := p.ensureHistory(time.Time{}); err != nil {
+		return nil, err
+	}
+
+	return p.proposal("", newQueryFilter(filters))
 }
 
 // ProposalsHistorySince returns all the commits history updates for the current
-// proposal tokens available since the provided date. This method is thread-safe.
-func (p *Parser) ProposalsHistorySince(since time.Time) ([]*types.History, error) {
-	p.Lock()
-	defer p.Unlock()
+// proposal tokens available since the provided date. This method is thread-safe
+// and can be run concurrently with other queries. The optional filters narrow
+// down the history returned.
+func (p *Parser) ProposalsHistorySince(since time.Time, filters ...Filter) (h []*types.History, err error) {
+	defer p.observeQuery("ProposalsHistorySince", time.Now(), &err)
+
+	if err := p.ensureHistory(since); err != nil {
+		return nil, err
+	}
+
+	return p.proposal("", newQueryFilter(filters), since)
+}
+
+// WalkFunc is the function called for each history item read by the walk
+// methods. Returning an error stops the walk and the error is returned by the
+// walk method.
+type WalkFunc func(h *types.History) error
+
+// WalkProposalHistory streams the commits history data associated with the
+// provided proposal token and made after the since time, if set, to fn in the
+// order the commits were made. Unlike ProposalHistory, the history is never
+// held in memory in full. No lock is held while walking thus a slow fn never
+// holds up the updates. The optional filters narrow down the history walked.
+func (p *Parser) WalkProposalHistory(proposalToken string, since time.Time, fn WalkFunc,
+	filters ...Filter) (err error) {
+	defer p.observeQuery("WalkProposalHistory", time.Now(), &err)
+
+	if err := isTokenSet(proposalToken); err != nil {
+		// error returned, indicates that the proposal token was empty.
+		return err
+	}
+
+	if err := p.ensureHistory(since); err != nil {
+		return err
+	}
+
+	view, err := p.pinnedView()
+	if err != nil {
+		return err
+	}
 
-	return p.proposal("", since)
+	return p.walkProposal(view, view.revs(), proposalToken, newQueryFilter(filters),
+		fn, since)
+}
+
+// WalkProposalsHistory streams all the commits history data made after the
+// since time, if set, to fn in the order the commits were made. No lock is held
+// while walking thus a slow fn never holds up the updates. The optional filters
+// narrow down the history walked.
+func (p *Parser) WalkProposalsHistory(since time.Time, fn WalkFunc, filters ...Filter) (err error) {
+	defer p.observeQuery("WalkProposalsHistory", time.Now(), &err)
+
+	if err := p.ensureHistory(since); err != nil {
+		return err
+	}
+
+	view, err := p.pinnedView()
+	if err != nil {
+		return err
+	}
+
+	return p.walkProposal(view, view.revs(), "", newQueryFilter(filters), fn, since)
+}
+
+// ProposalTokens returns the tokens of all the proposals found in the pinned
+// snapshot of the repository. The proposal records are stored in top level
+// directories named after their tokens. This method is thread-safe and can be
+// run concurrently with other queries.
+func (p *Parser) ProposalTokens() (tokens []string, err error) {
+	defer p.observeQuery("ProposalTokens", time.Now(), &err)
+
+	view, err := p.pinnedView()
+	if err != nil {
+		return nil, err
+	}
+
+	dirs, err := p.readCommandOutput(gitCmd, listTreeArg, dirsOnlyArg,
+		nameOnlyArg, view.sha)
+	if err != nil {
+		return nil, fmt.Errorf("listing the proposal tokens failed: %v", err)
+	}
+
+	for _, dir := range strings.Split(dirs, "\n") {
+		if dir = strings.TrimSpace(dir); types.IsToken(dir) {
+			tokens = append(tokens, dir)
+		}
+	}
+	return tokens, nil
+}
+
+// HeadSHA returns the commit SHA of the repository snapshot that the queries
+// are currently run against. An empty string is returned if no snapshot has
+// been pinned yet.
+func (p *Parser) HeadSHA() string {
+	p.RLock()
+	defer p.RUnlock()
+
+	return p.headSHA
+}
+
+// HeadCommitTime returns the committer date of the commit that the queries are
+// currently pinned to. It is zero if no snapshot has been pinned yet.
+func (p *Parser) HeadCommitTime() time.Time {
+	p.RLock()
+	defer p.RUnlock()
+
+	return p.headTime
+}
+
+// isTokenSet returns an error if the provided proposal token is empty.
+func isTokenSet(proposalToken string) error {
+	if len(proposalToken) == 0 {
+		return fmt.Errorf("empty token hash string found")
+	}
+	return nil
 }
 
 // proposal queries and parses the provided proposal token(s) data from the
 // cloned repository using the installed git command line interface tool. If
 // the optional since time argument is provided, only the proposal(s) history
-// returned was created after the since time.
-func (p *Parser) proposal(proposalToken string,
+// returned was created after the since time. Only the history matching the
+// query filter is returned. Only the commits reachable from the pinned
+// snapshot are queried.
+func (p *Parser) proposal(proposalToken string, q *queryFilter,
 	since ...time.Time) (items []*types.History, err error) {
+	view, err := p.pinnedView()
+	if err != nil {
+		return nil, err
+	}
 
-	defer types.ClearProposalToken()
+	err = p.walkProposal(view, view.revs(), proposalToken, q, func(h *types.History) error {
+		items = append(items, h)
+		return nil
+	}, since...)
 
-	var t time.Time
-	args := []string{listCommitsArg, reverseOrder, commitPatchArg}
+	return
+}
 
-	// Append the proposal token limiting argument if it exists.
-	if proposalToken != "" {
-		args = append(args, proposalToken)
+// ErrNotSynced is returned by the queries made before the first update, made
+// in the background if WithBackgroundSync is set, has pinned a snapshot.
+var ErrNotSynced = errors.New("the repository has not been synced yet")
+
+// snapshotView holds a copy of the pinned snapshot state that a query is run
+// against. The queries copy it under the read lock then run git without the
+// lock. The commits of the snapshot stay readable after an update swaps in a
+// new one since the updates never drop the commits, and a replaced clone is
+// moved aside rather than deleted.
+type snapshotView struct {
+	sha         string
+	shallowSHAs []string
+	layout      types.Layout
+}
+
+// pinnedView returns a copy of the pinned snapshot state. ErrNotSynced is
+// returned if the first update is made in the background and no snapshot has
+// been pinned yet.
+func (p *Parser) pinnedView() (*snapshotView, error) {
+	p.RLock()
+	defer p.RUnlock()
+
+	if p.backgroundSync && p.headSHA == "" {
+		return nil, ErrNotSynced
+	}
+
+	return &snapshotView{sha: p.snapshot(), shallowSHAs: p.shallowSHAs,
+		layout: p.recordLayout()}, nil
+}
+
+// revs returns the revisions that select the commits reachable from the
+// snapshot.
+func (s *snapshotView) revs() []string {
+	revs := []string{s.sha}
+
+	// Exclude the shallow clone boundary commits. Their patches are made
+	// against an empty tree since their parents are not available locally.
+	for _, sha := range s.shallowSHAs {
+		revs = append(revs, excludeRevPrefix+sha)
+	}
+	return revs
+}
+
+// isShallowBoundary returns true if the provided commit SHA is a boundary
+// commit of the shallow clone snapshot.
+func (s *snapshotView) isShallowBoundary(sha string) bool {
+	for _, boundary := range s.shallowSHAs {
+		if boundary == sha {
+			return true
+		}
 	}
+	return false
+}
+
+// walkProposal streams the provided proposal token(s) data of the commits
+// selected by revs from the cloned repository, calling fn for each history
+// item in the order the commits were made. The git log output is parsed one
+// commit record at a time so that the full history never needs to be held in
+// memory. Walking stops on the first error returned by fn. The records are
+// parsed in the layout of the snapshot viewed.
+func (p *Parser) walkProposal(view *snapshotView, revs []string, proposalToken string,
+	q *queryFilter, fn WalkFunc, since ...time.Time) error {
+
+	var t time.Time
+	args := []string{listCommitsArg, reverseOrder, commitPatchArg, findRenamesArg,
+		noColorArg, logFormatArg + types.LogFormat}
+	args = append(args, revs...)
 
 	// Append the time limiting argument if it exists.
 	if len(since) > 0 && since[0] != t {
@@ -277,40 +651,197 @@ func (p *Parser) proposal(proposalToken string,
 			since[0].Format(types.CmdDateFormat)}...)
 	}
 
-	// Fetch the data via git cmd.
-	patchData, err := p.readCommandOutput(gitCmd, args...)
-	if err != nil {
-		return nil, fmt.Errorf("fetching proposal(s) history failed: %v", err)
+	// Stop git from reading the commits made after the until filter date.
+	// The filter is still applied to the history parsed since git filters
+	// the commits by the committer date.
+	if !q.until.IsZero() {
+		args = append(args, untilArg, q.until.Format(types.CmdDateFormat))
+	}
+
+	// Append the proposal token limiting argument if it exists.
+	if proposalToken != "" {
+		args = append(args, pathSeparatorArg, proposalToken)
 	}
 
-	data := strings.Split(patchData, "commit")
+	// lastFlush holds the date of the last votes flush found per token. It
+	// helps estimate when the votes of the next flush were cast.
+	lastFlush := p.previousFlushes(view, revs, proposalToken, since...)
 
-	for _, entry := range data {
-		// strings.Split returns some split strings as empty or with just
-		// whitespaces and other special characters. This happens when the
-		// seperating argument is the first in the source string or is
-		// surrounded by whitespaces and other special characters.
+	// Fetch the data via git cmd.
+	err := p.streamCommandOutput(gitCmd, types.RecordSeparator[0], func(entry string) error {
 		if len(strings.TrimSpace(entry)) == 0 {
-			continue
+			return nil
 		}
 
 		var h types.History
 
-		// entry string is not a valid JSON string format thus the use of a
-		// customized unmarshaller.
-		if err = types.CustomUnmashaller(&h, entry, since...); err != nil {
-			return nil, fmt.Errorf("CustomUnmashaller failed: %v", err)
+		err := types.UnmarshalCommitRecord(&h, view.layout, proposalToken,
+			entry, since...)
+		if err != nil {
+			return fmt.Errorf("UnmarshalCommitRecord failed: %v", err)
+		}
+
+		var votes, skipped int
+		for _, f := range h.Patch {
+			votes += len(f.VotesInfo)
+			skipped += f.SkippedLines
+
+			if f.SkippedLines > 0 {
+				p.log().Warn("skipped malformed journal lines", "token", f.Token,
+					"commit", h.CommitSHA, "lines", f.SkippedLines)
+			}
 		}
+		p.observer().ObserveParsed(votes, skipped)
 
-		// Do not store any empty history data.
+		// Do not return any empty history data.
 		if len(h.Patch) == 0 || h.Author == "" || h.CommitSHA == "" {
+			return nil
+		}
+
+		for _, f := range h.Patch {
+			f.EstimateCastTimes(lastFlush[f.Token])
+			lastFlush[f.Token] = h.Date
+		}
+
+		if !q.apply(&h) {
+			return nil
+		}
+
+		return fn(&h)
+	}, args...)
+	if err != nil {
+		return fmt.Errorf("fetching proposal(s) history failed: %v", err)
+	}
+
+	return nil
+}
+
+// previousFlushes returns the date of the last votes flush made before the
+// commits selected by revs and the optional since time, per token. The flushes
+// are listed by a single git log run over the history preceding the walk: the
+// commits made before the since time or those reachable from the excluded
+// revisions. No flush precedes a walk that starts at the root commit.
+func (p *Parser) previousFlushes(view *snapshotView, revs []string, proposalToken string,
+	since ...time.Time) map[string]time.Time {
+	flushes := make(map[string]time.Time)
+
+	args := []string{listCommitsArg, authorTimeFormatArg, nameOnlyArg}
+	switch {
+	case len(since) > 0 && !since[0].IsZero():
+		// Only the commits made after the since time are walked.
+		args = append(args, untilArg, since[0].Format(types.CmdDateFormat))
+		args = append(args, revs...)
+
+	default:
+		// The shallow clone boundaries stay excluded since their parents
+		// are not available locally.
+		var preceding, boundaries []string
+		for _, rev := range revs {
+			sha := strings.TrimPrefix(rev, excludeRevPrefix)
+			switch {
+			case sha == rev:
+			case view.isShallowBoundary(sha):
+				boundaries = append(boundaries, rev)
+			default:
+				preceding = append(preceding, sha)
+			}
+		}
+
+		// The walk starts at the root commit.
+		if len(preceding) == 0 {
+			return flushes
+		}
+		args = append(args, preceding...)
+		args = append(args, boundaries...)
+	}
+
+	token := proposalToken
+	if token == "" {
+		token = "*"
+	}
+	args = append(args, pathSeparatorArg, view.layout.VotesPathspec(token))
+
+	out, err := p.readCommandOutput(gitCmd, args...)
+	if err != nil {
+		p.log().Warn("listing the previous votes flushes failed", "error", err)
+		return flushes
+	}
+
+	// The commits are listed newest first, each as its author timestamp
+	// followed by the votes files it changed.
+	var date time.Time
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
 			continue
 		}
 
-		items = append(items, &h)
+		if !strings.Contains(line, "/") {
+			secs, err := strconv.ParseInt(line, 10, 64)
+			if err != nil {
+				continue
+			}
+			date = time.Unix(secs, 0).UTC()
+			continue
+		}
+
+		token := line[:strings.Index(line, "/")]
+		if _, ok := flushes[token]; !ok && !date.IsZero() {
+			flushes[token] = date
+		}
 	}
 
-	return
+	return flushes
+}
+
+// recordLayout returns the layout that the records are stored in. The git
+// journal layout is returned if no layout has been detected yet. The read lock
+// must be held by the caller.
+func (p *Parser) recordLayout() types.Layout {
+	if p.layout == nil {
+		return types.GitJournalLayout{}
+	}
+	return p.layout
+}
+
+// Layout returns the name of the layout that the repository records are stored
+// in.
+func (p *Parser) Layout() string {
+	p.RLock()
+	defer p.RUnlock()
+
+	return p.recordLayout().Name()
+}
+
+// detectLayout returns the layout that the files at the provided commit are
+// stored in. The layout set by WithLayout or already detected for the commit is
+// returned as is.
+func (p *Parser) detectLayout(sha string) (types.Layout, error) {
+	p.RLock()
+	layout := p.layout
+	isKnown := p.layoutFixed || (layout != nil && p.layoutSHA == sha)
+	p.RUnlock()
+
+	if isKnown {
+		return layout, nil
+	}
+
+	paths, err := p.readCommandOutput(gitCmd, listTreeArg, recursiveArg,
+		nameOnlyArg, sha)
+	if err != nil {
+		return nil, fmt.Errorf("listing the repository files failed: %v", err)
+	}
+
+	return types.DetectLayout(strings.Split(paths, "\n")), nil
+}
+
+// snapshot returns the revision that queries should be run against. If no
+// snapshot has been pinned yet, the currently checked out HEAD is used.
+func (p *Parser) snapshot() string {
+	if p.headSHA == "" {
+		return headRef
+	}
+	return p.headSHA
 }
 
 // updateEnv pulls changes from github if they exists or otherwise it clones the
@@ -318,10 +849,42 @@ func (p *Parser) proposal(proposalToken string,
 // in the underlying platform and has the minimum version required. If the
 // required repo was cloned earlier, only the latest changes are pulled
 // otherwise a fresh clone is made. Should an error occurs while pulling
-// updates, the old repo is dropped and a fresh clone made.
-func (p *Parser) updateEnv() error {
-	p.Lock()
-	defer p.Unlock()
+// updates, recovery is attempted as documented in recoverUpdates. Queries are not
+// blocked while the changes are being pulled since they are pinned to the
+// previous snapshot which is swapped with the new HEAD only on success. If
+// another process holds the clone directory lock, the set lock behavior
+// decides whether to wait, fail or just pick up the changes it made.
+func (p *Parser) updateEnv() (err error) {
+	p.updateMtx.Lock()
+	defer p.updateMtx.Unlock()
+
+	start := time.Now()
+	defer func() {
+		p.recordUpdate(start, err)
+		p.observer().ObserveUpdate(time.Since(start), err)
+	}()
+
+	if p.offline {
+		// Only pick up the changes already made to the clone directory.
+		return p.offlineUpdate()
+	}
+
+	isLocked, err := p.lockCloneDir()
+	if err != nil {
+		return err
+	}
+
+	if !isLocked {
+		// Another process is updating the repository, use whatever it has
+		// fetched so far.
+		return p.readOnlyUpdate()
+	}
+
+	defer p.cloneLock.unlock()
+
+	// No other process updates the clone directory thus the clones replaced
+	// by the previous updates can be dropped.
+	p.dropReplacedClones()
 
 	// check if git exists by checking the git installation version.
 	versionStr, err := p.readCommandOutput(gitCmd, versionArg)
@@ -336,53 +899,175 @@ func (p *Parser) updateEnv() error {
 		return err
 	}
 
+	p.Lock()
+	p.gitVersion = types.GitVersion(versionStr)
+	p.Unlock()
+
 	// full clone directory: includes the expected repository name.
 	workingDir := filepath.Join(p.cloneDir, cloneRepoAlias)
 	_, err = os.Stat(workingDir)
 
-	completeRemoteURL := fmt.Sprintf(remoteURL, p.repoOwner, p.repoName)
+	completeRemoteURL := p.remoteURL()
 
-	switch {
-	case !os.IsNotExist(err):
+	if !os.IsNotExist(err) {
 		// The working directory was found thus check if the tracked repo is the
 		// same as the required one.
 		trackedRepo, err := p.readCommandOutput(gitCmd, remoteDef, trackedRemoteURL, remoteURLRef)
 
 		// If the required tracked repo was found initiate the updates fetch process
-		if err == nil && types.IsMatching(trackedRepo, completeRemoteURL) {
-			if err = p.execCommand(gitCmd, pullChangesArg, remoteURLRef); err == nil {
-				return nil
-			}
+		if err == nil && strings.TrimSpace(trackedRepo) == completeRemoteURL {
+			return p.recoverUpdates(workingDir, completeRemoteURL)
 		}
+	}
 
-		// git pull command failed or the required tracked repo wasn't found.
-		// Drop the old repo and proceed to clone the repo a fresh.
-		if err = os.RemoveAll(workingDir); err != nil {
+	// The required working directory could not be found. Seed it from the set
+	// seed file and fetch the changes made after the seed was created.
+	if os.IsNotExist(err) && p.seedPath != "" {
+		if err = p.seedRepo(workingDir, completeRemoteURL); err != nil {
 			return err
 		}
 
-		fallthrough
+		return p.recoverUpdates(workingDir, completeRemoteURL)
+	}
 
-	default:
-		// The required working directory could not be found or the repo update
-		// process failed. Clone the remote repository into the clone directory.
-		err = p.execCommand(gitCmd, cloneArg, completeRemoteURL, cloneRepoAlias)
-		if err != nil {
-			return fmt.Errorf("failed to clone %s : %v", completeRemoteURL, err)
+	// The required working directory could not be found or a different repo is
+	// being tracked. Clone the required repo and swap it in on success.
+	if err = p.recloneRepo(workingDir, completeRemoteURL); err != nil {
+		return err
+	}
+
+	return p.pinSnapshot()
+}
+
+// remoteURL returns the URL of the remote repository tracked. If no custom URL
+// was set, the github URL is built from the repo owner and repo name.
+func (p *Parser) remoteURL() string {
+	if p.repoURL != "" {
+		return p.repoURL
+	}
+	return fmt.Sprintf(remoteURL, p.repoOwner, p.repoName)
+}
+
+// readOnlyUpdate pins the HEAD of the repository updated by another process
+// as the new snapshot. No changes are made to the clone directory.
+func (p *Parser) readOnlyUpdate() error {
+	workingDir := filepath.Join(p.cloneDir, cloneRepoAlias)
+	if _, err := os.Stat(workingDir); os.IsNotExist(err) {
+		return fmt.Errorf("%v: %s has not been cloned yet", ErrCloneDirLocked,
+			workingDir)
+	}
+
+	return p.pinSnapshot()
+}
+
+// offlineUpdate pins the HEAD of the repository already cloned in the clone
+// directory as the new snapshot. No changes are fetched from the remote.
+func (p *Parser) offlineUpdate() error {
+	workingDir := filepath.Join(p.cloneDir, cloneRepoAlias)
+	if _, err := os.Stat(workingDir); os.IsNotExist(err) {
+		return fmt.Errorf("offline mode: %s has not been cloned yet", workingDir)
+	}
+
+	return p.pinSnapshot()
+}
+
+// pinSnapshot resolves the current HEAD commit SHA and atomically swaps it in
+// as the snapshot that new queries are run against.
+func (p *Parser) pinSnapshot() error {
+	sha, err := p.readCommandOutput(gitCmd, revParseArg, headRef)
+	if err != nil {
+		return fmt.Errorf("resolving %s failed: %v", headRef, err)
+	}
+
+	sha = strings.TrimSpace(sha)
+
+	boundaries, availableSince, err := p.shallowBoundaries()
+	if err != nil {
+		return err
+	}
+
+	layout, err := p.detectLayout(sha)
+	if err != nil {
+		return err
+	}
+
+	headTime, err := p.commitTime(sha)
+	if err != nil {
+		return err
+	}
+
+	// Only the updates made while holding the update lock pin the snapshots
+	// thus the previous snapshot cannot change until it is replaced below.
+	p.RLock()
+	previousSHA := p.headSHA
+	previousLayout := p.layout
+	p.RUnlock()
+
+	if previousSHA != sha {
+		if err = p.verifyHistory(previousSHA, sha); err != nil {
+			return err
 		}
 	}
 
+	p.Lock()
+	p.headSHA = sha
+	p.headTime = headTime
+	p.layout = layout
+	p.layoutSHA = sha
+	p.shallowSHAs = boundaries
+	p.availableSince = availableSince
+	p.Unlock()
+
+	if previousLayout != nil && previousLayout.Name() != layout.Name() {
+		p.log().Info("the record layout changed", "previous_layout",
+			previousLayout.Name(), "layout", layout.Name(), "head_sha", sha)
+	}
+
+	if previousSHA == sha {
+		p.log().Debug("no new commits found", "head_sha", sha)
+		return nil
+	}
+
+	p.log().Info("pinned a new snapshot", "previous_sha", previousSHA,
+		"head_sha", sha, "commit_time", headTime)
+
+	if previousSHA == "" {
+		return nil
+	}
+
+	rollback := p.detectRollback(previousSHA, sha)
+	if rollback != nil {
+		p.log().Warn("the history was rewritten: rolling back the dropped commits",
+			"previous_sha", previousSHA, "head_sha", sha, "fork_point",
+			rollback.ForkPoint, "dropped", len(rollback.Commits), "unknown",
+			rollback.Unknown)
+	}
+
+	p.publishUpdate(previousSHA, sha, rollback)
+
 	return nil
 }
 
 // readCommandOutput reads the std output messages of the run command.
 func (p *Parser) readCommandOutput(cmdName string, args ...string) (string, error) {
+	return p.readCommandOutputIn(p.workingDir(), cmdName, args...)
+}
+
+// readCommandOutputIn reads the std output messages of the command run in the
+// provided directory.
+func (p *Parser) readCommandOutputIn(dir, cmdName string, args ...string) (string, error) {
 	cmd, err := p.processCommand(cmdName, args...)
 	if err != nil {
 		return "", err
 	}
 
-	stdOutput, err := cmd.CombinedOutput()
+	cmd.Dir = dir
+
+	// Only the std output is read so that the warnings written to the std
+	// error do not get mixed up with the output parsed.
+	start := time.Now()
+	stdOutput, err := cmd.Output()
+	p.observeCommand(cmdName, args, start, err)
 	if err != nil {
 		return "", formatError(cmdName, args, err)
 	}
@@ -390,6 +1075,68 @@ func (p *Parser) readCommandOutput(cmdName string, args ...string) (string, erro
 	return string(stdOutput), nil
 }
 
+// streamCommandOutput runs the provided command in the working directory and
+// calls fn with each chunk of the std output terminated by the delim byte. The
+// delimiter is stripped from the chunks. If fn returns an error, the command
+// is killed and the error returned.
+func (p *Parser) streamCommandOutput(cmdName string, delim byte,
+	fn func(chunk string) error, args ...string) error {
+	cmd, err := p.processCommand(cmdName, args...)
+	if err != nil {
+		return err
+	}
+
+	var stdErr bytes.Buffer
+	cmd.Stderr = &stdErr
+
+	stdOut, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	if err = cmd.Start(); err != nil {
+		p.observeCommand(cmdName, args, start, err)
+		return formatError(cmdName, args, err)
+	}
+
+	reader := bufio.NewReader(stdOut)
+	for {
+		chunk, readErr := reader.ReadString(delim)
+		chunk = strings.TrimSuffix(chunk, string(delim))
+
+		if len(chunk) > 0 {
+			if err = fn(chunk); err != nil {
+				cmd.Process.Kill()
+				cmd.Wait()
+				return err
+			}
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+
+		if readErr != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return readErr
+		}
+	}
+
+	err = cmd.Wait()
+	p.observeCommand(cmdName, args, start, err)
+
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			exitErr.Stderr = stdErr.Bytes()
+		}
+		return formatError(cmdName, args, err)
+	}
+
+	return nil
+}
+
 // execCommand executes commands that do not return necessary std output messages.
 func (p *Parser) execCommand(cmdName string, args ...string) error {
 	cmd, err := p.processCommand(cmdName, args...)
@@ -397,7 +1144,9 @@ func (p *Parser) execCommand(cmdName string, args ...string) error {
 		return err
 	}
 
+	start := time.Now()
 	err = cmd.Run()
+	p.observeCommand(cmdName, args, start, err)
 
 	return formatError(cmdName, args, err)
 }
@@ -409,6 +1158,9 @@ func (p *Parser) processCommand(cmdName string, args ...string) (*exec.Cmd, erro
 		return nil, fmt.Errorf("missing command")
 	}
 	cmd := exec.Command(cmdName, args...)
+	if p.signatures != nil {
+		cmd.Env = append(os.Environ(), p.signatures.env()...)
+	}
 
 	// set the working directory.
 	cmd.Dir = p.workingDir()
@@ -426,10 +1178,17 @@ func (p *Parser) workingDir() string {
 }
 
 // formatError replaces "exit status 128" with "git args... failed to execute."
+// The std error output captured, if any, is appended to the error message.
 func formatError(cmd string, args []string, err error) error {
 	if err == nil {
 		return err
 	}
 	str := fmt.Sprintf("%s command with %v failed to execute", cmd, args)
-	return fmt.Errorf(types.ReplaceAny(err.Error(), "exit status 128", str))
+	msg := types.ReplaceAny(err.Error(), "exit status 128", str)
+
+	if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+		msg += ": " + strings.TrimSpace(string(exitErr.Stderr))
+	}
+
+	return errors.New(msg)
 }
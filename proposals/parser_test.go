@@ -1,11 +1,16 @@
 package proposals
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/dmigwi/go-piparser/proposals/types"
 )
@@ -96,3 +101,893 @@ func TestNewParser(t *testing.T) {
 		})
 	}
 }
+
+// testToken is the proposal token whose votes are committed into the test
+// repository.
+const testToken = "27f87171d98b7923a1bd2bee6affed929fa2d2a6e178b5c80a9971a92a5c7f50"
+
+// testVote returns a ballot journal entry for the provided ticket and votebit.
+func testVote(token, ticket, voteBit string) string {
+	return `{"version":"1","action":"add"}{"castvote":{"token":"` + token +
+		`","ticket":"` + ticket + `","votebit":"` + voteBit +
+		`","signature":"1f23"},"receipt":"9f1c"}` + "\n"
+}
+
+// runGit executes the git command in the provided directory and fails the test
+// if an error occurs.
+func runGit(t *testing.T, dir string, args ...string) string {
+	return runGitWithEnv(t, dir, nil, args...)
+}
+
+// runGitWithEnv executes the git command in the provided directory with the
+// extra environment variables set and fails the test if an error occurs.
+func runGitWithEnv(t *testing.T, dir string, env []string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Politeia", "GIT_AUTHOR_EMAIL=noreply@decred.org",
+		"GIT_COMMITTER_NAME=Politeia", "GIT_COMMITTER_EMAIL=noreply@decred.org",
+	)
+	cmd.Env = append(cmd.Env, env...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %v: %s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// commitVotes appends the provided votes into the ballot journal of the token
+// and commits them as a votes flush made at the provided date.
+func commitVotes(t *testing.T, dir, token, date string, votes ...string) {
+	journal := filepath.Join(dir, token, "1", "plugins", "decred", "ballot.journal")
+	if err := os.MkdirAll(filepath.Dir(journal), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(journal, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(strings.Join(votes, ""))
+	f.Close()
+
+	runGit(t, dir, "add", "-A")
+	runGitWithEnv(t, dir, []string{"GIT_COMMITTER_DATE=" + date}, "commit", "-q",
+		"--date", date, "-m", types.DefaultVotesCommitMsg+".\n\n"+token)
+}
+
+// initTestRepo initializes a git repository in the provided directory with a
+// single votes flush commit.
+func initTestRepo(t *testing.T, repoDir string) {
+	if err := os.MkdirAll(repoDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	runGit(t, repoDir, "init", "-q")
+	commitVotes(t, repoDir, testToken, "Mon Nov 5 17:58:13 2018 +0000",
+		testVote(testToken, strings.Repeat("a", 64), "1"),
+		testVote(testToken, strings.Repeat("b", 64), "2"))
+}
+
+// newEmptyTestParser creates a Parser with an empty clone directory.
+func newEmptyTestParser(t *testing.T, opts ...Option) *Parser {
+	cloneDir, err := ioutil.TempDir(testDir, "parser-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Parser{
+		cloneDir:  cloneDir,
+		repoName:  types.DefaultRepo,
+		repoOwner: types.DefaultRepoOwner,
+		cloneLock: newDirLock(cloneDir),
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// newTestParser creates a Parser whose clone directory holds a git repository
+// with a single votes flush commit.
+func newTestParser(t *testing.T, opts ...Option) *Parser {
+	p := newEmptyTestParser(t, opts...)
+	initTestRepo(t, filepath.Join(p.cloneDir, cloneRepoAlias))
+	return p
+}
+
+// TestConcurrentQueries tests that queries run concurrently against the pinned
+// snapshot and that commits made after it are only visible once a new
+// snapshot is pinned.
+func TestConcurrentQueries(t *testing.T) {
+	p := newTestParser(t)
+	if err := p.pinSnapshot(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	repoDir := filepath.Join(p.cloneDir, cloneRepoAlias)
+	commitVotes(t, repoDir, testToken, "Mon Nov 5 18:58:13 2018 +0000",
+		testVote(testToken, strings.Repeat("c", 64), "2"))
+
+	// Hold the read lock to confirm that the queries do not need exclusive
+	// access to the Parser.
+	p.RLock()
+	defer p.RUnlock()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			var h []*types.History
+			var err error
+			if i%2 == 0 {
+				h, err = p.ProposalHistory(testToken)
+			} else {
+				h, err = p.ProposalsHistory()
+			}
+
+			switch {
+			case err != nil:
+				errs <- err
+			case len(h) != 1 || len(h[0].Patch) != 1 ||
+				len(h[0].Patch[0].VotesInfo) != 2:
+				errs <- fmt.Errorf("expected the pinned snapshot history but found %d commits", len(h))
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+// TestPinSnapshot tests that a newly pinned snapshot exposes the latest commits.
+func TestPinSnapshot(t *testing.T) {
+	p := newTestParser(t)
+	if err := p.pinSnapshot(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	oldSHA := p.HeadSHA()
+
+	repoDir := filepath.Join(p.cloneDir, cloneRepoAlias)
+	commitVotes(t, repoDir, testToken, "Mon Nov 5 18:58:13 2018 +0000",
+		testVote(testToken, strings.Repeat("c", 64), "2"))
+
+	if err := p.pinSnapshot(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if p.HeadSHA() == oldSHA {
+		t.Fatalf("expected the snapshot to be updated but it wasn't")
+	}
+
+	h, err := p.ProposalHistory(testToken)
+	if err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if len(h) != 2 {
+		t.Fatalf("expected to find 2 commits but found %d", len(h))
+	}
+}
+
+// TestSlowWalk tests that a stalled walk holds up neither the updates nor the
+// queries and keeps walking the snapshot pinned when it started.
+func TestSlowWalk(t *testing.T) {
+	p := newTestParser(t)
+	if err := p.pinSnapshot(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	repoDir := filepath.Join(p.cloneDir, cloneRepoAlias)
+	started, release := make(chan struct{}), make(chan struct{})
+	walked := make(chan int)
+
+	go func() {
+		var count int
+		p.WalkProposalsHistory(time.Time{}, func(h *types.History) error {
+			if count == 0 {
+				close(started)
+			}
+			<-release
+			count++
+			return nil
+		})
+		walked <- count
+	}()
+	<-started
+
+	commitVotes(t, repoDir, testToken, "Mon Nov 5 18:58:13 2018 +0000",
+		testVote(testToken, strings.Repeat("c", 64), "2"))
+
+	pinned := make(chan error)
+	go func() { pinned <- p.pinSnapshot() }()
+
+	select {
+	case err := <-pinned:
+		if err != nil {
+			t.Fatalf("expected no error but found: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the snapshot to be pinned while the walk is stalled")
+	}
+
+	if h, err := p.ProposalsHistory(); err != nil || len(h) != 2 {
+		t.Fatalf("expected the 2 commits of the new snapshot but found %d: %v", len(h), err)
+	}
+
+	close(release)
+	if count := <-walked; count != 1 {
+		t.Fatalf("expected the 1 commit of the previous snapshot but found %d", count)
+	}
+}
+
+// TestCloneDirLock tests the Parser behavior when the clone directory lock is
+// held by another process.
+func TestCloneDirLock(t *testing.T) {
+	p := newTestParser(t)
+
+	// holder mimics another process holding the clone directory lock.
+	holder := newDirLock(p.cloneDir)
+	if isLocked, err := holder.lock(false); !isLocked || err != nil {
+		t.Fatalf("expected the lock to be acquired but it wasn't: %v", err)
+	}
+
+	p.lockBehavior = LockFail
+	if err := p.updateEnv(); err != ErrCloneDirLocked {
+		t.Fatalf("expected error %v but found: %v", ErrCloneDirLocked, err)
+	}
+
+	p.lockBehavior = LockReadOnly
+	if err := p.updateEnv(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if p.HeadSHA() == "" {
+		t.Fatalf("expected the snapshot to be pinned but it wasn't")
+	}
+
+	p.lockBehavior = LockWait
+	isLocked := make(chan bool)
+	go func() {
+		ok, _ := p.lockCloneDir()
+		isLocked <- ok
+	}()
+
+	select {
+	case <-isLocked:
+		t.Fatalf("expected the lock to be held by another process")
+	case <-time.After(2 * lockRetryInterval):
+	}
+
+	holder.unlock()
+
+	if !<-isLocked {
+		t.Fatalf("expected the lock to be acquired after it was released")
+	}
+
+	p.cloneLock.unlock()
+}
+
+// TestRecordJSONLayout tests that the record JSON layout is detected and its
+// votes parsed.
+func TestRecordJSONLayout(t *testing.T) {
+	p := newEmptyTestParser(t)
+
+	repoDir := filepath.Join(p.cloneDir, cloneRepoAlias)
+	votesFile := filepath.Join(repoDir, "0f1e2d3c4b5a6978", "plugins", "ticketvote", "votes.json")
+	if err := os.MkdirAll(filepath.Dir(votesFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	vote := `{"token":"0f1e2d3c4b5a6978","ticket":"aa","votebit":"2"}` + "\n"
+	if err := ioutil.WriteFile(votesFile, []byte(vote), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	runGit(t, repoDir, "init", "-q")
+	runGit(t, repoDir, "add", "-A")
+	runGit(t, repoDir, "commit", "-q", "-m", "Add votes")
+
+	if err := p.pinSnapshot(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if p.Layout() != (types.RecordJSONLayout{}).Name() {
+		t.Fatalf("expected the record JSON layout to be detected but found %s", p.Layout())
+	}
+
+	h, err := p.ProposalHistory("0f1e2d3c4b5a6978")
+	if err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if len(h) != 1 || h[0].Patch[0].VotesInfo[0].VoteBit != "Yes" {
+		t.Fatalf("expected to find a single Yes vote but it wasn't found")
+	}
+}
+
+// TestLayoutMigration tests that the layout is detected again once the
+// repository migrates to a different layout.
+func TestLayoutMigration(t *testing.T) {
+	p := newTestParser(t)
+	if err := p.pinSnapshot(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if p.Layout() != (types.GitJournalLayout{}).Name() {
+		t.Fatalf("expected the git journal layout to be detected but found %s", p.Layout())
+	}
+
+	repoDir := filepath.Join(p.cloneDir, cloneRepoAlias)
+	votesFile := filepath.Join(repoDir, "0f1e2d3c4b5a6978", "plugins", "ticketvote", "votes.json")
+	if err := os.MkdirAll(filepath.Dir(votesFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	vote := `{"token":"0f1e2d3c4b5a6978","ticket":"aa","votebit":"2"}` + "\n"
+	if err := ioutil.WriteFile(votesFile, []byte(vote), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	runGit(t, repoDir, "rm", "-q", "-r", testToken)
+	runGit(t, repoDir, "add", "-A")
+	runGit(t, repoDir, "commit", "-q", "-m", "Migrate the records")
+
+	if err := p.pinSnapshot(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if p.Layout() != (types.RecordJSONLayout{}).Name() {
+		t.Fatalf("expected the record JSON layout to be detected but found %s", p.Layout())
+	}
+
+	h, err := p.ProposalHistory("0f1e2d3c4b5a6978")
+	if err != nil || len(h) != 1 {
+		t.Fatalf("expected the migrated votes to be found but found %d: %v", len(h), err)
+	}
+}
+
+// TestCastTimeEstimates tests that the votes cast time range starts at the
+// previous flush of the same token votes and that the previous flushes of all
+// the tokens are looked up by a single git log run.
+func TestCastTimeEstimates(t *testing.T) {
+	m := &testMetrics{commands: map[string]int{}, queries: map[string]int{}}
+	p := newTestParser(t, WithMetrics(m))
+
+	// The votes of other proposals are flushed in between.
+	repoDir := filepath.Join(p.cloneDir, cloneRepoAlias)
+	for i := 0; i < 3; i++ {
+		token := strings.Repeat(strconv.Itoa(i), 64)
+		commitVotes(t, repoDir, token, "Mon Nov 5 18:00:13 2018 +0000",
+			testVote(token, strings.Repeat("e", 64), "1"))
+	}
+
+	if err := p.pinSnapshot(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	updates, cancel := p.Subscribe(1)
+	defer cancel()
+
+	commitVotes(t, repoDir, testToken, "Mon Nov 5 18:58:13 2018 +0000",
+		testVote(testToken, strings.Repeat("c", 64), "2"))
+
+	if err := p.pinSnapshot(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	first, _ := time.Parse(types.CmdDateFormat, "Mon Nov 5 17:58:13 2018 +0000")
+	second := first.Add(time.Hour)
+
+	update := nextUpdate(t, updates)
+
+	for i, since := range []time.Time{{}, first, first.Add(time.Minute), {}} {
+		m.commands = map[string]int{}
+
+		var h []*types.History
+		var err error
+		switch i {
+		case 3:
+			h = update.History
+		default:
+			h, err = p.ProposalsHistorySince(since)
+		}
+		if err != nil {
+			t.Fatalf("expected no error but found: %v", err)
+		}
+
+		if m.commands[listCommitsArg] > 2 {
+			t.Fatalf("expected at most 2 git log runs but found %d", m.commands[listCommitsArg])
+		}
+
+		vote := h[len(h)-1].Patch[0].VotesInfo[0]
+		if !vote.FlushedAt.Equal(second) {
+			t.Fatalf("expected the vote to be flushed at %v but found %v", second, vote.FlushedAt)
+		}
+
+		r := vote.EstimatedCastAt
+		if !r.Earliest.Equal(first) || !r.Latest.Equal(second) ||
+			!r.Estimate.Equal(first.Add(30*time.Minute)) {
+			t.Fatalf("expected the vote to be cast between %v and %v but found %v",
+				first, second, r)
+		}
+	}
+}
+
+// TestRenamedJournal tests that only the votes added to a ballot journal moved
+// to a new version directory are returned.
+func TestRenamedJournal(t *testing.T) {
+	p := newTestParser(t)
+
+	repoDir := filepath.Join(p.cloneDir, cloneRepoAlias)
+	if err := os.MkdirAll(filepath.Join(repoDir, testToken, "2", "plugins"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	runGit(t, repoDir, "mv", filepath.Join(testToken, "1", "plugins", "decred"),
+		filepath.Join(testToken, "2", "plugins", "decred"))
+
+	journal := filepath.Join(repoDir, testToken, "2", "plugins", "decred", "ballot.journal")
+	f, err := os.OpenFile(journal, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(testVote(testToken, strings.Repeat("c", 64), "2"))
+	f.Close()
+
+	runGit(t, repoDir, "add", "-A")
+	runGit(t, repoDir, "commit", "-q", "-m", types.DefaultVotesCommitMsg)
+
+	h, err := p.ProposalHistory(testToken)
+	if err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if len(h) != 2 {
+		t.Fatalf("expected to find 2 commits but found %d", len(h))
+	}
+
+	file := h[1].Patch[0]
+	if file.Change != types.FileRenamed || len(file.VotesInfo) != 1 {
+		t.Fatalf("expected a single vote in the renamed journal but found %d %s votes",
+			len(file.VotesInfo), file.Change)
+	}
+}
+
+func TestVersionFilter(t *testing.T) {
+	p := newTestParser(t)
+
+	repoDir := filepath.Join(p.cloneDir, cloneRepoAlias)
+	journal := filepath.Join(repoDir, testToken, "2", "plugins", "decred", "ballot.journal")
+	if err := os.MkdirAll(filepath.Dir(journal), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(journal, []byte(testVote(testToken,
+		strings.Repeat("d", 64), "1")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	runGit(t, repoDir, "add", "-A")
+	runGit(t, repoDir, "commit", "-q", "-m", types.DefaultVotesCommitMsg)
+
+	td := []struct {
+		version string
+		commits int
+	}{
+		{"", 2},
+		{"1", 1},
+		{"2", 1},
+		{"3", 0},
+	}
+
+	for i, val := range td {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			h, err := p.ProposalHistory(testToken, VersionFilter(val.version))
+			if err != nil {
+				t.Fatalf("expected no error but found: %v", err)
+			}
+
+			if len(h) != val.commits {
+				t.Fatalf("expected to find %d commits but found %d", val.commits, len(h))
+			}
+
+			for _, item := range h {
+				for _, f := range item.Patch {
+					if val.version != "" && f.Version != val.version {
+						t.Fatalf("expected only version %s votes but found version %s",
+							val.version, f.Version)
+					}
+
+					if f.JournalType != "ballot" {
+						t.Fatalf("expected a ballot journal but found %s", f.JournalType)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestWalkProposalsHistory(t *testing.T) {
+	p := newTestParser(t)
+
+	repoDir := filepath.Join(p.cloneDir, cloneRepoAlias)
+	commitVotes(t, repoDir, testToken, "Mon Nov 5 18:58:13 2018 +0000",
+		testVote(testToken, strings.Repeat("c", 64), "2"))
+
+	var walked []*types.History
+	err := p.WalkProposalsHistory(time.Time{}, func(h *types.History) error {
+		walked = append(walked, h)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	h, err := p.ProposalsHistory()
+	if err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if len(walked) != len(h) || len(walked) != 2 {
+		t.Fatalf("expected to walk %d commits but found %d", len(h), len(walked))
+	}
+
+	stopErr := fmt.Errorf("stop walking")
+	count := 0
+	err = p.WalkProposalHistory(testToken, time.Time{}, func(h *types.History) error {
+		count++
+		return stopErr
+	})
+	if err == nil || !strings.Contains(err.Error(), stopErr.Error()) || count != 1 {
+		t.Fatalf("expected the walk to stop after the first commit but found %d: %v",
+			count, err)
+	}
+}
+
+// TestQueryFilters tests that the until and ticket filters narrow down the
+// history returned, and that the commits made after the until date are not
+// parsed at all.
+func TestQueryFilters(t *testing.T) {
+	m := &testMetrics{commands: map[string]int{}, queries: map[string]int{}}
+	p := newTestParser(t, WithMetrics(m))
+
+	repoDir := filepath.Join(p.cloneDir, cloneRepoAlias)
+	commitVotes(t, repoDir, testToken, "Mon Nov 5 18:58:13 2018 +0000",
+		testVote(testToken, strings.Repeat("c", 64), "2"))
+
+	until := time.Date(2018, 11, 5, 18, 0, 0, 0, time.UTC)
+
+	td := []struct {
+		filters []Filter
+		commits int
+		votes   int
+		parsed  int
+	}{
+		{nil, 2, 3, 2},
+		{[]Filter{UntilFilter(until)}, 1, 2, 1},
+		{[]Filter{TicketFilter(strings.Repeat("b", 64))}, 1, 1, 2},
+		{[]Filter{TicketFilter(strings.Repeat("c", 64)), UntilFilter(until)}, 0, 0, 1},
+	}
+
+	for i, val := range td {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			m.commits = 0
+			h, err := p.ProposalsHistory(val.filters...)
+			if err != nil {
+				t.Fatalf("expected no error but found: %v", err)
+			}
+
+			votes := 0
+			for _, item := range h {
+				for _, f := range item.Patch {
+					votes += len(f.VotesInfo)
+				}
+			}
+
+			if len(h) != val.commits || votes != val.votes {
+				t.Fatalf("expected %d commits and %d votes but found %d and %d",
+					val.commits, val.votes, len(h), votes)
+			}
+
+			if m.commits != val.parsed {
+				t.Fatalf("expected %d commits parsed but found %d", val.parsed, m.commits)
+			}
+		})
+	}
+}
+
+// TestProposalTokens tests that the tokens of the proposals in the snapshot are
+// listed.
+func TestProposalTokens(t *testing.T) {
+	p := newTestParser(t)
+
+	repoDir := filepath.Join(p.cloneDir, cloneRepoAlias)
+	if err := os.MkdirAll(filepath.Join(repoDir, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(repoDir, "docs", "README"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	runGit(t, repoDir, "add", "-A")
+	runGit(t, repoDir, "commit", "-q", "-m", "Add docs")
+
+	tokens, err := p.ProposalTokens()
+	if err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if len(tokens) != 1 || tokens[0] != testToken {
+		t.Fatalf("expected to find token %s but found %v", testToken, tokens)
+	}
+}
+
+// TestOfflineUpdate tests that the offline mode only pins the local clone.
+func TestOfflineUpdate(t *testing.T) {
+	p := newEmptyTestParser(t, WithOffline(), WithRemoteURL("/missing/origin"))
+	if err := p.updateEnv(); err == nil {
+		t.Fatalf("expected a missing clone error but found none")
+	}
+
+	initTestRepo(t, filepath.Join(p.cloneDir, cloneRepoAlias))
+	if err := p.updateEnv(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if p.HeadSHA() == "" {
+		t.Fatalf("expected the local clone HEAD to be pinned")
+	}
+}
+
+// TestSubscribe tests that the subscribers receive the history of the commits
+// made since the previous snapshot.
+func TestSubscribe(t *testing.T) {
+	p := newTestParser(t)
+	if err := p.pinSnapshot(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	updates, cancel := p.Subscribe(1)
+	previousSHA := p.HeadSHA()
+
+	repoDir := filepath.Join(p.cloneDir, cloneRepoAlias)
+	commitVotes(t, repoDir, testToken, "Mon Nov 5 18:58:13 2018 +0000",
+		testVote(testToken, strings.Repeat("c", 64), "2"))
+
+	if err := p.pinSnapshot(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	select {
+	case u := <-updates:
+		if u.PreviousSHA != previousSHA || u.HeadSHA != p.HeadSHA() {
+			t.Fatalf("expected an update from %s to %s but found %s to %s",
+				previousSHA, p.HeadSHA(), u.PreviousSHA, u.HeadSHA)
+		}
+
+		if len(u.History) != 1 || len(u.History[0].Patch[0].VotesInfo) != 1 {
+			t.Fatalf("expected a single new vote but found %d commits", len(u.History))
+		}
+
+	default:
+		t.Fatalf("expected an update but found none")
+	}
+
+	cancel()
+	if _, ok := <-updates; ok {
+		t.Fatalf("expected the updates channel to be closed")
+	}
+}
+
+// testMetrics records the Parser activity reported to it.
+type testMetrics struct {
+	sync.Mutex
+	updates  int
+	commands map[string]int
+	queries  map[string]int
+	commits  int
+	votes    int
+}
+
+func (m *testMetrics) ObserveUpdate(_ time.Duration, _ error) {
+	m.Lock()
+	m.updates++
+	m.Unlock()
+}
+
+func (m *testMetrics) ObserveGitCommand(subcommand string, _ time.Duration, _ error) {
+	m.Lock()
+	m.commands[subcommand]++
+	m.Unlock()
+}
+
+func (m *testMetrics) ObserveQuery(method string, _ time.Duration, _ error) {
+	m.Lock()
+	m.queries[method]++
+	m.Unlock()
+}
+
+func (m *testMetrics) ObserveParsed(votes, _ int) {
+	m.Lock()
+	m.commits++
+	m.votes += votes
+	m.Unlock()
+}
+
+// TestMetrics tests that the Parser activity is reported to the set Metrics.
+func TestMetrics(t *testing.T) {
+	m := &testMetrics{commands: map[string]int{}, queries: map[string]int{}}
+	p := newTestParser(t, WithOffline(), WithMetrics(m))
+
+	if err := p.updateEnv(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if p.HeadCommitTime().IsZero() {
+		t.Fatalf("expected the HEAD commit time to be set")
+	}
+
+	if _, err := p.ProposalHistory(testToken); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if m.updates != 1 {
+		t.Fatalf("expected 1 update but found %d", m.updates)
+	}
+
+	if m.commands[revParseArg] == 0 || m.commands[listCommitsArg] == 0 {
+		t.Fatalf("expected the git rev-parse and log commands but found %v", m.commands)
+	}
+
+	if m.queries["ProposalHistory"] != 1 {
+		t.Fatalf("expected 1 ProposalHistory query but found %v", m.queries)
+	}
+
+	if m.commits != 1 || m.votes != 2 {
+		t.Fatalf("expected 1 commit with 2 votes but found %d commits with %d votes",
+			m.commits, m.votes)
+	}
+}
+
+// TestStatus tests that the status reflects the result of the updates and that
+// the Parser is only ready after the first successful update.
+func TestStatus(t *testing.T) {
+	p := newEmptyTestParser(t, WithOffline())
+
+	s := p.Status()
+	if s.Ready() || s.Cloned || !s.LastUpdateAttempt.IsZero() {
+		t.Fatalf("expected a fresh Parser status but found %+v", s)
+	}
+
+	if err := p.updateEnv(); err == nil {
+		t.Fatalf("expected a missing clone error but found none")
+	}
+
+	s = p.Status()
+	if s.Ready() || s.LastUpdateAttempt.IsZero() || s.LastUpdateError == "" {
+		t.Fatalf("expected a failed update status but found %+v", s)
+	}
+
+	initTestRepo(t, filepath.Join(p.cloneDir, cloneRepoAlias))
+	if err := p.updateEnv(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	s = p.Status()
+	if !s.Ready() || !s.Cloned || s.LastUpdateError != "" {
+		t.Fatalf("expected a ready status but found %+v", s)
+	}
+
+	if s.HeadSHA != p.HeadSHA() || s.HeadCommitTime.IsZero() || !s.Offline {
+		t.Fatalf("expected the pinned snapshot status but found %+v", s)
+	}
+
+	if s.Layout != (types.GitJournalLayout{}).Name() || s.Shallow {
+		t.Fatalf("expected a complete gitjournal clone but found %+v", s)
+	}
+}
+
+// TestRequestUpdate tests that the requested updates are fetched immediately
+// without waiting for the poll interval.
+func TestRequestUpdate(t *testing.T) {
+	p := newTestParser(t, WithOffline(), WithPollInterval(time.Hour))
+	if err := p.pinSnapshot(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	// Requests made before the background updates loop is started are ignored.
+	p.RequestUpdate()
+
+	p.updateReq = make(chan struct{}, 1)
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go p.pollUpdates(stop)
+
+	updates, cancel := p.Subscribe(1)
+	defer cancel()
+
+	repoDir := filepath.Join(p.cloneDir, cloneRepoAlias)
+	commitVotes(t, repoDir, testToken, "Mon Nov 5 18:58:13 2018 +0000",
+		testVote(testToken, strings.Repeat("c", 64), "2"))
+
+	p.RequestUpdate()
+	p.RequestUpdate()
+
+	select {
+	case u := <-updates:
+		if len(u.History) != 1 {
+			t.Fatalf("expected a single new commit but found %d", len(u.History))
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the requested update but found none")
+	}
+}
+
+// waitStatus polls the Parser status until the check passes.
+func waitStatus(t *testing.T, p *Parser, check func(s *Status) bool) *Status {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s := p.Status()
+		if check(s) {
+			return s
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the status check to pass but found %+v", s)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestBackgroundSync tests that the first update is made in the background
+// and retried until it succeeds, and that the queries fail until then.
+func TestBackgroundSync(t *testing.T) {
+	origin := newTestOrigin(t)
+	p := newEmptyTestParser(t, WithRemoteURL(origin), WithRetries(0, 0),
+		WithBackgroundSync(), WithPollInterval(time.Hour))
+
+	// Make the remote repository unreachable.
+	if err := os.Rename(origin, origin+"-moved"); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	p.startUpdates(stop, true)
+
+	s := waitStatus(t, p, func(s *Status) bool { return !s.LastUpdateAttempt.IsZero() })
+	if s.Ready() || s.LastUpdateError == "" {
+		t.Fatalf("expected the failed first update not to be ready but found %+v", s)
+	}
+
+	if _, err := p.ProposalsHistory(); err != ErrNotSynced {
+		t.Fatalf("expected error %v but found: %v", ErrNotSynced, err)
+	}
+
+	if err := os.Rename(origin+"-moved", origin); err != nil {
+		t.Fatal(err)
+	}
+
+	p.RequestUpdate()
+	waitStatus(t, p, func(s *Status) bool { return s.Ready() })
+
+	if h, err := p.ProposalsHistory(); err != nil || len(h) != 1 {
+		t.Fatalf("expected the synced history but found %d commits: %v", len(h), err)
+	}
+}
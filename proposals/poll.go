@@ -0,0 +1,83 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package proposals
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// defaultPollInterval is the default interval at which the updates are
+// fetched in the background.
+const defaultPollInterval = 5 * time.Minute
+
+// RequestUpdate asks the background updates loop to fetch the updates
+// immediately e.g. when a push webhook is received. It does not block: the
+// requests made while an update is pending are merged into it. The poll
+// interval restarts after each update. It does nothing in the offline mode.
+func (p *Parser) RequestUpdate() {
+	if p.updateReq == nil {
+		return
+	}
+
+	select {
+	case p.updateReq <- struct{}{}:
+	default:
+	}
+}
+
+// startUpdates starts the background updates loop until the stop channel is
+// closed. If syncNow is set, the first update is made immediately.
+func (p *Parser) startUpdates(stop <-chan struct{}, syncNow bool) {
+	p.updateReq = make(chan struct{}, 1)
+	if syncNow {
+		p.updateReq <- struct{}{}
+	}
+
+	go p.pollUpdates(stop)
+}
+
+// pollUpdates fetches the updates at the scheduled dates and whenever an update
+// is requested until the stop channel is closed. If UpdateSignal() was
+// invoked, the client is signalled after every successful update.
+func (p *Parser) pollUpdates(stop <-chan struct{}) {
+	timer := time.NewTimer(p.scheduleNext(true))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+
+		case <-timer.C:
+
+		case <-p.updateReq:
+			p.log().Debug("update requested")
+			if !timer.Stop() {
+				<-timer.C
+			}
+		}
+
+		previousSHA := p.HeadSHA()
+		err := p.updateEnv()
+		timer.Reset(p.scheduleNext(err == nil && p.HeadSHA() != previousSHA))
+
+		if err != nil {
+			p.log().Error("updateEnv failed", "error", err)
+			continue
+		}
+
+		// If UpdateSignal() was invoked, the trigger flag must have
+		// been set, an indication that the client wants to fetch updates
+		// after the tool retrieves them.
+		if atomic.LoadInt32(&(p.triggerFlag)) == updateFlag {
+			// Attempt to send updates signal if the channel isn't blocked
+			// otherwise ignore it till next interval.
+			select {
+			case triggerChan <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
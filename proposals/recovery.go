@@ -0,0 +1,203 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package proposals
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultMaxRetries is the default number of times a failed git network
+	// command is retried.
+	defaultMaxRetries = 3
+
+	// defaultRetryBackoff is the default delay before the first retry.
+	defaultRetryBackoff = 2 * time.Second
+
+	// tmpCloneSuffix is appended to the repo alias to name the temporary
+	// directories that fresh clones are made into.
+	tmpCloneSuffix = "-tmp-"
+
+	// oldCloneSuffix is appended to the repo alias to name the directory that
+	// the replaced clone is moved into. It is only deleted by the next update
+	// made while holding the clone directory lock.
+	oldCloneSuffix = "-old-"
+)
+
+// recoverUpdates fetches the remote HEAD and hard resets the current branch to
+// it, retrying with an exponential backoff on failure. This
+// discards any local changes without dropping the repository. If all the
+// retries fail, the repository integrity is checked. Only a corrupted
+// repository is replaced with a fresh clone otherwise the error is returned
+// and the queries keep running against the previous snapshot.
+func (p *Parser) recoverUpdates(workingDir, completeRemoteURL string) error {
+	err := p.retry(func() error {
+		if err := p.execCommand(gitCmd, fetchArg, remoteURLRef, headRef); err != nil {
+			return err
+		}
+		return p.execCommand(gitCmd, resetArg, hardResetArg, fetchHeadRef)
+	})
+	if err == nil {
+		p.log().Info("pulled the updates", "url", completeRemoteURL)
+		return p.pinSnapshot()
+	}
+
+	p.log().Warn("pulling the updates failed", "url", completeRemoteURL,
+		"error", err)
+
+	if p.isRepoIntact(workingDir) {
+		return fmt.Errorf("fetching updates from %s failed: %v",
+			completeRemoteURL, err)
+	}
+
+	p.log().Warn("the repository is corrupted: recloning it", "dir", workingDir)
+
+	if err = p.recloneRepo(workingDir, completeRemoteURL); err != nil {
+		return err
+	}
+
+	return p.pinSnapshot()
+}
+
+// retry runs the provided function until it succeeds or the maximum number of
+// retries is exhausted. The delay between the attempts doubles every time.
+func (p *Parser) retry(fn func() error) (err error) {
+	backoff := p.retryBackoff
+	for i := 0; ; i++ {
+		if err = fn(); err == nil || i >= p.maxRetries {
+			return
+		}
+
+		p.log().Debug("retrying the failed git command", "attempt", i+1,
+			"backoff", backoff, "error", err)
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// isRepoIntact returns true if the repository in the provided directory passes
+// the git integrity checks.
+func (p *Parser) isRepoIntact(dir string) bool {
+	_, err := p.readCommandOutputIn(dir, gitCmd, fsckArg)
+	return err == nil
+}
+
+// recloneRepo clones the remote repository into a temporary directory in the
+// clone directory. The clone is verified and then swapped in as the working
+// directory. The old working directory is only dropped after the swap thus a
+// failed clone leaves it untouched.
+func (p *Parser) recloneRepo(workingDir, completeRemoteURL string) error {
+	tmpDir, err := ioutil.TempDir(p.cloneDir, cloneRepoAlias+tmpCloneSuffix)
+	if err != nil {
+		return fmt.Errorf("failed to create a temp cloning dir: %v", err)
+	}
+
+	// Drops the temporary directory if the swap never happens.
+	defer os.RemoveAll(tmpDir)
+
+	start := time.Now()
+	p.log().Info("cloning the repository", "url", completeRemoteURL, "dir", tmpDir)
+
+	err = p.retry(func() error {
+		args := append([]string{cloneArg}, p.shallowArgs()...)
+		args = append(args, completeRemoteURL, tmpDir)
+
+		_, err := p.readCommandOutputIn(p.cloneDir, gitCmd, args...)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to clone %s : %v", completeRemoteURL, err)
+	}
+
+	if !p.isRepoIntact(tmpDir) {
+		return fmt.Errorf("the clone of %s failed the integrity checks",
+			completeRemoteURL)
+	}
+
+	p.log().Info("cloned the repository", "url", completeRemoteURL,
+		"duration", time.Since(start))
+
+	return p.swapWorkingDir(workingDir, tmpDir)
+}
+
+// swapWorkingDir replaces the working directory with the provided directory.
+// Queries are blocked only during the swap. The replaced directory is moved
+// aside rather than deleted since the processes that do not hold the clone
+// directory lock e.g. with LockReadOnly set, may still be reading it. It is
+// deleted by the next update, see dropReplacedClones.
+func (p *Parser) swapWorkingDir(workingDir, newDir string) error {
+	oldDir := workingDir + oldCloneSuffix + strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	_, err := os.Stat(workingDir)
+	hasOld := !os.IsNotExist(err)
+
+	// The verified snapshot stays pinned until the new clone HEAD is verified
+	// and pinned in turn. The queries fail meanwhile if the new clone misses
+	// it, rather than reading the unverified HEAD.
+	p.RLock()
+	pinnedSHA := p.headSHA
+	p.RUnlock()
+
+	if pinnedSHA != "" {
+		_, err = p.readCommandOutputIn(newDir, gitCmd, revParseArg, verifyRevArg,
+			pinnedSHA+commitRevSuffix)
+		if err != nil {
+			p.log().Warn("the pinned snapshot is missing in the new clone: "+
+				"the queries fail until a new snapshot is pinned", "head_sha", pinnedSHA)
+		}
+	}
+
+	p.Lock()
+	err = swapDirs(workingDir, newDir, oldDir, hasOld)
+	p.Unlock()
+
+	if err == nil && hasOld {
+		p.log().Debug("moved the replaced clone aside", "dir", oldDir)
+	}
+
+	return err
+}
+
+// dropReplacedClones deletes the working directories replaced by the previous
+// updates. The clone directory lock must be held by the caller. The commands
+// still reading a replaced directory when it was moved aside have had a whole
+// update interval to complete.
+func (p *Parser) dropReplacedClones() {
+	dirs, err := filepath.Glob(filepath.Join(p.cloneDir, cloneRepoAlias+oldCloneSuffix+"*"))
+	if err != nil {
+		return
+	}
+
+	for _, dir := range dirs {
+		if err = os.RemoveAll(dir); err != nil {
+			p.log().Warn("deleting the replaced clone failed", "dir", dir,
+				"error", err)
+		}
+	}
+}
+
+// swapDirs moves the dir into oldDir if hasOld is true and then moves the
+// newDir into dir. On failure, the original dir is restored.
+func swapDirs(dir, newDir, oldDir string, hasOld bool) error {
+	if hasOld {
+		if err := os.Rename(dir, oldDir); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(newDir, dir); err != nil {
+		if hasOld {
+			os.Rename(oldDir, dir)
+		}
+		return err
+	}
+
+	return nil
+}
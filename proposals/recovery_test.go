@@ -0,0 +1,151 @@
+package proposals
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestOrigin creates a repository that acts as the remote repository.
+func newTestOrigin(t *testing.T) string {
+	dir, err := ioutil.TempDir(testDir, "origin-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	initTestRepo(t, dir)
+	return dir
+}
+
+// TestRecoverUpdates tests that updates are fetched without dropping the working
+// directory and that local changes are discarded.
+func TestRecoverUpdates(t *testing.T) {
+	origin := newTestOrigin(t)
+	p := newEmptyTestParser(t, WithRemoteURL(origin), WithRetries(0, 0))
+
+	if err := p.updateEnv(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	workingDir := filepath.Join(p.cloneDir, cloneRepoAlias)
+	if p.HeadSHA() != runGit(t, origin, "rev-parse", "HEAD") {
+		t.Fatalf("expected the snapshot to match the origin HEAD but it didn't")
+	}
+
+	// marker confirms that the working directory is not replaced.
+	marker := filepath.Join(workingDir, ".git", "marker")
+	if err := ioutil.WriteFile(marker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	commitVotes(t, origin, testToken, "Mon Nov 5 18:58:13 2018 +0000",
+		testVote(testToken, strings.Repeat("c", 64), "2"))
+
+	// Diverge the local repository from the remote one.
+	commitVotes(t, workingDir, testToken, "Mon Nov 5 18:58:13 2018 +0000",
+		testVote(testToken, strings.Repeat("d", 64), "1"))
+
+	if err := p.updateEnv(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if p.HeadSHA() != runGit(t, origin, "rev-parse", "HEAD") {
+		t.Fatalf("expected the snapshot to match the origin HEAD but it didn't")
+	}
+
+	if _, err := os.Stat(marker); err != nil {
+		t.Fatalf("expected the working directory to be kept but it wasn't: %v", err)
+	}
+}
+
+// TestRecoverUpdatesFailure tests that a failed fetch leaves the working
+// directory and the pinned snapshot untouched.
+func TestRecoverUpdatesFailure(t *testing.T) {
+	origin := newTestOrigin(t)
+	p := newEmptyTestParser(t, WithRemoteURL(origin), WithRetries(2, time.Millisecond))
+
+	if err := p.updateEnv(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	sha := p.HeadSHA()
+
+	// Make the remote repository unreachable.
+	if err := os.Rename(origin, origin+"-moved"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.updateEnv(); err == nil {
+		t.Fatalf("expected an error but none was returned")
+	}
+
+	if p.HeadSHA() != sha {
+		t.Fatalf("expected the snapshot %s to be kept but found %s", sha, p.HeadSHA())
+	}
+
+	if h, err := p.ProposalsHistory(); err != nil || len(h) != 1 {
+		t.Fatalf("expected the queries to still work but found: %v", err)
+	}
+}
+
+// TestRecloneRepo tests that tracking a different remote repository swaps in
+// a fresh clone of it.
+func TestRecloneRepo(t *testing.T) {
+	p := newTestParser(t, WithRetries(0, 0))
+	if err := p.pinSnapshot(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	origin := newTestOrigin(t)
+	commitVotes(t, origin, testToken, "Mon Nov 5 18:58:13 2018 +0000",
+		testVote(testToken, strings.Repeat("c", 64), "2"))
+
+	p.repoURL = origin
+	if err := p.updateEnv(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if p.HeadSHA() != runGit(t, origin, "rev-parse", "HEAD") {
+		t.Fatalf("expected the snapshot to match the origin HEAD but it didn't")
+	}
+
+	// The replaced clone is kept for the readers in other processes.
+	oldDirs := replacedClones(t, p.cloneDir)
+	if len(oldDirs) != 1 {
+		t.Fatalf("expected the replaced clone to be kept but found %v", oldDirs)
+	}
+	runGit(t, filepath.Join(p.cloneDir, oldDirs[0]), "log", "-1")
+
+	// The next update drops it.
+	if err := p.updateEnv(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if oldDirs = replacedClones(t, p.cloneDir); len(oldDirs) != 0 {
+		t.Fatalf("expected %v to have been dropped", oldDirs)
+	}
+}
+
+// replacedClones returns the names of the replaced clones in the clone
+// directory. It fails the test if a temporary clone is left behind.
+func replacedClones(t *testing.T, cloneDir string) []string {
+	entries, err := ioutil.ReadDir(cloneDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dirs []string
+	for _, e := range entries {
+		if strings.Contains(e.Name(), tmpCloneSuffix) {
+			t.Fatalf("expected %s to have been dropped", e.Name())
+		}
+
+		if strings.Contains(e.Name(), oldCloneSuffix) {
+			dirs = append(dirs, e.Name())
+		}
+	}
+	return dirs
+}
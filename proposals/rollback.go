@@ -0,0 +1,76 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package proposals
+
+import (
+	"strings"
+
+	"github.com/dmigwi/go-piparser/proposals/types"
+)
+
+const (
+	// mergeBaseArg finds the most recent common ancestor of two commits.
+	mergeBaseArg = "merge-base"
+
+	// revListArg lists the commits selected by the provided revisions.
+	revListArg = "rev-list"
+
+	// verifyRevArg makes rev-parse fail if the revision cannot be resolved.
+	verifyRevArg = "--verify"
+
+	// commitRevSuffix is appended to a revision to resolve it as a commit.
+	commitRevSuffix = "^{commit}"
+)
+
+// Rollback describes the commits dropped from the history when the remote
+// repository was force-pushed. The data derived from them is stale and should
+// be retracted.
+type Rollback struct {
+	// ForkPoint is the most recent commit shared by the previous and the new
+	// history. It is empty if they share no commit.
+	ForkPoint string
+
+	// Commits lists the SHAs of the commits dropped in the order they were
+	// made.
+	Commits []string
+
+	// History holds the dropped commits with votes in the order they were
+	// made.
+	History []*types.History
+
+	// Unknown is set if the dropped commits cannot be found because the
+	// previous snapshot is no longer available locally, e.g. after the
+	// repository was recloned. All the data derived from the previous history
+	// should then be discarded.
+	Unknown bool
+}
+
+// detectRollback returns the commits dropped from the history if the previous
+// snapshot is not an ancestor of the new one. Nil is returned if the history
+// only moved forward. The dropped commits are reported as unknown if they
+// cannot be listed. Their history is not parsed.
+func (p *Parser) detectRollback(previousSHA, headSHA string) *Rollback {
+	_, err := p.readCommandOutput(gitCmd, revParseArg, verifyRevArg,
+		previousSHA+commitRevSuffix)
+	if err != nil {
+		return &Rollback{Unknown: true}
+	}
+
+	// merge-base fails if the commits share no history.
+	forkPoint, err := p.readCommandOutput(gitCmd, mergeBaseArg, previousSHA, headSHA)
+	forkPoint = strings.TrimSpace(forkPoint)
+	if err == nil && forkPoint == previousSHA {
+		return nil
+	}
+
+	list, err := p.readCommandOutput(gitCmd, revListArg, reverseOrder, previousSHA,
+		excludeRevPrefix+headSHA)
+	if err != nil {
+		p.log().Error("listing the dropped commits failed", "previous_sha",
+			previousSHA, "head_sha", headSHA, "error", err)
+		return &Rollback{ForkPoint: forkPoint, Unknown: true}
+	}
+
+	return &Rollback{ForkPoint: forkPoint, Commits: strings.Fields(list)}
+}
@@ -0,0 +1,110 @@
+package proposals
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// nextUpdate returns the update received by the subscription or fails the test
+// if there is none.
+func nextUpdate(t *testing.T, updates <-chan *Update) *Update {
+	select {
+	case u := <-updates:
+		return u
+	default:
+		t.Fatalf("expected an update but found none")
+		return nil
+	}
+}
+
+// TestRollback tests that the commits dropped by a force-push of the remote
+// repository are rolled back.
+func TestRollback(t *testing.T) {
+	origin := newTestOrigin(t)
+	p := newEmptyTestParser(t, WithRemoteURL(origin), WithRetries(0, 0))
+
+	if err := p.updateEnv(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	updates, cancel := p.Subscribe(1)
+	defer cancel()
+
+	forkPoint := p.HeadSHA()
+	commitVotes(t, origin, testToken, "Mon Nov 5 18:58:13 2018 +0000",
+		testVote(testToken, strings.Repeat("c", 64), "2"))
+	dropped := runGit(t, origin, "rev-parse", "HEAD")
+
+	if err := p.updateEnv(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if u := nextUpdate(t, updates); u.Rollback != nil {
+		t.Fatalf("expected no rollback but found %+v", u.Rollback)
+	}
+
+	// Force-push a different votes flush.
+	runGit(t, origin, "reset", "-q", "--hard", forkPoint)
+	commitVotes(t, origin, testToken, "Mon Nov 5 19:58:13 2018 +0000",
+		testVote(testToken, strings.Repeat("d", 64), "1"))
+
+	if err := p.updateEnv(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	u := nextUpdate(t, updates)
+	r := u.Rollback
+	if r == nil || r.Unknown || r.ForkPoint != forkPoint ||
+		len(r.Commits) != 1 || r.Commits[0] != dropped {
+		t.Fatalf("expected the commit %s dropped after %s but found %+v", dropped,
+			forkPoint, r)
+	}
+
+	if len(r.History) != 1 || r.History[0].Patch[0].VotesInfo[0].Ticket != strings.Repeat("c", 64) {
+		t.Fatalf("expected the dropped vote of ticket c but found %d commits", len(r.History))
+	}
+
+	if len(u.History) != 1 || u.History[0].Patch[0].VotesInfo[0].Ticket != strings.Repeat("d", 64) {
+		t.Fatalf("expected the new vote of ticket d but found %d commits", len(u.History))
+	}
+}
+
+// TestUnknownRollback tests that the complete history is published if the
+// previous snapshot is missing from a new clone.
+func TestUnknownRollback(t *testing.T) {
+	p := newTestParser(t, WithRetries(0, 0))
+	if err := p.pinSnapshot(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	updates, cancel := p.Subscribe(1)
+	defer cancel()
+
+	// A different repository shares none of the previous history.
+	origin, err := ioutil.TempDir(testDir, "origin-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runGit(t, origin, "init", "-q")
+	commitVotes(t, origin, testToken, "Mon Nov 5 16:58:13 2018 +0000",
+		testVote(testToken, strings.Repeat("c", 64), "2"))
+	commitVotes(t, origin, testToken, "Mon Nov 5 18:58:13 2018 +0000",
+		testVote(testToken, strings.Repeat("d", 64), "1"))
+
+	p.repoURL = origin
+	if err := p.updateEnv(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	u := nextUpdate(t, updates)
+	if u.Rollback == nil || !u.Rollback.Unknown {
+		t.Fatalf("expected an unknown rollback but found %+v", u.Rollback)
+	}
+
+	if len(u.History) != 2 {
+		t.Fatalf("expected the complete history of 2 commits but found %d",
+			len(u.History))
+	}
+}
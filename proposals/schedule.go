@@ -0,0 +1,218 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package proposals
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// cadenceSampleSize is the number of the most recent votes flush commits
+	// that the flush cadence is learnt from.
+	cadenceSampleSize = 48
+
+	// minCadenceSamples is the minimum number of flush commits required to
+	// learn the flush cadence.
+	minCadenceSamples = 3
+
+	// flushDelay is how long after an expected flush the update is made. It
+	// gives the flush commit time to be pushed to the remote repository.
+	flushDelay = 2 * time.Minute
+
+	// activeVoteWindow is how long after the last votes flush a vote is
+	// considered to still be active.
+	activeVoteWindow = 24 * time.Hour
+
+	// maxIdleInterval is the longest interval that the updates back off to
+	// while no vote is active.
+	maxIdleInterval = 6 * time.Hour
+)
+
+// schedule learns the votes flush cadence from the flush commits dates and
+// decides when the next update is made.
+type schedule struct {
+	// period is the interval between the flushes and phase is the offset of
+	// the flushes from the start of each period e.g. 1h and 58m for the
+	// flushes made at minute 58 of each hour. period is zero if the cadence
+	// is unknown.
+	period time.Duration
+	phase  time.Duration
+
+	// lastFlush is the date of the most recent flush.
+	lastFlush time.Time
+
+	// idle is the interval to the next update while no vote is active. It
+	// doubles after every idle update that finds no new commits.
+	idle time.Duration
+}
+
+// learn sets the flush cadence from the provided flush dates. The period is
+// the most common interval between consecutive flushes and the phase the most
+// common offset of the flushes within the period, both rounded to the minute.
+func (s *schedule) learn(flushes []time.Time) {
+	if len(flushes) == 0 {
+		return
+	}
+
+	sort.Slice(flushes, func(i, j int) bool { return flushes[i].Before(flushes[j]) })
+	s.lastFlush = flushes[len(flushes)-1]
+
+	if len(flushes) < minCadenceSamples {
+		return
+	}
+
+	intervals := make([]time.Duration, 0, len(flushes)-1)
+	for i := 1; i < len(flushes); i++ {
+		if d := flushes[i].Sub(flushes[i-1]).Round(time.Minute); d > 0 {
+			intervals = append(intervals, d)
+		}
+	}
+
+	period := mode(intervals)
+	if period <= 0 {
+		return
+	}
+
+	offsets := make([]time.Duration, 0, len(flushes))
+	for _, t := range flushes {
+		offset := time.Duration(t.UnixNano() % int64(period))
+		offsets = append(offsets, offset.Round(time.Minute)%period)
+	}
+
+	s.period = period
+	s.phase = mode(offsets)
+}
+
+// active returns true if a flush was made within the active vote window.
+func (s *schedule) active(now time.Time) bool {
+	return !s.lastFlush.IsZero() && now.Sub(s.lastFlush) < activeVoteWindow
+}
+
+// nextFlush returns the date of the first flush expected after now. A zero
+// time is returned if the cadence is unknown.
+func (s *schedule) nextFlush(now time.Time) time.Time {
+	if s.period <= 0 {
+		return time.Time{}
+	}
+
+	nanos := now.UnixNano()
+	start := time.Unix(0, nanos-nanos%int64(s.period)).UTC()
+	next := start.Add(s.phase)
+	if !next.After(now) {
+		next = next.Add(s.period)
+	}
+	return next
+}
+
+// next returns the date of the next update. While a vote is active, the
+// updates are made every poll interval and just after every expected flush.
+// Otherwise the interval doubles after every update that finds no new commits
+// up to maxIdleInterval. changed is true if the last update found new commits.
+func (s *schedule) next(now time.Time, interval time.Duration, changed bool) time.Time {
+	if !s.active(now) {
+		if changed || s.idle < interval {
+			s.idle = interval
+		}
+
+		next := now.Add(s.idle)
+
+		if s.idle *= 2; s.idle > maxIdleInterval {
+			s.idle = maxIdleInterval
+		}
+		return next
+	}
+
+	s.idle = interval
+	next := now.Add(interval)
+
+	flush := s.nextFlush(now)
+	if !flush.IsZero() {
+		if flush = flush.Add(flushDelay); flush.Before(next) {
+			next = flush
+		}
+	}
+
+	return next
+}
+
+// mode returns the most common value. The smallest one wins a tie.
+func mode(values []time.Duration) time.Duration {
+	counts := make(map[time.Duration]int, len(values))
+	var best time.Duration
+	for _, v := range values {
+		counts[v]++
+		if c := counts[v]; c > counts[best] || (c == counts[best] && v < best) {
+			best = v
+		}
+	}
+	return best
+}
+
+// NextUpdate returns the date that the next background update is scheduled
+// for. It is zero if no update is scheduled e.g. in the offline mode.
+func (p *Parser) NextUpdate() time.Time {
+	p.RLock()
+	defer p.RUnlock()
+
+	return p.nextUpdate
+}
+
+// scheduleNext sets the date of the next background update and returns how
+// long to wait for it. Without the adaptive schedule, the updates are made
+// every poll interval. changed is true if the last update found new commits.
+func (p *Parser) scheduleNext(changed bool) time.Duration {
+	now := time.Now()
+	next := now.Add(p.pollInterval)
+
+	if p.adaptive {
+		flushes, err := p.flushDates()
+		if err != nil {
+			p.log().Warn("learning the flush cadence failed", "error", err)
+		}
+
+		p.sched.learn(flushes)
+		next = p.sched.next(now, p.pollInterval, changed)
+
+		p.log().Debug("scheduled the next update", "at", next,
+			"flush_period", p.sched.period, "flush_phase", p.sched.phase,
+			"vote_active", p.sched.active(now))
+	}
+
+	p.Lock()
+	p.nextUpdate = next
+	p.Unlock()
+
+	return next.Sub(now)
+}
+
+// flushDates returns the committer dates of the most recent votes flush
+// commits in the snapshot.
+func (p *Parser) flushDates() ([]time.Time, error) {
+	p.RLock()
+	snapshot := p.snapshot()
+	pathspec := p.recordLayout().VotesPathspec("*")
+	p.RUnlock()
+
+	out, err := p.readCommandOutput(gitCmd, listCommitsArg,
+		"-"+strconv.Itoa(cadenceSampleSize), commitTimeFormatArg, snapshot,
+		pathSeparatorArg, pathspec)
+	if err != nil {
+		return nil, fmt.Errorf("listing the flush commits failed: %v", err)
+	}
+
+	var dates []time.Time
+	for _, str := range strings.Fields(out) {
+		secs, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid commit timestamp %q found", str)
+		}
+		dates = append(dates, time.Unix(secs, 0).UTC())
+	}
+
+	return dates, nil
+}
@@ -0,0 +1,121 @@
+package proposals
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hourlyFlushes returns the dates of the flushes made at minute 58 of every
+// hour until the provided date, with a few seconds jitter and a few hours with
+// no votes skipped.
+func hourlyFlushes(until time.Time, count int) []time.Time {
+	var flushes []time.Time
+	t := until.Truncate(time.Hour).Add(58 * time.Minute)
+	for i := 0; len(flushes) < count; i++ {
+		if i%4 != 3 {
+			flushes = append(flushes, t.Add(time.Duration(i%3)*time.Second))
+		}
+		t = t.Add(-time.Hour)
+	}
+	return flushes
+}
+
+// TestScheduleLearn tests that the flush cadence is learnt from the flushes
+// dates.
+func TestScheduleLearn(t *testing.T) {
+	until := time.Date(2019, 3, 24, 10, 0, 0, 0, time.UTC)
+
+	td := []struct {
+		flushes []time.Time
+		period  time.Duration
+		phase   time.Duration
+	}{
+		{hourlyFlushes(until, 20), time.Hour, 58 * time.Minute},
+		{hourlyFlushes(until, 3), time.Hour, 58 * time.Minute},
+		{hourlyFlushes(until, 2), 0, 0},
+		{nil, 0, 0},
+	}
+
+	for i, val := range td {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			var s schedule
+			s.learn(val.flushes)
+
+			if s.period != val.period || s.phase != val.phase {
+				t.Fatalf("expected the period %v and phase %v but found %v and %v",
+					val.period, val.phase, s.period, s.phase)
+			}
+		})
+	}
+}
+
+// TestScheduleNext tests the next update dates while a vote is active and
+// while none is.
+func TestScheduleNext(t *testing.T) {
+	until := time.Date(2019, 3, 24, 10, 0, 0, 0, time.UTC)
+	active := until.Add(30 * time.Minute)
+	idle := until.Add(48 * time.Hour)
+
+	var s schedule
+	s.learn(hourlyFlushes(until, 20))
+
+	td := []struct {
+		now      time.Time
+		interval time.Duration
+		changed  bool
+		next     time.Time
+	}{
+		// While the vote is active, poll every interval or just after the
+		// expected flush at 10:58.
+		{active, 5 * time.Minute, false, active.Add(5 * time.Minute)},
+		{active, time.Hour, false, until.Add(60 * time.Minute)},
+		// While no vote is active, back off up to maxIdleInterval.
+		{idle, time.Hour, true, idle.Add(time.Hour)},
+		{idle, time.Hour, false, idle.Add(2 * time.Hour)},
+		{idle, time.Hour, false, idle.Add(4 * time.Hour)},
+		{idle, time.Hour, false, idle.Add(6 * time.Hour)},
+		{idle, time.Hour, false, idle.Add(6 * time.Hour)},
+		{idle, time.Hour, true, idle.Add(time.Hour)},
+	}
+
+	for i, val := range td {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			next := s.next(val.now, val.interval, val.changed)
+			if !next.Equal(val.next) {
+				t.Fatalf("expected the next update at %v but found %v", val.next, next)
+			}
+		})
+	}
+}
+
+// TestAdaptiveSchedule tests that the flush cadence is learnt from the test
+// repository and the next update date is exposed.
+func TestAdaptiveSchedule(t *testing.T) {
+	p := newTestParser(t, WithAdaptiveSchedule(), WithPollInterval(time.Minute))
+	if err := p.pinSnapshot(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	flushes, err := p.flushDates()
+	if err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if len(flushes) != 1 || !strings.HasPrefix(flushes[0].String(), "2018-11-05 17:58:13") {
+		t.Fatalf("expected the single test repo flush but found %v", flushes)
+	}
+
+	// The test flush is too old for the vote to be active thus the idle
+	// interval is used.
+	d := p.scheduleNext(true)
+	if d <= 0 || d > time.Minute || p.NextUpdate().IsZero() {
+		t.Fatalf("expected the next update in a minute but found %v at %v",
+			d, p.NextUpdate())
+	}
+
+	if p.Status().NextUpdate != p.NextUpdate() {
+		t.Fatalf("expected the status to report the next update")
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package proposals
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// bundleArg is the git command used to work with bundle files.
+	bundleArg = "bundle"
+
+	// verifyArg checks that a bundle file is valid and applies cleanly.
+	verifyArg = "verify"
+
+	// setURLArg changes the URL of a remote when used with remoteDef.
+	setURLArg = "set-url"
+
+	// addArg adds a remote when used with remoteDef.
+	addArg = "add"
+
+	// gitDirName is the name of the git directory in a working directory.
+	gitDirName = ".git"
+)
+
+// NewParserFromBundle returns a Parser instance whose clone directory is seeded
+// from the provided git bundle or tar snapshot instead of cloning the remote
+// repository. The seed is only used if the repository hasn't been cloned into
+// the clone directory yet. Subsequent updates are fetched incrementally from
+// the remote repository. See NewParser for the rest of the arguments.
+func NewParserFromBundle(seedPath, repoOwner, repo, rootCloneDir string,
+	opts ...Option) (*Parser, error) {
+	return NewParser(repoOwner, repo, rootCloneDir, append(opts, WithSeed(seedPath))...)
+}
+
+// isTarSnapshot returns true if the seed file name has a tar archive extension.
+func isTarSnapshot(seedPath string) bool {
+	for _, ext := range []string{".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(seedPath, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// seedRepo initializes the working directory from the set seed file. The seed
+// is unpacked into a temporary directory, verified and set to track the remote
+// repository before it is swapped in as the working directory.
+func (p *Parser) seedRepo(workingDir, completeRemoteURL string) error {
+	tmpDir, err := ioutil.TempDir(p.cloneDir, cloneRepoAlias+tmpCloneSuffix)
+	if err != nil {
+		return fmt.Errorf("failed to create a temp seeding dir: %v", err)
+	}
+
+	// Drops the temporary directory if the swap never happens.
+	defer os.RemoveAll(tmpDir)
+
+	p.log().Info("seeding the repository", "seed", p.seedPath)
+
+	repoDir := tmpDir
+	if isTarSnapshot(p.seedPath) {
+		repoDir, err = extractSnapshot(p.seedPath, tmpDir)
+	} else {
+		err = p.unbundle(p.seedPath, tmpDir)
+	}
+
+	if err != nil {
+		return fmt.Errorf("seeding from %s failed: %v", p.seedPath, err)
+	}
+
+	if !p.isRepoIntact(repoDir) {
+		return fmt.Errorf("the seed %s failed the integrity checks", p.seedPath)
+	}
+
+	if err = p.setRemoteURL(repoDir, completeRemoteURL); err != nil {
+		return fmt.Errorf("setting the remote URL of the seed failed: %v", err)
+	}
+
+	return p.swapWorkingDir(workingDir, repoDir)
+}
+
+// setRemoteURL sets the URL of the remote tracked by the repository in the
+// provided directory. The remote is added if it doesn't exist.
+func (p *Parser) setRemoteURL(dir, completeRemoteURL string) error {
+	_, err := p.readCommandOutputIn(dir, gitCmd, remoteDef, setURLArg,
+		remoteURLRef, completeRemoteURL)
+	if err == nil {
+		return nil
+	}
+
+	_, err = p.readCommandOutputIn(dir, gitCmd, remoteDef, addArg, remoteURLRef,
+		completeRemoteURL)
+	return err
+}
+
+// unbundle clones the git bundle into the provided directory and verifies it.
+func (p *Parser) unbundle(bundlePath, dir string) error {
+	bundlePath, err := filepath.Abs(bundlePath)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.readCommandOutputIn(p.cloneDir, gitCmd, cloneArg, bundlePath, dir)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.readCommandOutputIn(dir, gitCmd, bundleArg, verifyArg, bundlePath)
+	return err
+}
+
+// extractSnapshot extracts the tar snapshot into the provided directory and
+// returns the path of the repository found in it. The snapshot can hold the
+// repository files at its root or in a single top level directory.
+func extractSnapshot(snapshotPath, dir string) (string, error) {
+	f, err := os.Open(snapshotPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if !strings.HasSuffix(snapshotPath, ".tar") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return "", err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	if err = extractTar(tar.NewReader(r), dir); err != nil {
+		return "", err
+	}
+
+	if _, err = os.Stat(filepath.Join(dir, gitDirName)); err == nil {
+		return dir, nil
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	if len(entries) == 1 && entries[0].IsDir() {
+		repoDir := filepath.Join(dir, entries[0].Name())
+		if _, err = os.Stat(filepath.Join(repoDir, gitDirName)); err == nil {
+			return repoDir, nil
+		}
+	}
+
+	return "", fmt.Errorf("no git repository found in the snapshot")
+}
+
+// extractTar writes the tar archive entries into the provided directory. Entries
+// that would be written outside the directory are rejected.
+func extractTar(tr *tar.Reader, dir string) error {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dir, hdr.Name)
+		if !isWithinDir(dir, target) {
+			return fmt.Errorf("invalid snapshot entry %s found", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0755)
+
+		case tar.TypeReg, tar.TypeRegA:
+			err = writeFile(target, tr, os.FileMode(hdr.Mode))
+
+		case tar.TypeSymlink:
+			if filepath.IsAbs(hdr.Linkname) ||
+				!isWithinDir(dir, filepath.Join(filepath.Dir(target), hdr.Linkname)) {
+				return fmt.Errorf("invalid snapshot link %s found", hdr.Name)
+			}
+			err = os.Symlink(hdr.Linkname, target)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// writeFile writes the reader contents into a file at the provided path
+// creating the missing parent directories.
+func writeFile(path string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode.Perm())
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// isWithinDir returns true if the path is the dir or one of its descendants.
+func isWithinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
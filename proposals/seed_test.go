@@ -0,0 +1,50 @@
+package proposals
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSeedRepo tests that the clone directory is seeded from a git bundle or a
+// tar snapshot and that the changes made after the seed are then fetched from
+// the remote repository.
+func TestSeedRepo(t *testing.T) {
+	origin := newTestOrigin(t)
+
+	bundle := filepath.Join(testDir, "seed.bundle")
+	runGit(t, origin, "bundle", "create", bundle, "--all")
+
+	snapshot := filepath.Join(testDir, "seed.tar.gz")
+	out, err := exec.Command("tar", "-czf", snapshot, "-C", filepath.Dir(origin),
+		filepath.Base(origin)).CombinedOutput()
+	if err != nil {
+		t.Fatalf("creating the snapshot failed: %v: %s", err, out)
+	}
+
+	// Commit made after the seeds were created.
+	commitVotes(t, origin, testToken, "Mon Nov 5 18:58:13 2018 +0000",
+		testVote(testToken, strings.Repeat("c", 64), "2"))
+
+	for _, seed := range []string{bundle, snapshot} {
+		t.Run(filepath.Base(seed), func(t *testing.T) {
+			p := newEmptyTestParser(t, WithRemoteURL(origin), WithSeed(seed),
+				WithRetries(0, 0))
+
+			if err := p.updateEnv(); err != nil {
+				t.Fatalf("expected no error but found: %v", err)
+			}
+
+			if p.HeadSHA() != runGit(t, origin, "rev-parse", "HEAD") {
+				t.Fatalf("expected the snapshot to match the origin HEAD but it didn't")
+			}
+
+			remote := runGit(t, filepath.Join(p.cloneDir, cloneRepoAlias), "remote",
+				"get-url", "origin")
+			if remote != origin {
+				t.Fatalf("expected the remote URL to be %s but found %s", origin, remote)
+			}
+		})
+	}
+}
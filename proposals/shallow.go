@@ -0,0 +1,180 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package proposals
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dmigwi/go-piparser/proposals/types"
+)
+
+const (
+	// shallowSinceArg limits the history fetched to the commits made after
+	// the provided date. It can also be used to deepen a shallow clone.
+	shallowSinceArg = "--shallow-since="
+
+	// depthArg limits the history fetched to the provided number of commits.
+	depthArg = "--depth"
+
+	// unshallowArg fetches the complete history of a shallow clone.
+	unshallowArg = "--unshallow"
+
+	// shallowFile lists the boundary commits of a shallow clone. It only
+	// exists in the git directory of a shallow clone.
+	shallowFile = "shallow"
+
+	// showArg shows the details of the provided git objects.
+	showArg = "show"
+
+	// noPatchArg suppresses the patch output of the show command.
+	noPatchArg = "-s"
+
+	// commitTimeFormatArg formats each commit shown as its committer unix
+	// timestamp.
+	commitTimeFormatArg = "--format=%ct"
+)
+
+// AvailableSince returns the date after which the full proposals history is
+// available locally. A zero time is returned if the complete history is
+// available. Queries for older history fetch it first.
+func (p *Parser) AvailableSince() time.Time {
+	p.RLock()
+	defer p.RUnlock()
+
+	return p.availableSince
+}
+
+// shallowArgs returns the arguments that limit the history fetched by a clone.
+func (p *Parser) shallowArgs() []string {
+	switch {
+	case !p.shallowSince.IsZero():
+		return []string{shallowSinceArg + p.shallowSince.Format(types.CmdDateFormat)}
+	case p.shallowDepth > 0:
+		return []string{depthArg, strconv.Itoa(p.shallowDepth)}
+	default:
+		return nil
+	}
+}
+
+// shallowBoundaries returns the boundary commits of a shallow clone and the
+// date of the most recent of them. No boundary commits are returned if the
+// complete history is available.
+func (p *Parser) shallowBoundaries() ([]string, time.Time, error) {
+	var t time.Time
+	path := filepath.Join(p.cloneDir, cloneRepoAlias, ".git", shallowFile)
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, t, nil
+	}
+
+	if err != nil {
+		return nil, t, err
+	}
+
+	var boundaries []string
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		if sha := strings.TrimSpace(scanner.Text()); sha != "" {
+			boundaries = append(boundaries, sha)
+		}
+	}
+
+	if len(boundaries) == 0 {
+		return nil, t, nil
+	}
+
+	args := append([]string{showArg, noPatchArg, commitTimeFormatArg}, boundaries...)
+	dates, err := p.readCommandOutput(gitCmd, args...)
+	if err != nil {
+		return nil, t, fmt.Errorf("reading the shallow boundary dates failed: %v", err)
+	}
+
+	for _, str := range strings.Fields(dates) {
+		secs, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return nil, t, fmt.Errorf("invalid commit timestamp %q found", str)
+		}
+
+		if date := time.Unix(secs, 0).UTC(); date.After(t) {
+			t = date
+		}
+	}
+
+	return boundaries, t, nil
+}
+
+// commitTime returns the committer date of the provided commit.
+func (p *Parser) commitTime(sha string) (time.Time, error) {
+	str, err := p.readCommandOutput(gitCmd, showArg, noPatchArg, commitTimeFormatArg, sha)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("reading the commit %s date failed: %v", sha, err)
+	}
+
+	secs, err := strconv.ParseInt(strings.TrimSpace(str), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid commit timestamp %q found", str)
+	}
+
+	return time.Unix(secs, 0).UTC(), nil
+}
+
+// ensureHistory fetches the history made after the provided date if it is not
+// available locally. A zero since date requests the complete history. In the
+// offline mode only the history available locally is queried.
+func (p *Parser) ensureHistory(since time.Time) error {
+	availableSince := p.AvailableSince()
+	if p.offline || availableSince.IsZero() || (!since.IsZero() && !since.Before(availableSince)) {
+		return nil
+	}
+
+	return p.deepen(since)
+}
+
+// deepen fetches the older history of a shallow clone up to the provided date.
+// A zero since date fetches the complete history. If another process holds the
+// clone directory lock in the read-only mode, only the history it fetched is
+// used.
+func (p *Parser) deepen(since time.Time) error {
+	p.updateMtx.Lock()
+	defer p.updateMtx.Unlock()
+
+	isLocked, err := p.lockCloneDir()
+	if err != nil {
+		return err
+	}
+
+	if isLocked {
+		defer p.cloneLock.unlock()
+
+		args := []string{fetchArg, unshallowArg, remoteURLRef}
+		if !since.IsZero() {
+			args = []string{fetchArg, shallowSinceArg + since.Format(types.CmdDateFormat),
+				remoteURLRef}
+		}
+
+		if err = p.execCommand(gitCmd, args...); err != nil {
+			return fmt.Errorf("fetching the history since %v failed: %v", since, err)
+		}
+	}
+
+	boundaries, availableSince, err := p.shallowBoundaries()
+	if err != nil {
+		return err
+	}
+
+	p.Lock()
+	p.shallowSHAs = boundaries
+	p.availableSince = availableSince
+	p.Unlock()
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package proposals
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmigwi/go-piparser/proposals/types"
+)
+
+// TestShallowClone tests that a shallow clone only exposes the history after
+// its boundary commit and that the older history is fetched on demand.
+func TestShallowClone(t *testing.T) {
+	origin := newTestOrigin(t)
+	commitVotes(t, origin, testToken, "Tue Mar 5 01:58:01 2019 +0000",
+		testVote(testToken, strings.Repeat("c", 64), "2"))
+	commitVotes(t, origin, testToken, "Wed Mar 6 12:58:01 2019 +0000",
+		testVote(testToken, strings.Repeat("d", 64), "1"))
+
+	since, _ := time.Parse(types.CmdDateFormat, "Mon Mar 4 00:00:00 2019 +0000")
+	p := newEmptyTestParser(t, WithRemoteURL("file://"+origin),
+		WithShallowSince(since), WithRetries(0, 0))
+
+	if err := p.updateEnv(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	boundary, _ := time.Parse(types.CmdDateFormat, "Tue Mar 5 01:58:01 2019 +0000")
+	if !p.AvailableSince().Equal(boundary) {
+		t.Fatalf("expected the history to be available since %v but found %v",
+			boundary, p.AvailableSince())
+	}
+
+	h, err := p.ProposalsHistorySince(boundary)
+	if err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if len(h) != 1 || len(h[0].Patch[0].VotesInfo) != 1 {
+		t.Fatalf("expected to find only the commit after the boundary but found %d", len(h))
+	}
+
+	h, err = p.ProposalsHistory()
+	if err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if len(h) != 3 {
+		t.Fatalf("expected to find 3 commits after fetching the full history but found %d", len(h))
+	}
+
+	if !p.AvailableSince().IsZero() {
+		t.Fatalf("expected the complete history to be available but it wasn't")
+	}
+}
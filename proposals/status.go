@@ -0,0 +1,101 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package proposals
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Status describes whether the Parser is usable. It is a point in time copy
+// that is safe to share.
+type Status struct {
+	// CloneDir is the directory that the repository is cloned into.
+	CloneDir string `json:"clone_dir"`
+
+	// Cloned is true if the repository has been cloned into CloneDir.
+	Cloned bool `json:"cloned"`
+
+	// HeadSHA and HeadCommitTime describe the snapshot that the queries are
+	// pinned to. They are empty until the first successful update.
+	HeadSHA        string    `json:"head_sha"`
+	HeadCommitTime time.Time `json:"head_commit_time"`
+
+	// LastUpdateAttempt and LastUpdateSuccess are the start times of the last
+	// update and of the last update that succeeded.
+	LastUpdateAttempt time.Time `json:"last_update_attempt"`
+	LastUpdateSuccess time.Time `json:"last_update_success"`
+
+	// LastUpdateError is the error returned by the last update if it failed.
+	LastUpdateError string `json:"last_update_error,omitempty"`
+
+	// NextUpdate is the date of the next scheduled background update. It is
+	// zero if none is scheduled.
+	NextUpdate time.Time `json:"next_update"`
+
+	// GitVersion is the semantic version of the git installation checked by
+	// the last update. It is empty if no check has been made.
+	GitVersion string `json:"git_version"`
+
+	// Offline is true if no changes are fetched from the remote repository.
+	Offline bool `json:"offline"`
+
+	// Shallow is true if only part of the history is cached locally. The
+	// complete history is available after AvailableSince.
+	Shallow        bool      `json:"shallow"`
+	AvailableSince time.Time `json:"available_since"`
+
+	// Layout is the name of the layout that the records are stored in.
+	Layout string `json:"layout"`
+}
+
+// Ready returns true if the queries can be served i.e. the first update has
+// succeeded and a snapshot has been pinned.
+func (s *Status) Ready() bool {
+	return !s.LastUpdateSuccess.IsZero() && s.HeadSHA != ""
+}
+
+// Status returns the current state of the clone directory, the pinned snapshot
+// and the repository updates.
+func (p *Parser) Status() *Status {
+	_, err := os.Stat(filepath.Join(p.cloneDir, cloneRepoAlias))
+
+	p.RLock()
+	defer p.RUnlock()
+
+	s := &Status{
+		CloneDir:          p.cloneDir,
+		Cloned:            err == nil,
+		HeadSHA:           p.headSHA,
+		HeadCommitTime:    p.headTime,
+		LastUpdateAttempt: p.lastAttempt,
+		LastUpdateSuccess: p.lastSuccess,
+		NextUpdate:        p.nextUpdate,
+		GitVersion:        p.gitVersion,
+		Offline:           p.offline,
+		Shallow:           len(p.shallowSHAs) > 0,
+		AvailableSince:    p.availableSince,
+		Layout:            p.recordLayout().Name(),
+	}
+
+	if p.lastErr != nil {
+		s.LastUpdateError = p.lastErr.Error()
+	}
+
+	return s
+}
+
+// recordUpdate records the result of the update that started at the provided
+// time.
+func (p *Parser) recordUpdate(start time.Time, err error) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.lastAttempt = start
+	p.lastErr = err
+	if err == nil {
+		p.lastSuccess = start
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package proposals
+
+import (
+	"github.com/dmigwi/go-piparser/proposals/types"
+)
+
+// Update describes the new history fetched by a single repository update.
+type Update struct {
+	// PreviousSHA is the commit SHA of the snapshot replaced by the update.
+	PreviousSHA string
+
+	// HeadSHA is the commit SHA of the new snapshot.
+	HeadSHA string
+
+	// History holds the commits made after the previous snapshot in the order
+	// they were made. Only the commits with votes are included.
+	History []*types.History
+
+	// Rollback is set if the previous snapshot is no longer an ancestor of
+	// the new one, i.e. the remote repository was force-pushed. Its history
+	// should be retracted before History is applied. History then holds the
+	// commits made after the fork point, or the complete history if the
+	// dropped commits are unknown.
+	Rollback *Rollback
+}
+
+// Subscribe returns a channel that receives the new history fetched by each
+// successful update, and the function that cancels the subscription and
+// closes the channel. The channel holds up to buffer updates. Updates are
+// dropped rather than blocking the Parser if the buffer is full, thus the
+// subscribers should read the channel promptly.
+func (p *Parser) Subscribe(buffer int) (<-chan *Update, func()) {
+	ch := make(chan *Update, buffer)
+
+	p.subMtx.Lock()
+	if p.subscribers == nil {
+		p.subscribers = make(map[chan *Update]struct{})
+	}
+	p.subscribers[ch] = struct{}{}
+	p.subMtx.Unlock()
+
+	cancel := func() {
+		p.subMtx.Lock()
+		defer p.subMtx.Unlock()
+
+		if _, ok := p.subscribers[ch]; ok {
+			delete(p.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	return ch, cancel
+}
+
+// publishUpdate parses the commits made between the previous and the new
+// snapshot, and the commits dropped by the rollback if set, and sends them to
+// the subscribers. Nothing is parsed if there are no subscribers.
+func (p *Parser) publishUpdate(previousSHA, headSHA string, rollback *Rollback) {
+	p.subMtx.Lock()
+	defer p.subMtx.Unlock()
+
+	if len(p.subscribers) == 0 {
+		return
+	}
+
+	u := &Update{PreviousSHA: previousSHA, HeadSHA: headSHA, Rollback: rollback}
+	appendTo := func(history *[]*types.History) WalkFunc {
+		return func(h *types.History) error {
+			*history = append(*history, h)
+			return nil
+		}
+	}
+
+	view, err := p.pinnedView()
+	if err != nil {
+		p.log().Error("parsing the updates failed", "previous_sha", previousSHA,
+			"head_sha", headSHA, "error", err)
+		return
+	}
+
+	revs := []string{headSHA, excludeRevPrefix + previousSHA}
+	if rollback != nil && rollback.Unknown {
+		revs = view.revs()
+	}
+
+	err = p.walkProposal(view, revs, "", newQueryFilter(nil), appendTo(&u.History))
+	if err == nil && rollback != nil && !rollback.Unknown {
+		// The dropped commits are those made after the fork point. Excluding
+		// it rather than the new HEAD lets the votes flushes preceding them
+		// be looked up from their own history.
+		revs = []string{previousSHA}
+		if rollback.ForkPoint != "" {
+			revs = append(revs, excludeRevPrefix+rollback.ForkPoint)
+		}
+		err = p.walkProposal(view, revs, "", newQueryFilter(nil), appendTo(&rollback.History))
+	}
+
+	if err != nil {
+		p.log().Error("parsing the updates failed", "previous_sha", previousSHA,
+			"head_sha", headSHA, "error", err)
+		return
+	}
+
+	for ch := range p.subscribers {
+		select {
+		case ch <- u:
+		default:
+			p.log().Warn("subscriber buffer is full: dropping the update",
+				"head_sha", headSHA)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CommentAction defines the journal action of a comments journal entry.
+type CommentAction string
+
+const (
+	// CommentAdded is the action of the entries that add a comment.
+	CommentAdded CommentAction = "add"
+
+	// CommentCensored is the action of the entries that censor a comment
+	// added earlier.
+	CommentCensored CommentAction = "del"
+
+	// CommentLiked is the action of the entries that up or down vote a
+	// comment. They are not parsed.
+	CommentLiked CommentAction = "addlike"
+)
+
+// CommentsJournalPathspec returns the pathspec matching the comments journals
+// of all the versions of the provided token. The comments are only stored in
+// the git journal layout.
+func CommentsJournalPathspec(token string) string {
+	return token + "/*/plugins/decred/comments.journal"
+}
+
+// Comment defines a single comments journal entry. ParentID, Comment and
+// Receipt are only set on the added comments while Reason is only set on the
+// censored ones.
+type Comment struct {
+	Action    CommentAction
+	Token     string
+	Version   string
+	CommentID string
+	ParentID  string
+	Comment   string
+	Reason    string
+	PublicKey string
+	Signature string
+	Receipt   string
+	Timestamp time.Time
+}
+
+// CommentsHistory defines the comments journal entries added in a single
+// commit.
+type CommentsHistory struct {
+	CommitSHA  string
+	Author     string
+	Date       time.Time
+	Committer  string
+	CommitDate time.Time
+	Message    string
+	Comments   []*Comment
+
+	// SkippedLines is the number of malformed journal lines skipped.
+	SkippedLines int
+}
+
+// journalAction is the JSON blob that starts every journal entry.
+type journalAction struct {
+	Version string        `json:"version"`
+	Action  CommentAction `json:"action"`
+}
+
+// commentEntry is the JSON blob of the added and censored comments that
+// follows the journal action.
+type commentEntry struct {
+	Token     string `json:"token"`
+	CommentID string `json:"commentid"`
+	ParentID  string `json:"parentid"`
+	Comment   string `json:"comment"`
+	Reason    string `json:"reason"`
+	PublicKey string `json:"publickey"`
+	Signature string `json:"signature"`
+	Receipt   string `json:"receipt"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// UnmarshalCommentsRecord unmarshals the comments journal entries added in a
+// single commit record output by git log --format=LogFormat. The history is
+// left empty if the commit adds no comments or is a merge commit.
+func UnmarshalCommentsRecord(h *CommentsHistory, record string, since ...time.Time) error {
+	fields := strings.SplitN(strings.TrimPrefix(record, RecordSeparator),
+		fieldSeparator, recordFields)
+	if len(fields) != recordFields {
+		return fmt.Errorf("invalid commit record found: expected %d fields but found %d",
+			recordFields, len(fields))
+	}
+
+	sha, parents, author, authorDate := fields[0], fields[1], fields[2], fields[3]
+	committer, commitDate, message, patch := fields[4], fields[5], fields[6], fields[7]
+
+	// The comments brought in by a merge commit are reported by the merged
+	// commits.
+	if len(strings.Fields(parents)) > 1 {
+		return nil
+	}
+
+	date, err := time.Parse(LogDateFormat, authorDate)
+	if err != nil {
+		return fmt.Errorf("invalid author date of commit %s: %v", sha, err)
+	}
+
+	if len(since) > 0 && date.Equal(since[0]) {
+		return nil
+	}
+
+	cDate, err := time.Parse(LogDateFormat, commitDate)
+	if err != nil {
+		return fmt.Errorf("invalid commit date of commit %s: %v", sha, err)
+	}
+
+	var comments []*Comment
+	var skipped int
+	for _, change := range ParseCommitDiff(patch) {
+		if change.Type == FileDeleted || change.IsBinary || change.JournalType() != "comments" {
+			continue
+		}
+
+		for _, line := range RetrieveAddedLines(change.Patch) {
+			c, err := parseCommentEntry(line)
+			if err != nil {
+				skipped++
+				continue
+			}
+
+			if c != nil {
+				c.Version = change.Version
+				comments = append(comments, c)
+			}
+		}
+	}
+
+	if len(comments) == 0 && skipped == 0 {
+		return nil
+	}
+
+	h.CommitSHA = sha
+	h.Author = author
+	h.Date = date
+	h.Committer = committer
+	h.CommitDate = cDate
+	h.Message = message
+	h.Comments = comments
+	h.SkippedLines = skipped
+
+	return nil
+}
+
+// parseCommentEntry parses a single comments journal line made of the journal
+// action followed by its payload. A nil comment is returned for the actions
+// that are not parsed.
+func parseCommentEntry(line string) (*Comment, error) {
+	dec := json.NewDecoder(strings.NewReader(line))
+
+	var action journalAction
+	if err := dec.Decode(&action); err != nil {
+		return nil, err
+	}
+
+	if action.Action != CommentAdded && action.Action != CommentCensored {
+		return nil, nil
+	}
+
+	var entry commentEntry
+	if err := dec.Decode(&entry); err != nil {
+		return nil, err
+	}
+
+	if !IsToken(entry.Token) || entry.CommentID == "" {
+		return nil, fmt.Errorf("invalid comment entry found: %s", line)
+	}
+
+	return &Comment{
+		Action:    action.Action,
+		Token:     entry.Token,
+		CommentID: entry.CommentID,
+		ParentID:  entry.ParentID,
+		Comment:   entry.Comment,
+		Reason:    entry.Reason,
+		PublicKey: entry.PublicKey,
+		Signature: entry.Signature,
+		Receipt:   entry.Receipt,
+		Timestamp: time.Unix(entry.Timestamp, 0).UTC(),
+	}, nil
+}
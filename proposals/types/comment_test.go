@@ -0,0 +1,80 @@
+package types
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// commentsPatch adds a comment, a like, a censored comment and a malformed
+// line to a comments journal.
+var commentsPatch = `diff --git a/27f87171d98b7923a1bd2bee6affed929fa2d2a6e178b5c80a9971a92a5c7f50/3/plugins/decred/comments.journal b/27f87171d98b7923a1bd2bee6affed929fa2d2a6e178b5c80a9971a92a5c7f50/3/plugins/decred/comments.journal
+index 3f1a2b4..8c9d0e1 100644
+--- a/27f87171d98b7923a1bd2bee6affed929fa2d2a6e178b5c80a9971a92a5c7f50/3/plugins/decred/comments.journal
++++ b/27f87171d98b7923a1bd2bee6affed929fa2d2a6e178b5c80a9971a92a5c7f50/3/plugins/decred/comments.journal
+@@ -1 +1,5 @@
+ {"version":"1","action":"add"}{"token":"27f87171d98b7923a1bd2bee6affed929fa2d2a6e178b5c80a9971a92a5c7f50","parentid":"0","comment":"first","signature":"aa","publickey":"bb","commentid":"1","receipt":"cc","timestamp":1551873481}
++{"version":"1","action":"add"}{"token":"27f87171d98b7923a1bd2bee6affed929fa2d2a6e178b5c80a9971a92a5c7f50","parentid":"1","comment":"a reply","signature":"dd","publickey":"ee","commentid":"2","receipt":"ff","timestamp":1551873581}
++{"version":"1","action":"addlike"}{"token":"27f87171d98b7923a1bd2bee6affed929fa2d2a6e178b5c80a9971a92a5c7f50","commentid":"2","action":"1","signature":"11","publickey":"22","receipt":"33","timestamp":1551873681}
++{"version":"1","action":"del"}{"token":"27f87171d98b7923a1bd2bee6affed929fa2d2a6e178b5c80a9971a92a5c7f50","commentid":"1","reason":"spam","signature":"44","publickey":"55","receipt":"66","timestamp":1551873781}
++{"version":"1","action":"add"}{"token":
+`
+
+// TestUnmarshalCommentsRecord tests the unmarshalling of the comments journal
+// entries added in a commit.
+func TestUnmarshalCommentsRecord(t *testing.T) {
+	parent := "4913ebaef7eac7f70913f285d49de03f5ed08e87"
+
+	var h CommentsHistory
+	err := UnmarshalCommentsRecord(&h, testRecord(parent, "Flush vote journals.", commentsPatch))
+	if err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if h.CommitSHA != "1d6edd806dd8bf043cdbd343c9d7d8e5dcc90b4f" || h.SkippedLines != 1 {
+		t.Fatalf("expected the commit with 1 skipped line but found %s with %d",
+			h.CommitSHA, h.SkippedLines)
+	}
+
+	td := []struct {
+		action    CommentAction
+		commentID string
+		parentID  string
+		comment   string
+		reason    string
+		timestamp int64
+	}{
+		{CommentAdded, "2", "1", "a reply", "", 1551873581},
+		{CommentCensored, "1", "", "", "spam", 1551873781},
+	}
+
+	if len(h.Comments) != len(td) {
+		t.Fatalf("expected %d comments but found %d", len(td), len(h.Comments))
+	}
+
+	for i, val := range td {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			c := h.Comments[i]
+			switch {
+			case c.Action != val.action || c.CommentID != val.commentID:
+				t.Fatalf("expected the %s entry of comment %s but found %s of %s",
+					val.action, val.commentID, c.Action, c.CommentID)
+
+			case c.ParentID != val.parentID || c.Comment != val.comment || c.Reason != val.reason:
+				t.Fatalf("expected the parent %q, comment %q and reason %q but found %+v",
+					val.parentID, val.comment, val.reason, c)
+
+			case c.Version != "3" || !c.Timestamp.Equal(time.Unix(val.timestamp, 0)):
+				t.Fatalf("expected version 3 at %d but found %s at %v", val.timestamp,
+					c.Version, c.Timestamp)
+			}
+		})
+	}
+
+	// The merge commits are ignored.
+	var merge CommentsHistory
+	err = UnmarshalCommentsRecord(&merge, testRecord(parent+" "+parent, "Merge", commentsPatch))
+	if err != nil || merge.CommitSHA != "" {
+		t.Fatalf("expected the merge commit to be ignored but found %s: %v", merge.CommitSHA, err)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package types
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// LogFormat is the git log --format placeholder string that outputs every
+	// commit as a record starting with the RecordSeparator. The record fields
+	// are separated by NUL characters and are: the commit SHA, the parent
+	// SHAs, the author, the author date, the committer, the commit date and
+	// the full commit message. The commit patch, if requested, follows the
+	// last field.
+	LogFormat = "%x1e%H%x00%P%x00%an <%ae>%x00%ai%x00%cn <%ce>%x00%ci%x00%B%x00"
+
+	// RecordSeparator is the character that starts every commit record in the
+	// output of git log --format=LogFormat.
+	RecordSeparator = "\x1e"
+
+	// LogDateFormat is the ISO 8601-like date format of the dates output by
+	// the %ai and %ci git log placeholders.
+	LogDateFormat = "2006-01-02 15:04:05 -0700"
+
+	// fieldSeparator separates the fields of a commit record.
+	fieldSeparator = "\x00"
+
+	// recordFields is the number of fields in a commit record, the patch
+	// included.
+	recordFields = 8
+)
+
+// UnmarshalCommitRecord unmarshals a single commit record output by git log
+// --format=LogFormat using the provided record layout. If the token is empty,
+// history for all the proposal tokens available is unmarshalled. If the
+// commit holds no votes data or is a merge commit, the history is left empty.
+func UnmarshalCommitRecord(h *History, l Layout, token, record string, since ...time.Time) error {
+	fields := strings.SplitN(strings.TrimPrefix(record, RecordSeparator),
+		fieldSeparator, recordFields)
+	if len(fields) != recordFields {
+		return fmt.Errorf("invalid commit record found: expected %d fields but found %d",
+			recordFields, len(fields))
+	}
+
+	sha, parents, author, authorDate := fields[0], fields[1], fields[2], fields[3]
+	committer, commitDate, message, patch := fields[4], fields[5], fields[6], fields[7]
+
+	// If no votes data detected, ignore the current record.
+	if !l.IsVotesCommit(message) {
+		return nil
+	}
+
+	date, err := time.Parse(LogDateFormat, authorDate)
+	if err != nil {
+		return fmt.Errorf("invalid author date of commit %s: %v", sha, err)
+	}
+
+	if len(since) > 0 && date.Equal(since[0]) {
+		// If this date matches the date in the record being unmarshalled
+		// then it already existed earlier on thus ignore it.
+		return nil
+	}
+
+	cDate, err := time.Parse(LogDateFormat, commitDate)
+	if err != nil {
+		return fmt.Errorf("invalid commit date of commit %s: %v", sha, err)
+	}
+
+	// The votes brought in by a merge commit are already reported by the
+	// merged commits since git log lists them too. Its patch, if any, is
+	// ignored so that the votes are not counted twice.
+	if len(strings.Fields(parents)) > 1 {
+		return nil
+	}
+
+	changes, err := parsePatch(l, token, patch, date)
+	if err != nil || len(changes) == 0 {
+		return err
+	}
+
+	h.Author = author
+	h.CommitSHA = sha
+	h.Date = date
+	h.Patch = changes
+	h.Committer = committer
+	h.CommitDate = cDate
+	h.Parents = strings.Fields(parents)
+	h.Message = message
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package types
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testRecord builds a commit record in the git log --format=LogFormat output.
+func testRecord(parents, message, patch string) string {
+	return strings.Join([]string{
+		RecordSeparator + "1d6edd806dd8bf043cdbd343c9d7d8e5dcc90b4f",
+		parents,
+		"Politeia <noreply@decred.org>",
+		"2019-03-06 12:58:01 +0000",
+		"GitHub <noreply@github.com>",
+		"2019-03-06 13:00:00 +0300",
+		message,
+		"\n" + patch,
+	}, fieldSeparator)
+}
+
+// TestUnmarshalCommitRecord tests the unmarshalling of the machine-parseable
+// git log commit records.
+func TestUnmarshalCommitRecord(t *testing.T) {
+	parent := "4913ebaef7eac7f70913f285d49de03f5ed08e87"
+	message := "Flush vote journals.\n\ncommit Author: Date: diff --git a\n"
+
+	var h History
+	err := UnmarshalCommitRecord(&h, RecordJSONLayout{}, "", testRecord(parent, message, recordPatch))
+	if err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	date := time.Date(2019, 3, 6, 12, 58, 1, 0, time.FixedZone("", 0))
+	commitDate := time.Date(2019, 3, 6, 13, 0, 0, 0, time.FixedZone("", 3*60*60))
+
+	switch {
+	case h.CommitSHA != "1d6edd806dd8bf043cdbd343c9d7d8e5dcc90b4f":
+		t.Fatalf("unexpected commit SHA %s found", h.CommitSHA)
+
+	case !reflect.DeepEqual(h.Parents, []string{parent}):
+		t.Fatalf("unexpected parents %v found", h.Parents)
+
+	case h.Author != "Politeia <noreply@decred.org>" || h.Committer != "GitHub <noreply@github.com>":
+		t.Fatalf("unexpected author %s or committer %s found", h.Author, h.Committer)
+
+	case !h.Date.Equal(date) || !h.CommitDate.Equal(commitDate):
+		t.Fatalf("unexpected author date %v or commit date %v found", h.Date, h.CommitDate)
+
+	case h.Message != message:
+		t.Fatalf("expected the message %q but found %q", message, h.Message)
+
+	case len(h.Patch) != 1 || len(h.Patch[0].VotesInfo) != 2:
+		t.Fatalf("expected to find 2 votes but found %v", h.Patch)
+	}
+
+	h = History{}
+	err = UnmarshalCommitRecord(&h, GitJournalLayout{}, "",
+		testRecord(parent, "Flush comment journals.", recordPatch))
+	if err != nil || h.CommitSHA != "" {
+		t.Fatalf("expected the non votes commit to be ignored but it wasn't: %v", err)
+	}
+
+	err = UnmarshalCommitRecord(&h, RecordJSONLayout{}, "",
+		testRecord(parent+" 62f715e00c50e7c506acc4b6e33eb86d02bab6d1", message, recordPatch))
+	if err != nil || h.CommitSHA != "" {
+		t.Fatalf("expected the merge commit patch to be ignored but it wasn't: %v", err)
+	}
+
+	err = UnmarshalCommitRecord(&h, GitJournalLayout{}, "", RecordSeparator+"1d6edd8")
+	if err == nil {
+		t.Fatalf("expected an error for the malformed record but none was returned")
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package types
+
+import (
+	"path"
+	"strings"
+)
+
+// ChangeType defines how a file was changed in a commit.
+type ChangeType string
+
+const (
+	// FileAdded is set for a file created in the commit.
+	FileAdded ChangeType = "added"
+
+	// FileModified is set for an existing file whose contents were changed.
+	FileModified ChangeType = "modified"
+
+	// FileDeleted is set for a file deleted in the commit.
+	FileDeleted ChangeType = "deleted"
+
+	// FileRenamed is set for a file moved to a new path. Its contents may
+	// have been changed too.
+	FileRenamed ChangeType = "renamed"
+)
+
+const (
+	// diffHeader starts the patch of every file changed in a commit.
+	diffHeader = "diff --git "
+
+	// devNull is the path used in the patch headers of the added and the
+	// deleted files in place of the missing path.
+	devNull = "/dev/null"
+)
+
+// FileChange defines a single file changed in a commit as read from the commit
+// patch.
+type FileChange struct {
+	Type ChangeType
+
+	// OldPath is the path before the change. It is empty for added files.
+	OldPath string
+
+	// Path is the path after the change. For deleted files, it is the path of
+	// the deleted file.
+	Path string
+
+	// Token and Version are read from the path of the record the file belongs
+	// to i.e. <token>/<version>/... Version is empty if the path has none.
+	Token   string
+	Version string
+
+	// IsBinary is true if the file contents are not shown in the patch.
+	IsBinary bool
+
+	// Patch is the complete patch text of the file, headers included.
+	Patch string
+}
+
+// ParseCommitDiff reads the patch of every file changed in the provided commit
+// patch and classifies each change. Any text before the first file patch is
+// ignored.
+func ParseCommitDiff(patch string) []*FileChange {
+	var changes []*FileChange
+	var current []string
+
+	flush := func() {
+		if len(current) > 0 {
+			changes = append(changes, parseFileChange(current))
+		}
+	}
+
+	for _, line := range strings.Split(patch, "\n") {
+		if strings.HasPrefix(line, diffHeader) {
+			flush()
+			current = nil
+		}
+
+		if current != nil || strings.HasPrefix(line, diffHeader) {
+			current = append(current, line)
+		}
+	}
+
+	flush()
+
+	return changes
+}
+
+// JournalType returns the name of the changed file without its extension e.g.
+// "ballot" for a ballot.journal file.
+func (c *FileChange) JournalType() string {
+	name := path.Base(c.Path)
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+// parseFileChange classifies the single file patch lines provided.
+func parseFileChange(lines []string) *FileChange {
+	c := &FileChange{Type: FileModified, Patch: strings.Join(lines, "\n")}
+
+	// The paths in the "diff --git a/<old> b/<new>" header are used unless
+	// the extended headers below provide them.
+	header := strings.TrimPrefix(lines[0], diffHeader)
+	if i := strings.LastIndex(header, " b/"); i > 0 {
+		c.OldPath = strings.TrimPrefix(header[:i], "a/")
+		c.Path = header[i+len(" b/"):]
+	}
+
+	for _, line := range lines[1:] {
+		switch {
+		case strings.HasPrefix(line, "new file mode"):
+			c.Type = FileAdded
+
+		case strings.HasPrefix(line, "deleted file mode"):
+			c.Type = FileDeleted
+
+		case strings.HasPrefix(line, "rename from "):
+			c.Type = FileRenamed
+			c.OldPath = strings.TrimPrefix(line, "rename from ")
+
+		case strings.HasPrefix(line, "rename to "):
+			c.Path = strings.TrimPrefix(line, "rename to ")
+
+		case strings.HasPrefix(line, "Binary files ") && strings.HasSuffix(line, " differ"):
+			c.IsBinary = true
+
+		case strings.HasPrefix(line, "--- "):
+			if name := strings.TrimPrefix(line, "--- "); name != devNull {
+				c.OldPath = strings.TrimPrefix(name, "a/")
+			}
+
+		case strings.HasPrefix(line, "+++ "):
+			if name := strings.TrimPrefix(line, "+++ "); name != devNull {
+				c.Path = strings.TrimPrefix(name, "b/")
+			}
+		}
+
+		// The hunks start after the headers.
+		if strings.HasPrefix(line, "@@") {
+			break
+		}
+	}
+
+	if c.Type == FileAdded {
+		c.OldPath = ""
+	}
+
+	c.Token, c.Version = splitRecordPath(c.Path)
+
+	return c
+}
+
+// splitRecordPath returns the token and the version of the record that the
+// provided path belongs to. The token is the first path element while the
+// version is the second one, if it is a number.
+func splitRecordPath(filePath string) (token, version string) {
+	parts := strings.SplitN(filePath, "/", 3)
+	if len(parts) < 2 {
+		return "", ""
+	}
+
+	token = parts[0]
+	if len(parts) == 3 && isDigits(parts[1]) {
+		version = parts[1]
+	}
+
+	return token, version
+}
+
+// isDigits returns true if the provided string is made up of digits only.
+func isDigits(str string) bool {
+	if str == "" {
+		return false
+	}
+
+	for _, r := range str {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+// commitPatch holds file changes of all the supported change types.
+const commitPatch = `Flush vote journals.
+
+diff --git a/27f87171d98b7923a1bd2bee6affed929fa2d2a6e178b5c80a9971a92a5c7f50/3/plugins/decred/ballot.journal b/27f87171d98b7923a1bd2bee6affed929fa2d2a6e178b5c80a9971a92a5c7f50/3/plugins/decred/ballot.journal
+new file mode 100644
+index 0000000..45b983b
+--- /dev/null
++++ b/27f87171d98b7923a1bd2bee6affed929fa2d2a6e178b5c80a9971a92a5c7f50/3/plugins/decred/ballot.journal
+@@ -0,0 +1 @@
++{"version":"1","action":"add"}
+diff --git a/a3def199af812b796887f4eae22e11e45f112b50c2e17252c60ed190933ec14f/5/plugins/decred/ballot.journal b/a3def199af812b796887f4eae22e11e45f112b50c2e17252c60ed190933ec14f/6/plugins/decred/ballot.journal
+similarity index 100%
+rename from a3def199af812b796887f4eae22e11e45f112b50c2e17252c60ed190933ec14f/5/plugins/decred/ballot.journal
+rename to a3def199af812b796887f4eae22e11e45f112b50c2e17252c60ed190933ec14f/6/plugins/decred/ballot.journal
+diff --git a/5431da8ff4eda8cdbf8f4f2e08566ffa573464b97ef6d6bae78e749f27800d3a/3/plugins/decred/ballot.journal b/5431da8ff4eda8cdbf8f4f2e08566ffa573464b97ef6d6bae78e749f27800d3a/3/plugins/decred/ballot.journal
+deleted file mode 100644
+index 45b983b..0000000
+--- a/5431da8ff4eda8cdbf8f4f2e08566ffa573464b97ef6d6bae78e749f27800d3a/3/plugins/decred/ballot.journal
++++ /dev/null
+@@ -1 +0,0 @@
+-{"version":"1","action":"add"}
+diff --git a/0f1e2d3c4b5a6978/index.png b/0f1e2d3c4b5a6978/index.png
+index 6b23caab..468606b3 100644
+Binary files a/0f1e2d3c4b5a6978/index.png and b/0f1e2d3c4b5a6978/index.png differ
+diff --git a/README.md b/README.md
+index 6b23caab..468606b3 100644
+--- a/README.md
++++ b/README.md
+@@ -1 +1 @@
+-diff --git a
++rename to
+`
+
+// TestParseCommitDiff tests the classification of the file changes in a commit
+// patch.
+func TestParseCommitDiff(t *testing.T) {
+	changes := ParseCommitDiff(commitPatch)
+
+	type expected struct {
+		Type           ChangeType
+		OldPath, Path  string
+		Token, Version string
+		IsBinary       bool
+	}
+
+	td := []expected{
+		{FileAdded, "",
+			"27f87171d98b7923a1bd2bee6affed929fa2d2a6e178b5c80a9971a92a5c7f50/3/plugins/decred/ballot.journal",
+			"27f87171d98b7923a1bd2bee6affed929fa2d2a6e178b5c80a9971a92a5c7f50", "3", false},
+		{FileRenamed,
+			"a3def199af812b796887f4eae22e11e45f112b50c2e17252c60ed190933ec14f/5/plugins/decred/ballot.journal",
+			"a3def199af812b796887f4eae22e11e45f112b50c2e17252c60ed190933ec14f/6/plugins/decred/ballot.journal",
+			"a3def199af812b796887f4eae22e11e45f112b50c2e17252c60ed190933ec14f", "6", false},
+		{FileDeleted,
+			"5431da8ff4eda8cdbf8f4f2e08566ffa573464b97ef6d6bae78e749f27800d3a/3/plugins/decred/ballot.journal",
+			"5431da8ff4eda8cdbf8f4f2e08566ffa573464b97ef6d6bae78e749f27800d3a/3/plugins/decred/ballot.journal",
+			"5431da8ff4eda8cdbf8f4f2e08566ffa573464b97ef6d6bae78e749f27800d3a", "3", false},
+		{FileModified, "0f1e2d3c4b5a6978/index.png", "0f1e2d3c4b5a6978/index.png",
+			"0f1e2d3c4b5a6978", "", true},
+		{FileModified, "README.md", "README.md", "", "", false},
+	}
+
+	if len(changes) != len(td) {
+		t.Fatalf("expected to find %d file changes but found %d", len(td), len(changes))
+	}
+
+	for i, c := range changes {
+		result := expected{c.Type, c.OldPath, c.Path, c.Token, c.Version, c.IsBinary}
+		if !reflect.DeepEqual(result, td[i]) {
+			t.Fatalf("expected change #%d to be %v but found %v", i, td[i], result)
+		}
+	}
+}
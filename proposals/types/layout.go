@@ -0,0 +1,215 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Layout defines how a Politeia data repository stores the proposal records
+// and their votes. It helps the votes to be parsed from the repositories
+// created by the different Politeia versions.
+type Layout interface {
+	// Name returns the layout name.
+	Name() string
+
+	// Detect returns true if the provided repository file paths are stored
+	// in this layout.
+	Detect(paths []string) bool
+
+	// IsVotesCommit returns true if the commit string can hold votes data.
+	IsVotesCommit(commit string) bool
+
+	// ParseFile parses the votes added in the provided file change. If the
+	// token isn't empty only votes cast for it are parsed. A nil File is
+	// returned if the change holds no votes data.
+	ParseFile(change *FileChange, token string) (*File, error)
+
+	// VotesPathspec returns the git pathspec that matches the votes files of
+	// the provided token.
+	VotesPathspec(token string) string
+}
+
+// Layouts lists the supported layouts in the order they are detected.
+var Layouts = []Layout{GitJournalLayout{}, RecordJSONLayout{}}
+
+// DetectLayout returns the layout that the provided repository file paths are
+// stored in. The git journal layout is returned if none is detected.
+func DetectLayout(paths []string) Layout {
+	for _, l := range Layouts {
+		if l.Detect(paths) {
+			return l
+		}
+	}
+	return GitJournalLayout{}
+}
+
+// GitJournalLayout is the layout used by the Politeia gitbe backend. Votes are
+// appended to <token>/<version>/plugins/decred/ballot.journal and committed
+// hourly in "Flush vote journals" commits. Tokens are 64 characters long.
+type GitJournalLayout struct{}
+
+// Confirm that GitJournalLayout implements the Layout interface.
+var _ Layout = GitJournalLayout{}
+
+// Name returns the layout name.
+func (GitJournalLayout) Name() string { return "gitjournal" }
+
+// Detect returns true if any of the paths is a git journal ballot file.
+func (GitJournalLayout) Detect(paths []string) bool {
+	for _, path := range paths {
+		if IsMatching(path, journalBallotPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsVotesCommit returns true if the commit message is a votes flush message.
+func (GitJournalLayout) IsVotesCommit(commit string) bool {
+	return IsMatching(commit, DefaultVotesCommitMsg)
+}
+
+// VotesPathspec returns the pathspec matching the ballot journals of all the
+// token versions.
+func (GitJournalLayout) VotesPathspec(token string) string {
+	return token + "/*/plugins/decred/ballot.journal"
+}
+
+// ParseFile parses the votes appended to a ballot journal. Deleted and binary
+// files hold no votes added thus they are ignored.
+func (GitJournalLayout) ParseFile(change *FileChange, token string) (*File, error) {
+	if change.Type == FileDeleted || change.IsBinary {
+		return nil, nil
+	}
+
+	filePatch := change.Patch
+
+	// If the proposal token has been set, check if this payload has the required
+	// proposal token data. If it exists proceed otherwise ignore it.
+	if isMatched := IsMatching(filePatch, TokenVotesJSONSignature(token)); !isMatched {
+		return nil, nil
+	}
+
+	proposalToken, err := RetrieveProposalToken(filePatch)
+	if err != nil {
+		return nil, err // Missing proposal token
+	}
+
+	// Count the added lines that do not start a journal entry. They are
+	// dropped by the patch selection below.
+	var skipped int
+	for _, line := range RetrieveAddedLines(filePatch) {
+		if !IsMatching("+"+line, "^"+string(journalSelection())) {
+			skipped++
+		}
+	}
+
+	filePatch = RetrieveAllPatchSelection(filePatch)
+
+	filePatch = ReplaceJournalSelection(filePatch, "")
+
+	// Drop any special characters left.
+	filePatch = ReplaceAny(filePatch, `\s`, "")
+
+	// Add the square brackets and commas to complete the JSON string array format.
+	filePatch = "[" + ReplaceAny(filePatch, "}{", "},{") + "]"
+
+	var v Votes
+
+	if err = json.Unmarshal([]byte(filePatch), &v); err != nil {
+		return nil, fmt.Errorf("Unmarshalling File failed: %v %s", err, filePatch)
+	}
+
+	return &File{
+		Token:        proposalToken,
+		Version:      change.Version,
+		JournalType:  change.JournalType(),
+		Change:       change.Type,
+		VotesInfo:    v,
+		SkippedLines: skipped,
+	}, nil
+}
+
+// RecordJSONLayout is the layout of the records exported from the newer tlog
+// based Politeia versions. Each record is stored in a directory named after
+// its 16 characters long token and its votes are stored in
+// <token>/plugins/ticketvote/votes.json as one JSON blob per line.
+type RecordJSONLayout struct{}
+
+// Confirm that RecordJSONLayout implements the Layout interface.
+var _ Layout = RecordJSONLayout{}
+
+// recordVote defines the JSON blob of a single vote in the record JSON layout.
+type recordVote struct {
+	Token     string  `json:"token"`
+	Ticket    string  `json:"ticket"`
+	VoteBit   bitCast `json:"votebit"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+// Name returns the layout name.
+func (RecordJSONLayout) Name() string { return "recordjson" }
+
+// Detect returns true if any of the paths is a record votes file.
+func (RecordJSONLayout) Detect(paths []string) bool {
+	for _, path := range paths {
+		if IsMatching(path, recordVotesPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsVotesCommit returns true for all commits since the votes are not committed
+// with a specific commit message.
+func (RecordJSONLayout) IsVotesCommit(commit string) bool { return true }
+
+// VotesPathspec returns the path of the token votes file.
+func (RecordJSONLayout) VotesPathspec(token string) string {
+	return token + "/plugins/ticketvote/votes.json"
+}
+
+// ParseFile parses the votes blobs added to a record votes file. The votes that
+// have a timestamp set have an exact cast time. The malformed lines are
+// skipped and counted. Deleted and binary files hold no votes added thus they
+// are ignored.
+func (RecordJSONLayout) ParseFile(change *FileChange, token string) (*File, error) {
+	if change.Type == FileDeleted || change.IsBinary ||
+		!IsMatching(change.Path, recordVotesPath) {
+		return nil, nil
+	}
+
+	if token != "" && token != change.Token {
+		return nil, nil
+	}
+
+	var v Votes
+	var skipped int
+	for _, line := range RetrieveAddedLines(change.Patch) {
+		var vote recordVote
+		if err := json.Unmarshal([]byte(line), &vote); err != nil || vote.Ticket == "" {
+			skipped++
+			continue
+		}
+
+		data := CastVoteData{PiVote: &PiVote{Ticket: vote.Ticket, VoteBit: vote.VoteBit}}
+		if vote.Timestamp > 0 {
+			data.EstimatedCastAt = ExactTimeRange(time.Unix(vote.Timestamp, 0).UTC())
+		}
+
+		v = append(v, data)
+	}
+
+	return &File{
+		Token:        change.Token,
+		Version:      change.Version,
+		JournalType:  change.JournalType(),
+		Change:       change.Type,
+		VotesInfo:    v,
+		SkippedLines: skipped,
+	}, nil
+}
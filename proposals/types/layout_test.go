@@ -0,0 +1,98 @@
+package types
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// recordPatch is a single file patch in the record JSON layout.
+const recordPatch = `diff --git a/0f1e2d3c4b5a6978/plugins/ticketvote/votes.json b/0f1e2d3c4b5a6978/plugins/ticketvote/votes.json
+index 6b23caab..468606b3 100644
+--- a/0f1e2d3c4b5a6978/plugins/ticketvote/votes.json
++++ b/0f1e2d3c4b5a6978/plugins/ticketvote/votes.json
+@@ -1,1 +1,4 @@
+ {"token":"0f1e2d3c4b5a6978","ticket":"aa","votebit":"1","receipt":"f0"}
++{"token":"0f1e2d3c4b5a6978","ticket":"bb","votebit":"2","receipt":"f1"}
++{"token":"0f1e2d3c4b5a6978","ticket":"dd","votebit":
++{"token":"0f1e2d3c4b5a6978","ticket":"cc","votebit":"1","receipt":"f2"}
+`
+
+// TestDetectLayout tests the detection of the layout from the repository files.
+func TestDetectLayout(t *testing.T) {
+	td := []struct {
+		paths  []string
+		layout Layout
+	}{
+		{nil, GitJournalLayout{}},
+		{[]string{"README.md"}, GitJournalLayout{}},
+		{[]string{"27f87171d98b7923a1bd2bee6affed929fa2d2a6e178b5c80a9971a92a5c7f50/3/plugins/decred/ballot.journal"},
+			GitJournalLayout{}},
+		{[]string{"README.md", "0f1e2d3c4b5a6978/plugins/ticketvote/votes.json"},
+			RecordJSONLayout{}},
+	}
+
+	for i, val := range td {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			if l := DetectLayout(val.paths); l != val.layout {
+				t.Fatalf("expected the %s layout but found %s", val.layout.Name(), l.Name())
+			}
+		})
+	}
+}
+
+// TestRecordJSONLayoutParseFile tests the parsing of the votes added to a record
+// votes file.
+func TestRecordJSONLayoutParseFile(t *testing.T) {
+	td := []struct {
+		token  string
+		output *File
+	}{
+		{"", &File{Token: "0f1e2d3c4b5a6978", JournalType: "votes", Change: FileModified, VotesInfo: Votes{
+			{PiVote: &PiVote{Ticket: "bb", VoteBit: "Yes"}},
+			{PiVote: &PiVote{Ticket: "cc", VoteBit: "No"}},
+		}, SkippedLines: 1}},
+		{"0f1e2d3c4b5a6978", &File{Token: "0f1e2d3c4b5a6978", JournalType: "votes", Change: FileModified, VotesInfo: Votes{
+			{PiVote: &PiVote{Ticket: "bb", VoteBit: "Yes"}},
+			{PiVote: &PiVote{Ticket: "cc", VoteBit: "No"}},
+		}, SkippedLines: 1}},
+		{"a3def199af812b79", nil},
+	}
+
+	for i, val := range td {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			f, err := RecordJSONLayout{}.ParseFile(ParseCommitDiff(recordPatch)[0], val.token)
+			if err != nil {
+				t.Fatalf("expected no error but found: %v", err)
+			}
+
+			if !reflect.DeepEqual(f, val.output) {
+				t.Fatalf("expected the parsed file to be %v but found %v", val.output, f)
+			}
+		})
+	}
+}
+
+// TestGitJournalLayoutSkippedLines tests that the added lines that are not
+// journal entries are counted as skipped.
+func TestGitJournalLayoutSkippedLines(t *testing.T) {
+	token := "27f87171d98b7923a1bd2bee6affed929fa2d2a6e178b5c80a9971a92a5c7f50"
+	patch := "diff --git a/" + token + "/3/plugins/decred/ballot.journal b/" + token +
+		"/3/plugins/decred/ballot.journal\n" +
+		"--- a/" + token + "/3/plugins/decred/ballot.journal\n" +
+		"+++ b/" + token + "/3/plugins/decred/ballot.journal\n" +
+		"@@ -1,0 +1,2 @@\n" +
+		`+{"version":"1","action":"add"}{"castvote":{"token":"` + token +
+		`","ticket":"aa","votebit":"2","signature":"f0"},"receipt":"f1"}` + "\n" +
+		"+garbage\n"
+
+	f, err := GitJournalLayout{}.ParseFile(ParseCommitDiff(patch)[0], token)
+	if err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if len(f.VotesInfo) != 1 || f.SkippedLines != 1 {
+		t.Fatalf("expected 1 vote and 1 skipped line but found %d and %d",
+			len(f.VotesInfo), f.SkippedLines)
+	}
+}
@@ -56,6 +56,22 @@ var (
 	// case of any letter, exclusive of punctuations and white space characters.
 	anyTokenSelection = `[A-z0-9]{64}`
 
+	// shortTokenSelection matches the 16 hexadecimal characters long tokens
+	// used by the newer tlog based Politeia versions.
+	shortTokenSelection = `[0-9a-f]{16}`
+
+	// ticketSelection matches a ticket hash, 64 lower case hexadecimal
+	// characters.
+	ticketSelection = `[0-9a-f]{64}`
+
+	// journalBallotPath matches the path of a ballot journal file in the git
+	// journal layout e.g. <token>/<version>/plugins/decred/ballot.journal.
+	journalBallotPath = `^` + anyTokenSelection + `/[[:digit:]]+/plugins/decred/ballot\.journal$`
+
+	// recordVotesPath matches the path of a record votes file in the record
+	// JSON layout e.g. <token>/plugins/ticketvote/votes.json.
+	recordVotesPath = `^` + shortTokenSelection + `/plugins/ticketvote/votes\.json$`
+
 	// In a git commit history, the changes made per file always start with
 	// "diff --git a". commitDiff is therefore used to split the single commit
 	// string into file changes in an array. "diff --git a" is documented here:
@@ -70,10 +86,17 @@ var (
 // the commit patch string required. The matched commit patch string contains
 // the needed votes data.
 func VotesJSONSignature() string {
-	if proposalToken == "" {
+	return TokenVotesJSONSignature(proposalToken)
+}
+
+// TokenVotesJSONSignature returns the votes json string signature for the
+// provided proposal token. If the token is empty, the signature matches the
+// votes of any proposal token.
+func TokenVotesJSONSignature(token string) string {
+	if token == "" {
 		return fmt.Sprintf(`{"castvote":{"token":"%s",`, anyTokenSelection)
 	}
-	return fmt.Sprintf(`{"castvote":{"token":"%s",`, proposalToken)
+	return fmt.Sprintf(`{"castvote":{"token":"%s",`, token)
 }
 
 // exp compiles the PiRegExp regex expression type.
@@ -135,6 +158,18 @@ func RetrieveProposalToken(parent string) (string, error) {
 	return "", fmt.Errorf("missing token from the parsed string")
 }
 
+// IsToken returns boolean true if the provided string is a proposal token of
+// either the git journal or the record JSON layout.
+func IsToken(str string) bool {
+	regex := fmt.Sprintf(`^(%s|%s)$`, anyTokenSelection, shortTokenSelection)
+	return IsMatching(str, regex)
+}
+
+// IsTicket returns boolean true if the provided string is a ticket hash.
+func IsTicket(str string) bool {
+	return IsMatching(str, `^`+ticketSelection+`$`)
+}
+
 // IsMatching returns boolean true if the matchRegex can be matched in the parent
 // string.
 func IsMatching(parent, matchRegex string) bool {
@@ -152,6 +187,22 @@ func ReplaceAny(parent, regex, with string) string {
 	return r.ReplaceAllLiteralString(parent, with)
 }
 
+// RetrieveAddedLines returns the lines added in the provided single file patch
+// without the leading "+" character. Empty lines are ignored.
+func RetrieveAddedLines(filePatch string) []string {
+	var lines []string
+	for _, line := range strings.Split(filePatch, "\n") {
+		if !strings.HasPrefix(line, "+") || strings.HasPrefix(line, "+++") {
+			continue
+		}
+
+		if line = strings.TrimSpace(line[1:]); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
 // SplitCommitDiff uses the commitDiff separating string to split the string
 // into an array.
 func SplitCommitDiff(parent string) []string {
@@ -182,6 +233,13 @@ func IsGitVersionSupported(parsedStr string) error {
 	return nil
 }
 
+// GitVersion returns the git semantic version found in the parse string e.g.
+// "2.39.2" from "git version 2.39.2". An empty string is returned if none is
+// found.
+func GitVersion(parsedStr string) string {
+	return gitVersionSelection.exp().FindString(parsedStr)
+}
+
 // parseVersion converts the semantic version into an int value that can be compared.
 func parseVersion(strList []string) (int64, error) {
 	str := ""
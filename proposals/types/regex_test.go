@@ -359,6 +359,29 @@ func TestVotesJSONSignature(t *testing.T) {
 	}
 }
 
+// TestIsTicket tests that only the ticket hashes are matched.
+func TestIsTicket(t *testing.T) {
+	td := []struct {
+		ticket   string
+		isTicket bool
+	}{
+		{"e272d314b1f6a15c4480145ab286a54bb9b6735718b776755fea7c77eba030b8", true},
+		{"E272D314B1F6A15C4480145AB286A54BB9B6735718B776755FEA7C77EBA030B8", false},
+		{"e272d314b1f6a15c4480145ab286a54bb9b6735718b776755fea7c77eba030b", false},
+		{"e272d314b1f6a15c4480145ab286a54bb9b6735718b776755fea7c77eba030b8a", false},
+		{"g272d314b1f6a15c4480145ab286a54bb9b6735718b776755fea7c77eba030b8", false},
+		{"", false},
+	}
+
+	for i, val := range td {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			if IsTicket(val.ticket) != val.isTicket {
+				t.Fatalf("expected IsTicket to be %v for %q", val.isTicket, val.ticket)
+			}
+		})
+	}
+}
+
 func TestRetrieveProposalToken(t *testing.T) {
 	testString1 := `{"version":"1","action":"add"}{"castvote":{"token":"a3def199af812b796887f4eae22e11e45f112b50c2e17252c60ed190933ec14f","ticket":"03d4f5888a0a7bf983852b379de539acf8eff272534cf2be6846ac55eaae878b","votebit":"1","signature":"1f06c29926a871a501f91fd0bca0b68b2d12226c582f0277b4be59eb48454b8e894824c4a02ec312b87245d285a99f835492dd766bfd34d9d32222a6f03c60a413"},"receipt":"7e0f760157cf8d3cb7bfe76e4c76aaf41a6571dc4a9519d603be30986fb36028203cf21c9e81e2819adaa3660b4195a0868daf068c5a39f7949f822b53977f05"}`
 
@@ -438,3 +461,24 @@ func TestIsGitVersionSupported(t *testing.T) {
 		})
 	}
 }
+
+func TestGitVersion(t *testing.T) {
+	td := []struct {
+		ParsedStr string
+		Version   string
+	}{
+		{"git version 2.39.2", "2.39.2"},
+		{"git version 2.20.1 (Apple Git-117)", "2.20.1"},
+		{"git version", ""},
+		{"", ""},
+	}
+
+	for i, val := range td {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			version := GitVersion(val.ParsedStr)
+			if version != val.Version {
+				t.Fatalf("expected the version (%s) but found (%s)", val.Version, version)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package types
+
+// Tally defines the number of votes cast per vote option on a proposal.
+type Tally struct {
+	Token   string         `json:"token"`
+	Options map[string]int `json:"options"`
+	Total   int            `json:"total"`
+}
+
+// TallyVotes counts the votes found in the provided history per proposal
+// token. Politeia rejects repeated votes from the same ticket thus only the
+// first vote cast by each ticket is counted.
+func TallyVotes(history []*History) map[string]*Tally {
+	tallies := make(map[string]*Tally)
+	counted := make(map[string]bool)
+
+	for _, h := range history {
+		for _, f := range h.Patch {
+			tally, ok := tallies[f.Token]
+			if !ok {
+				tally = &Tally{Token: f.Token, Options: make(map[string]int)}
+				tallies[f.Token] = tally
+			}
+
+			for _, v := range f.VotesInfo {
+				if v.PiVote == nil || counted[f.Token+v.Ticket] {
+					continue
+				}
+
+				counted[f.Token+v.Ticket] = true
+				tally.Options[string(v.VoteBit)]++
+				tally.Total++
+			}
+		}
+	}
+
+	return tallies
+}
@@ -0,0 +1,39 @@
+package types
+
+import (
+	"testing"
+)
+
+// TestTallyVotes tests that the votes are counted per token and that repeated
+// votes from a ticket are ignored.
+func TestTallyVotes(t *testing.T) {
+	history := []*History{
+		{Patch: []*File{
+			{Token: "aa", VotesInfo: Votes{
+				{PiVote: &PiVote{Ticket: "t1", VoteBit: "Yes"}},
+				{PiVote: &PiVote{Ticket: "t2", VoteBit: "No"}},
+			}},
+			{Token: "bb", VotesInfo: Votes{
+				{PiVote: &PiVote{Ticket: "t1", VoteBit: "No"}},
+			}},
+		}},
+		{Patch: []*File{
+			{Token: "aa", VotesInfo: Votes{
+				{PiVote: &PiVote{Ticket: "t1", VoteBit: "No"}},
+				{PiVote: &PiVote{Ticket: "t3", VoteBit: "Yes"}},
+			}},
+		}},
+	}
+
+	tallies := TallyVotes(history)
+
+	a := tallies["aa"]
+	if a == nil || a.Total != 3 || a.Options["Yes"] != 2 || a.Options["No"] != 1 {
+		t.Fatalf("expected 2 Yes and 1 No votes for token aa but found %v", a)
+	}
+
+	b := tallies["bb"]
+	if b == nil || b.Total != 1 || b.Options["No"] != 1 {
+		t.Fatalf("expected 1 No vote for token bb but found %v", b)
+	}
+}
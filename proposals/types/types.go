@@ -61,19 +61,35 @@ func (s semVer) String() string {
 var _ json.Unmarshaler = (*Votes)(nil)
 
 // History defines the standard single commit history contents to be shared
-// with the outside world.
+// with the outside world. Author and Committer are formatted as "Name <email>"
+// while Date is the author date.
 type History struct {
 	Author    string
 	CommitSHA string
 	Date      time.Time
 	Patch     []*File
+
+	// The fields below are only set when the history is parsed from the
+	// machine-parseable git log format. See UnmarshalCommitRecord.
+	Committer  string
+	CommitDate time.Time
+	Parents    []string
+	Message    string
 }
 
 // File defines the votes cast for a single token in a commit. A commit can
-// votes cast for several commits joined together.
+// votes cast for several commits joined together. Version is the proposal
+// record version the votes were cast for and JournalType is the name of the
+// votes file without its extension e.g. "ballot". Change defines how the votes
+// file was changed in the commit. SkippedLines is the number of malformed
+// lines added to the votes file that were skipped.
 type File struct {
-	Token     string
-	VotesInfo Votes
+	Token        string
+	Version      string
+	JournalType  string
+	Change       ChangeType
+	VotesInfo    Votes
+	SkippedLines int
 }
 
 // Votes defines a slice type of votes cast data.
@@ -83,6 +99,75 @@ type Votes []CastVoteData
 type CastVoteData struct {
 	*PiVote `json:"castvote"`
 	// Receipt string `json:"receipt"`
+
+	// FlushedAt is the date of the commit that flushed the vote.
+	FlushedAt time.Time `json:"flushedat"`
+
+	// EstimatedCastAt is the time range the vote was cast in.
+	EstimatedCastAt TimeRange `json:"estimatedcastat"`
+}
+
+// TimeRange defines the time range an event happened in and the estimated
+// time it happened at. A zero Earliest time means that the start of the range
+// is unknown.
+type TimeRange struct {
+	Earliest time.Time `json:"earliest"`
+	Latest   time.Time `json:"latest"`
+	Estimate time.Time `json:"estimate"`
+}
+
+// IsExact returns true if the exact time the event happened at is known.
+func (r TimeRange) IsExact() bool {
+	return !r.Earliest.IsZero() && r.Earliest.Equal(r.Latest)
+}
+
+// ExactTimeRange returns the time range of an event whose exact time is known.
+func ExactTimeRange(t time.Time) TimeRange {
+	return TimeRange{Earliest: t, Latest: t, Estimate: t}
+}
+
+// setFlushTime sets the flush time of all the votes. Votes without an exact
+// cast time are estimated to have been cast at the flush time until
+// EstimateCastTimes is invoked.
+func (f *File) setFlushTime(flushedAt time.Time) {
+	for i := range f.VotesInfo {
+		vote := &f.VotesInfo[i]
+		vote.FlushedAt = flushedAt
+
+		if !vote.EstimatedCastAt.IsExact() {
+			vote.EstimatedCastAt = TimeRange{Latest: flushedAt, Estimate: flushedAt}
+		}
+	}
+}
+
+// EstimateCastTimes estimates when the votes without an exact cast time were
+// cast using the date of the previous flush of the same votes file. The votes
+// are appended to the votes file in the order they were cast thus they are
+// assumed to have been cast uniformly in that order between the previous flush
+// and the flush that committed them. A zero previousFlush is ignored.
+func (f *File) EstimateCastTimes(previousFlush time.Time) {
+	var estimated []int
+	for i, vote := range f.VotesInfo {
+		if !vote.EstimatedCastAt.IsExact() && previousFlush.Before(vote.FlushedAt) {
+			estimated = append(estimated, i)
+		}
+	}
+
+	if previousFlush.IsZero() || len(estimated) == 0 {
+		return
+	}
+
+	for pos, i := range estimated {
+		vote := &f.VotesInfo[i]
+		window := vote.FlushedAt.Sub(previousFlush)
+		offset := window * time.Duration(pos+1) / time.Duration(len(estimated)+1)
+
+		vote.EstimatedCastAt = TimeRange{
+			Earliest: previousFlush,
+			Latest:   vote.FlushedAt,
+			Estimate: previousFlush.Add(offset).Truncate(time.Second),
+		}
+	}
 }
 
 // PiVote defines the ticket hash and vote bit type details about a vote.
@@ -138,10 +223,20 @@ func (v *Votes) UnmarshalJSON(b []byte) error {
 
 // CustomUnmashaller unmarshals the string argument passed. Its not in a JSON
 // format. History unmarshalling happens ONLY for the set proposal token and
-// for all proposal tokens available if otherwise (not set).
+// for all proposal tokens available if otherwise (not set). The string is
+// expected to be in the legacy git journal layout.
 func CustomUnmashaller(h *History, str string, since ...time.Time) error {
+	return UnmarshalProposalHistory(h, GitJournalLayout{}, proposalToken, str, since...)
+}
+
+// UnmarshalProposalHistory unmarshals the string argument passed for the
+// provided proposal token using the provided record layout. If the token is
+// empty, history for all the proposal tokens available is unmarshalled. Unlike
+// CustomUnmashaller it does not depend on the package level proposal token
+// thus it is safe to be called concurrently.
+func UnmarshalProposalHistory(h *History, l Layout, token, str string, since ...time.Time) error {
 	// If no votes data detected, ignore the current str payload.
-	if isMatched := IsMatching(str, DefaultVotesCommitMsg); !isMatched {
+	if !l.IsVotesCommit(str) {
 		return nil
 	}
 
@@ -166,54 +261,38 @@ func CustomUnmashaller(h *History, str string, since ...time.Time) error {
 		return err // Missing Author
 	}
 
-	var changes []*File
-
-	for _, filePatch := range SplitCommitDiff(str) {
-
-		// If the proposal token has been set, check if this payload has the required
-		// proposal token data. If it exists proceed otherwise ignore it.
-		if isMatched := IsMatching(filePatch, VotesJSONSignature()); !isMatched {
-			continue
-		}
-
-		proposalToken, err := RetrieveProposalToken(filePatch)
-		if err != nil {
-			return err // Missing proposal token
-		}
-
-		filePatch = RetrieveAllPatchSelection(filePatch)
-
-		filePatch = ReplaceJournalSelection(filePatch, "")
+	changes, err := parsePatch(l, token, str, date)
+	if err != nil || len(changes) == 0 {
+		return err
+	}
 
-		// Drop any special characters left.
-		filePatch = ReplaceAny(filePatch, `\s`, "")
+	h.Author = author
+	h.CommitSHA = commit
+	h.Date = date
+	h.Patch = changes
 
-		// Add the square brackets and commas to complete the JSON string array format.
-		filePatch = "[" + ReplaceAny(filePatch, "}{", "},{") + "]"
+	return nil
+}
 
-		var v Votes
+// parsePatch parses the votes in the provided commit patch using the provided
+// record layout. The votes are flushed at the provided date.
+func parsePatch(l Layout, token, patch string, date time.Time) ([]*File, error) {
+	var changes []*File
 
-		if err = json.Unmarshal([]byte(filePatch), &v); err != nil {
-			panic(err)
-			return fmt.Errorf("Unmarshalling File failed: %v %s", err, filePatch)
+	for _, change := range ParseCommitDiff(patch) {
+		f, err := l.ParseFile(change, token)
+		if err != nil {
+			return nil, err
 		}
 
 		// If votes data was found, append it the File patch data else ignore it.
-		if len(v) > 0 {
-			changes = append(changes, &File{proposalToken, v})
+		if f != nil && len(f.VotesInfo) > 0 {
+			f.setFlushTime(date)
+			changes = append(changes, f)
 		}
 	}
 
-	if len(changes) == 0 {
-		return nil
-	}
-
-	h.Author = author
-	h.CommitSHA = commit
-	h.Date = date
-	h.Patch = changes
-
-	return nil
+	return changes, nil
 }
 
 // SetProposalToken sets the current proposal token string whose data is being
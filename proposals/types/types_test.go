@@ -0,0 +1,47 @@
+package types
+
+import (
+	"testing"
+	"time"
+)
+
+// TestEstimateCastTimes tests the estimation of the votes cast time between two
+// consecutive flushes.
+func TestEstimateCastTimes(t *testing.T) {
+	previous := time.Date(2019, 3, 5, 0, 58, 0, 0, time.UTC)
+	flushedAt := previous.Add(time.Hour)
+	exact := previous.Add(10 * time.Minute)
+
+	f := &File{VotesInfo: Votes{
+		{PiVote: &PiVote{Ticket: "aa"}},
+		{PiVote: &PiVote{Ticket: "bb"}, EstimatedCastAt: ExactTimeRange(exact)},
+		{PiVote: &PiVote{Ticket: "cc"}},
+	}}
+
+	f.setFlushTime(flushedAt)
+
+	for _, vote := range f.VotesInfo {
+		if !vote.FlushedAt.Equal(flushedAt) {
+			t.Fatalf("expected the flush time to be %v but found %v", flushedAt, vote.FlushedAt)
+		}
+	}
+
+	if r := f.VotesInfo[0].EstimatedCastAt; !r.Earliest.IsZero() || !r.Estimate.Equal(flushedAt) {
+		t.Fatalf("expected an open range ending at the flush time but found %v", r)
+	}
+
+	f.EstimateCastTimes(previous)
+
+	expected := []TimeRange{
+		{previous, flushedAt, previous.Add(20 * time.Minute)},
+		ExactTimeRange(exact),
+		{previous, flushedAt, previous.Add(40 * time.Minute)},
+	}
+
+	for i, vote := range f.VotesInfo {
+		if vote.EstimatedCastAt != expected[i] {
+			t.Fatalf("expected vote #%d range to be %v but found %v", i,
+				expected[i], vote.EstimatedCastAt)
+		}
+	}
+}
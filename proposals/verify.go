@@ -0,0 +1,163 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package proposals
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// isAncestorArg makes merge-base fail unless the first commit is an
+	// ancestor of the second one.
+	isAncestorArg = "--is-ancestor"
+
+	// signatureFormatArg formats each commit listed as its SHA, its signature
+	// status and the fingerprint of the key that signed it.
+	signatureFormatArg = "--format=%H %G? %GF"
+
+	// goodSignature is the signature status of a valid signature made by a
+	// trusted key.
+	goodSignature = "G"
+
+	// unknownValiditySignature is the signature status of a valid signature
+	// made by a key that isn't trusted e.g. an SSH key missing in the allowed
+	// signers file.
+	unknownValiditySignature = "U"
+
+	// gnupgHomeEnv sets the GnuPG home directory used by git to verify the
+	// GPG signatures.
+	gnupgHomeEnv = "GNUPGHOME"
+
+	// allowedSignersConfig is the git configuration that sets the file
+	// listing the SSH keys trusted to sign commits.
+	allowedSignersConfig = "gpg.ssh.allowedSignersFile"
+)
+
+// SignaturePolicy defines the keys that the commits fetched must be signed by.
+// The commits are verified by git thus the GPG signatures require gpg to be
+// installed and the SSH signatures require git v2.34.0 or later.
+type SignaturePolicy struct {
+	// GPGHome is the GnuPG home directory holding the keyring of the GPG keys
+	// trusted to sign the commits. The default keyring is used if empty.
+	GPGHome string
+
+	// AllowedSignersFile is the file listing the SSH keys trusted to sign the
+	// commits, in the ssh-keygen allowed signers format.
+	AllowedSignersFile string
+
+	// Fingerprints lists the fingerprints of the keys accepted e.g. the GPG
+	// key fingerprint or the SSH "SHA256:..." fingerprint. If set, only the
+	// commits signed by the listed keys are accepted, even if the keys aren't
+	// trusted by the keyring or the allowed signers file. Otherwise all the
+	// trusted keys are accepted.
+	Fingerprints []string
+}
+
+// env returns the environment variables that point git and gpg to the
+// configured keys.
+func (s *SignaturePolicy) env() []string {
+	var env []string
+	if s.GPGHome != "" {
+		env = append(env, gnupgHomeEnv+"="+s.GPGHome)
+	}
+
+	if s.AllowedSignersFile != "" {
+		env = append(env, "GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0="+allowedSignersConfig,
+			"GIT_CONFIG_VALUE_0="+s.AllowedSignersFile)
+	}
+	return env
+}
+
+// accepts returns true if the signature status and key fingerprint reported by
+// git satisfy the policy.
+func (s *SignaturePolicy) accepts(status, fingerprint string) bool {
+	if len(s.Fingerprints) == 0 {
+		return status == goodSignature
+	}
+
+	if status != goodSignature && status != unknownValiditySignature {
+		return false
+	}
+
+	for _, f := range s.Fingerprints {
+		if strings.EqualFold(f, fingerprint) {
+			return true
+		}
+	}
+	return false
+}
+
+// VerificationError is returned by the updates whose history fails the
+// authenticity checks. The snapshot previously pinned is kept, even after the
+// repository was recloned. The queries then fail if the new clone misses it.
+type VerificationError struct {
+	// SHA is the commit that failed the checks.
+	SHA string
+
+	// Reason describes why the commit failed the checks.
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("verifying the commit %s failed: %s", e.SHA, e.Reason)
+}
+
+// verifyHistory checks that the history of the new snapshot holds all the
+// pinned commits, and that the commits made after the previous snapshot are
+// signed as required by the signature policy. The commits reachable from the
+// pinned commits are trusted thus not verified. A *VerificationError is
+// returned if the checks fail.
+func (p *Parser) verifyHistory(previousSHA, headSHA string) error {
+	for _, sha := range p.pinnedSHAs {
+		_, err := p.readCommandOutput(gitCmd, mergeBaseArg, isAncestorArg, sha, headSHA)
+		if err != nil {
+			return &VerificationError{SHA: sha, Reason: "the pinned commit is missing in the history of " + headSHA}
+		}
+	}
+
+	if p.signatures == nil {
+		return nil
+	}
+
+	revs := []string{headSHA}
+	for _, sha := range p.pinnedSHAs {
+		revs = append(revs, excludeRevPrefix+sha)
+	}
+
+	// The previous snapshot is missing after the repository was recloned.
+	if previousSHA != "" {
+		_, err := p.readCommandOutput(gitCmd, revParseArg, verifyRevArg,
+			previousSHA+commitRevSuffix)
+		if err == nil {
+			revs = append(revs, excludeRevPrefix+previousSHA)
+		}
+	}
+
+	args := append([]string{listCommitsArg, signatureFormatArg}, revs...)
+	out, err := p.readCommandOutput(gitCmd, args...)
+	if err != nil {
+		return fmt.Errorf("listing the commit signatures failed: %v", err)
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		var fingerprint string
+		if len(fields) > 2 {
+			fingerprint = fields[2]
+		}
+
+		if !p.signatures.accepts(fields[1], fingerprint) {
+			return &VerificationError{SHA: fields[0],
+				Reason: "the commit is not signed by an accepted key (status " + fields[1] + ")"}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,187 @@
+package proposals
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newTestKey generates an SSH signing key in the provided directory and
+// returns its path and fingerprint.
+func newTestKey(t *testing.T, dir, name string) (string, string) {
+	key := filepath.Join(dir, name)
+	out, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-f", key).CombinedOutput()
+	if err != nil {
+		t.Skipf("generating an SSH key failed: %v: %s", err, out)
+	}
+
+	out, err = exec.Command("ssh-keygen", "-l", "-f", key+".pub").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, strings.Fields(string(out))[1]
+}
+
+// TestPinnedCommits tests that the histories missing a pinned commit are
+// refused.
+func TestPinnedCommits(t *testing.T) {
+	p := newTestParser(t)
+	sha := runGit(t, filepath.Join(p.cloneDir, cloneRepoAlias), "rev-parse", "HEAD")
+
+	td := []struct {
+		pinned  []string
+		isError bool
+	}{
+		{[]string{sha}, false},
+		{[]string{sha[:12]}, false},
+		{[]string{sha, strings.Repeat("f", 40)}, true},
+	}
+
+	for i, val := range td {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			p.headSHA = ""
+			p.pinnedSHAs = val.pinned
+
+			err := p.pinSnapshot()
+			if _, ok := err.(*VerificationError); ok != val.isError {
+				t.Fatalf("expected a verification error to be %v but found: %v",
+					val.isError, err)
+			}
+
+			if val.isError && p.HeadSHA() != "" {
+				t.Fatalf("expected no snapshot pinned but found %s", p.HeadSHA())
+			}
+		})
+	}
+}
+
+// TestSignedCommits tests that only the commits signed by the accepted keys
+// are pinned.
+func TestSignedCommits(t *testing.T) {
+	p := newTestParser(t)
+	repoDir := filepath.Join(p.cloneDir, cloneRepoAlias)
+
+	keysDir, err := ioutil.TempDir(testDir, "keys-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trusted, _ := newTestKey(t, keysDir, "trusted")
+	other, otherFingerprint := newTestKey(t, keysDir, "other")
+
+	pub, err := ioutil.ReadFile(trusted + ".pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	allowedSigners := filepath.Join(keysDir, "allowed_signers")
+	if err = ioutil.WriteFile(allowedSigners, append([]byte("noreply@decred.org "), pub...), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The unsigned initial commit is trusted.
+	WithPinnedCommits(runGit(t, repoDir, "rev-parse", "HEAD"))(p)
+	runGit(t, repoDir, "config", "gpg.format", "ssh")
+
+	td := []struct {
+		signingKey   string
+		fingerprints []string
+		isError      bool
+	}{
+		{trusted, nil, false},
+		{other, nil, true},
+		{"", nil, true},
+		{other, []string{otherFingerprint}, false},
+		{trusted, []string{otherFingerprint}, true},
+	}
+
+	for i, val := range td {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			WithSignedCommits(SignaturePolicy{AllowedSignersFile: allowedSigners,
+				Fingerprints: val.fingerprints})(p)
+
+			if err := p.pinSnapshot(); err != nil {
+				t.Fatalf("expected no error but found: %v", err)
+			}
+			previousSHA := p.HeadSHA()
+
+			sign := "false"
+			if val.signingKey != "" {
+				sign = "true"
+				runGit(t, repoDir, "config", "user.signingkey", val.signingKey)
+			}
+			runGit(t, repoDir, "config", "commit.gpgsign", sign)
+
+			commitVotes(t, repoDir, testToken, "Mon Nov 5 18:58:13 2018 +0000",
+				testVote(testToken, strings.Repeat(strconv.Itoa(i), 64), "1"))
+
+			err := p.pinSnapshot()
+			if _, ok := err.(*VerificationError); ok != val.isError {
+				t.Fatalf("expected a verification error to be %v but found: %v",
+					val.isError, err)
+			}
+
+			if !val.isError {
+				return
+			}
+
+			if p.HeadSHA() != previousSHA {
+				t.Fatalf("expected the snapshot %s to be kept but found %s",
+					previousSHA, p.HeadSHA())
+			}
+
+			// Drop the refused commit.
+			runGit(t, repoDir, "reset", "-q", "--hard", previousSHA)
+		})
+	}
+}
+
+// TestRecloneVerification tests that the votes of a recloned history missing
+// the pinned commits are never queried.
+func TestRecloneVerification(t *testing.T) {
+	p := newTestParser(t, WithRetries(0, 0))
+	repoDir := filepath.Join(p.cloneDir, cloneRepoAlias)
+	commitVotes(t, repoDir, testToken, "Mon Nov 5 17:58:13 2018 +0000",
+		testVote(testToken, strings.Repeat("d", 64), "1"))
+	WithPinnedCommits(runGit(t, repoDir, "rev-parse", "HEAD"))(p)
+
+	if err := p.pinSnapshot(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+	sha := p.HeadSHA()
+
+	// The origin history does not hold the pinned commit.
+	origin := newTestOrigin(t)
+	ticket := strings.Repeat("c", 64)
+	commitVotes(t, origin, testToken, "Mon Nov 5 18:58:13 2018 +0000",
+		testVote(testToken, ticket, "2"))
+
+	p.repoURL = origin
+	err := p.updateEnv()
+	if _, ok := err.(*VerificationError); !ok {
+		t.Fatalf("expected a verification error but found: %v", err)
+	}
+
+	if p.HeadSHA() != sha {
+		t.Fatalf("expected the snapshot %s to be kept but found %s", sha, p.HeadSHA())
+	}
+
+	// The queries fail rather than reading the unverified clone.
+	history, err := p.ProposalsHistory()
+	if err == nil {
+		t.Fatalf("expected an error but none was returned")
+	}
+
+	for _, h := range history {
+		for _, f := range h.Patch {
+			for _, v := range f.VotesInfo {
+				if v.PiVote != nil && v.Ticket == ticket {
+					t.Fatalf("expected the unverified votes not to be queried")
+				}
+			}
+		}
+	}
+}
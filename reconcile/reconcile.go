@@ -0,0 +1,136 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+// Package reconcile cross-checks the vote tallies derived from the parsed
+// votes against reference tallies, such as a Politeia vote results dump or a
+// dcrdata API response, and reports the proposals whose vote counts or ticket
+// sets mismatch. It helps catch the parser regressions.
+package reconcile
+
+import (
+	"sort"
+
+	"github.com/dmigwi/go-piparser/proposals/types"
+)
+
+// Result holds the comparison of the parsed and the reference tallies of a
+// proposal.
+type Result struct {
+	Token     string       `json:"token"`
+	Parsed    *types.Tally `json:"parsed"`
+	Reference *types.Tally `json:"reference"`
+
+	// TicketsCompared is false if the reference only reports the counts thus
+	// the ticket sets were not compared.
+	TicketsCompared bool `json:"tickets_compared"`
+
+	// MissingTickets voted in the reference but were not parsed.
+	MissingTickets []string `json:"missing_tickets,omitempty"`
+
+	// ExtraTickets were parsed but did not vote in the reference.
+	ExtraTickets []string `json:"extra_tickets,omitempty"`
+
+	// ChangedTickets voted for a different option in the reference.
+	ChangedTickets []string `json:"changed_tickets,omitempty"`
+}
+
+// Matches returns true if the parsed tally and tickets match the reference.
+func (r *Result) Matches() bool {
+	if r.Parsed.Total != r.Reference.Total || len(r.MissingTickets) > 0 ||
+		len(r.ExtraTickets) > 0 || len(r.ChangedTickets) > 0 {
+		return false
+	}
+
+	for option, votes := range r.Reference.Options {
+		if r.Parsed.Options[option] != votes {
+			return false
+		}
+	}
+
+	for option, votes := range r.Parsed.Options {
+		if r.Reference.Options[option] != votes {
+			return false
+		}
+	}
+	return true
+}
+
+// Report holds the comparison results of all the reference proposals ordered
+// by token.
+type Report struct {
+	Results []*Result `json:"results"`
+}
+
+// Mismatches returns the results that do not match the reference.
+func (r *Report) Mismatches() []*Result {
+	var mismatches []*Result
+	for _, res := range r.Results {
+		if !res.Matches() {
+			mismatches = append(mismatches, res)
+		}
+	}
+	return mismatches
+}
+
+// Compare tallies the votes in the history per proposal and compares them to
+// the references. Only the proposals with a reference are compared thus the
+// history may hold other proposals. As by Politeia, only the first vote cast by
+// each ticket is counted.
+func Compare(history []*types.History, refs map[string]*Reference) *Report {
+	parsed := make(map[string]*Reference)
+	for _, h := range history {
+		for _, f := range h.Patch {
+			if _, ok := refs[f.Token]; !ok {
+				continue
+			}
+
+			p := reference(parsed, f.Token)
+			for _, v := range f.VotesInfo {
+				if _, ok := p.Tickets[v.Ticket]; v.PiVote == nil || ok {
+					continue
+				}
+
+				p.Tickets[v.Ticket] = string(v.VoteBit)
+				p.Tally.Options[string(v.VoteBit)]++
+				p.Tally.Total++
+			}
+		}
+	}
+
+	report := new(Report)
+	for token, ref := range refs {
+		p := reference(parsed, token)
+		res := &Result{Token: token, Parsed: p.Tally, Reference: ref.Tally,
+			TicketsCompared: ref.Tickets != nil}
+
+		if res.TicketsCompared {
+			for ticket, option := range ref.Tickets {
+				parsedOption, ok := p.Tickets[ticket]
+				switch {
+				case !ok:
+					res.MissingTickets = append(res.MissingTickets, ticket)
+				case parsedOption != option:
+					res.ChangedTickets = append(res.ChangedTickets, ticket)
+				}
+			}
+
+			for ticket := range p.Tickets {
+				if _, ok := ref.Tickets[ticket]; !ok {
+					res.ExtraTickets = append(res.ExtraTickets, ticket)
+				}
+			}
+
+			sort.Strings(res.MissingTickets)
+			sort.Strings(res.ExtraTickets)
+			sort.Strings(res.ChangedTickets)
+		}
+
+		report.Results = append(report.Results, res)
+	}
+
+	sort.Slice(report.Results, func(i, j int) bool {
+		return report.Results[i].Token < report.Results[j].Token
+	})
+
+	return report
+}
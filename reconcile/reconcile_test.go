@@ -0,0 +1,216 @@
+package reconcile
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/dmigwi/go-piparser/proposals/types"
+	"github.com/dmigwi/go-piparser/v1/data"
+)
+
+const testToken = "27f87171d98b7923a1bd2bee6affed929fa2d2a6e178b5c80a9971a92a5c7f50"
+
+// politeiaDump returns a Politeia vote results reply listing the votes of the
+// test token found in the history fixtures. The votebits are encoded back.
+func politeiaDump(history []*types.History) string {
+	bits := map[string]string{"No": "1", "Yes": "2"}
+
+	var votes []string
+	for _, h := range history {
+		for _, f := range h.Patch {
+			if f.Token != testToken {
+				continue
+			}
+
+			for _, v := range f.VotesInfo {
+				votes = append(votes, `{"token":"`+f.Token+`","ticket":"`+v.Ticket+
+					`","votebit":"`+bits[string(v.VoteBit)]+`","signature":"1f23"}`)
+			}
+		}
+	}
+
+	return `{"startvote":{"vote":{"token":"` + testToken + `"}},"castvotes":[` +
+		strings.Join(votes, ",") + `]}`
+}
+
+// TestParse tests the supported reference formats.
+func TestParse(t *testing.T) {
+	ticket := strings.Repeat("a", 64)
+
+	td := []struct {
+		input   string
+		total   int
+		yes     int
+		tickets bool
+		isError bool
+	}{
+		{`{"castvotes":[{"token":"` + testToken + `","ticket":"` + ticket + `","votebit":"2"},` +
+			`{"token":"` + testToken + `","ticket":"` + ticket + `","votebit":"1"}]}`, 1, 1, true, false},
+		{`{"votes":[{"token":"` + testToken + `","ticket":"` + ticket + `","votebit":"1"}]}`, 1, 0, true, false},
+		{`[{"token":"` + testToken + `","totalvotes":5,"voteresults":[` +
+			`{"option":{"id":"yes","bits":2},"votesreceived":3},` +
+			`{"option":{"id":"no","bits":1},"votesreceived":2}]}]`, 5, 3, false, false},
+		{`{"censorshiprecord":{"token":"` + testToken + `"},"voteresults":[` +
+			`{"option":{"id":"yes"},"votesreceived":4}]}`, 4, 4, false, false},
+		{`{"startvote":{"vote":{"token":"` + testToken + `"}},"castvotes":[]}`, 0, 0, true, false},
+		{`{"voteresults":[{"option":{"id":"yes"},"votesreceived":4}]}`, 0, 0, false, true},
+		{`{"castvotes":[{"ticket":"` + ticket + `","votebit":"1"}]}`, 0, 0, false, true},
+		{`{"status":"ok"}`, 0, 0, false, true},
+		{`{`, 0, 0, false, true},
+	}
+
+	for i, val := range td {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			refs, err := Parse(strings.NewReader(val.input))
+			if (err != nil) != val.isError {
+				t.Fatalf("expected an error to be %v but found: %v", val.isError, err)
+			}
+
+			if val.isError {
+				return
+			}
+
+			ref, ok := refs[testToken]
+			if !ok {
+				t.Fatalf("expected the reference of %s but found none", testToken)
+			}
+
+			if ref.Tally.Total != val.total || ref.Tally.Options["Yes"] != val.yes {
+				t.Fatalf("expected %d votes with %d Yes but found %+v", val.total,
+					val.yes, ref.Tally)
+			}
+
+			if (ref.Tickets != nil) != val.tickets {
+				t.Fatalf("expected the tickets to be listed to be %v", val.tickets)
+			}
+		})
+	}
+}
+
+// TestCompare tests the mismatches found against the reference tallies.
+func TestCompare(t *testing.T) {
+	dump := politeiaDump(data.AllTokensVotesData)
+	refs, err := Parse(strings.NewReader(dump))
+	if err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	report := Compare(data.AllTokensVotesData, refs)
+	if len(report.Results) != 1 || len(report.Mismatches()) != 0 {
+		t.Fatalf("expected a single matching proposal but found %d mismatches",
+			len(report.Mismatches()))
+	}
+
+	// Drop a vote, change another and add an extra one to the reference.
+	ref := refs[testToken]
+	var dropped, changed string
+	for ticket, option := range ref.Tickets {
+		switch {
+		case dropped == "":
+			dropped = ticket
+			delete(ref.Tickets, ticket)
+			ref.Tally.Options[option]--
+			ref.Tally.Total--
+
+		case changed == "" && option == "Yes":
+			changed = ticket
+			ref.Tickets[ticket] = "No"
+			ref.Tally.Options["Yes"]--
+			ref.Tally.Options["No"]++
+		}
+	}
+
+	missing := strings.Repeat("f", 64)
+	ref.Tickets[missing] = "Yes"
+	ref.Tally.Options["Yes"]++
+	ref.Tally.Total++
+
+	res := Compare(data.AllTokensVotesData, refs).Results[0]
+	if res.Matches() {
+		t.Fatalf("expected a mismatch but found none")
+	}
+
+	if len(res.MissingTickets) != 1 || res.MissingTickets[0] != missing ||
+		len(res.ExtraTickets) != 1 || res.ExtraTickets[0] != dropped ||
+		len(res.ChangedTickets) != 1 || res.ChangedTickets[0] != changed {
+		t.Fatalf("expected the tickets %s missing, %s extra and %s changed but found %+v",
+			missing, dropped, changed, res)
+	}
+
+	// The counts only references are compared by the counts.
+	ref.Tickets = nil
+	ref.Tally = copyTally(res.Parsed)
+	if res = Compare(data.AllTokensVotesData, refs).Results[0]; !res.Matches() {
+		t.Fatalf("expected the counts to match but found %+v", res)
+	}
+
+	ref.Tally.Options["Yes"]--
+	ref.Tally.Options["No"]++
+	if res = Compare(data.AllTokensVotesData, refs).Results[0]; res.Matches() {
+		t.Fatalf("expected the counts to mismatch but found %+v", res)
+	}
+
+	// The proposals missing in the history are reported.
+	refs = map[string]*Reference{"other": reference(map[string]*Reference{}, "other")}
+	refs["other"].Tally.Total = 1
+	if report = Compare(data.AllTokensVotesData, refs); len(report.Mismatches()) != 1 {
+		t.Fatalf("expected the missing proposal mismatch but found none")
+	}
+}
+
+// copyTally returns a copy of the tally.
+func copyTally(t *types.Tally) *types.Tally {
+	c := &types.Tally{Token: t.Token, Total: t.Total, Options: make(map[string]int)}
+	for option, votes := range t.Options {
+		c.Options[option] = votes
+	}
+	return c
+}
+
+// TestLoad tests loading the references from a file and a local HTTP server.
+func TestLoad(t *testing.T) {
+	dump := politeiaDump(data.AllTokensVotesData)
+
+	path := filepath.Join(t.TempDir(), "results.json")
+	if err := ioutil.WriteFile(path, []byte(dump), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/vote/results" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(dump))
+	}))
+	defer ts.Close()
+
+	td := []struct {
+		source  string
+		isError bool
+	}{
+		{path, false},
+		{ts.URL + "/vote/results", false},
+		{ts.URL + "/missing", true},
+		{filepath.Join(t.TempDir(), "missing.json"), true},
+	}
+
+	for i, val := range td {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			refs, err := Load(context.Background(), val.source)
+			if (err != nil) != val.isError {
+				t.Fatalf("expected an error to be %v but found: %v", val.isError, err)
+			}
+
+			if !val.isError && len(refs[testToken].Tickets) == 0 {
+				t.Fatalf("expected the tickets of %s but found none", testToken)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package reconcile
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/dmigwi/go-piparser/proposals/types"
+)
+
+// Reference holds the reference tally of a proposal.
+type Reference struct {
+	Tally *types.Tally
+
+	// Tickets maps the tickets that voted to their vote option. It is nil if
+	// the reference source only reports the counts e.g. the dcrdata responses.
+	Tickets map[string]string
+}
+
+// castVote is a vote listed by the Politeia vote results. The token is set on
+// every vote. The votebit is decoded into the vote option as parsed from the
+// repository e.g. "Yes".
+type castVote struct {
+	Token string `json:"token"`
+	types.PiVote
+}
+
+// voteResult is the number of votes received by a vote option as reported by
+// the dcrdata proposals API.
+type voteResult struct {
+	Option struct {
+		ID   string `json:"id"`
+		Bits int    `json:"bits"`
+	} `json:"option"`
+	VotesReceived int `json:"votesreceived"`
+}
+
+// referenceRecord holds the fields of the supported reference formats:
+//   - the politeiawww v1 /proposals/{token}/votes (vote results) reply that
+//     lists the votes as "castvotes" and the vote params as "startvote",
+//   - the ticketvote v1 /results reply that lists the votes as "votes",
+//   - the dcrdata /api/proposals/{token} response that holds the counts per
+//     vote option as "voteresults".
+type referenceRecord struct {
+	CastVotes []castVote `json:"castvotes"`
+	Votes     []castVote `json:"votes"`
+	StartVote struct {
+		Vote struct {
+			Token string `json:"token"`
+		} `json:"vote"`
+	} `json:"startvote"`
+
+	Token            string `json:"token"`
+	CensorshipRecord struct {
+		Token string `json:"token"`
+	} `json:"censorshiprecord"`
+	VoteResults []voteResult `json:"voteresults"`
+	TotalVotes  int          `json:"totalvotes"`
+}
+
+// Parse reads the reference tallies from a Politeia vote results reply or a
+// dcrdata proposal response. A JSON array of either is accepted too. The
+// references are mapped by the proposal token.
+func Parse(r io.Reader) (map[string]*Reference, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*referenceRecord
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		err = json.Unmarshal(data, &records)
+	} else {
+		record := new(referenceRecord)
+		err = json.Unmarshal(data, record)
+		records = append(records, record)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("decoding the reference tallies failed: %v", err)
+	}
+
+	refs := make(map[string]*Reference)
+	for _, record := range records {
+		if err = record.addTo(refs); err != nil {
+			return nil, err
+		}
+	}
+
+	return refs, nil
+}
+
+// addTo adds the tallies of the record to refs.
+func (r *referenceRecord) addTo(refs map[string]*Reference) error {
+	votes := append(r.CastVotes, r.Votes...)
+	switch {
+	case len(votes) > 0:
+		for _, v := range votes {
+			if v.Token == "" {
+				return fmt.Errorf("the proposal token of the vote cast by %s is missing",
+					v.Ticket)
+			}
+
+			ref := reference(refs, v.Token)
+
+			// Politeia rejects the repeated votes of a ticket.
+			if _, ok := ref.Tickets[v.Ticket]; ok {
+				continue
+			}
+
+			ref.Tickets[v.Ticket] = string(v.VoteBit)
+			ref.Tally.Options[string(v.VoteBit)]++
+			ref.Tally.Total++
+		}
+
+	case len(r.VoteResults) > 0 || r.TotalVotes > 0:
+		token := r.Token
+		if token == "" {
+			token = r.CensorshipRecord.Token
+		}
+
+		if token == "" {
+			return fmt.Errorf("the proposal token of the vote results is missing")
+		}
+
+		ref := reference(refs, token)
+		ref.Tickets = nil
+		for _, res := range r.VoteResults {
+			ref.Tally.Options[optionName(res.Option.ID, res.Option.Bits)] += res.VotesReceived
+			ref.Tally.Total += res.VotesReceived
+		}
+
+		if r.TotalVotes > 0 {
+			ref.Tally.Total = r.TotalVotes
+		}
+
+	// The vote results of a proposal that has not received any vote yet.
+	case r.StartVote.Vote.Token != "":
+		reference(refs, r.StartVote.Vote.Token)
+
+	default:
+		return fmt.Errorf("no vote results found in the reference record")
+	}
+
+	return nil
+}
+
+// reference returns the reference of the token in refs, adding it if missing.
+func reference(refs map[string]*Reference, token string) *Reference {
+	ref, ok := refs[token]
+	if !ok {
+		ref = &Reference{
+			Tally:   &types.Tally{Token: token, Options: make(map[string]int)},
+			Tickets: make(map[string]string),
+		}
+		refs[token] = ref
+	}
+	return ref
+}
+
+// optionName returns the vote option name used by the parsed votes for the
+// provided option id and bits.
+func optionName(id string, bits int) string {
+	switch bits {
+	case 1:
+		return "No"
+	case 2:
+		return "Yes"
+	}
+
+	if id == "" {
+		return "Unknown"
+	}
+	return strings.ToUpper(id[:1]) + id[1:]
+}
+
+// Load reads the reference tallies from the provided source, either an
+// http(s) URL or a file path.
+func Load(ctx context.Context, source string) (map[string]*Reference, error) {
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		return Fetch(ctx, http.DefaultClient, source)
+	}
+
+	f, err := os.Open(source)
+	if err != nil {
+		return nil, fmt.Errorf("opening the reference file failed: %v", err)
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
+
+// Fetch requests the reference tallies from the provided URL e.g. a dcrdata
+// API or a local stand-in serving a Politeia dump.
+func Fetch(ctx context.Context, client *http.Client, url string) (map[string]*Reference, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching the reference tallies failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching the reference tallies failed: unexpected response status %s",
+			resp.Status)
+	}
+
+	return Parse(resp.Body)
+}
@@ -0,0 +1,286 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+// Package rpcserver implements the piparserpb.Piparser gRPC service on top of
+// the proposals.Parser queries.
+package rpcserver
+
+import (
+	"context"
+	"time"
+
+	"github.com/dmigwi/go-piparser/proposals"
+	"github.com/dmigwi/go-piparser/proposals/types"
+	"github.com/dmigwi/go-piparser/v1/export"
+	pb "github.com/dmigwi/go-piparser/v1/piparserpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// updatesBuffer is the number of parser updates buffered per StreamVotes
+	// call.
+	updatesBuffer = 16
+
+	// historyBuffer is the number of commits queued per ProposalHistory call
+	// while the client falls behind.
+	historyBuffer = 64
+)
+
+// Source defines the proposals.Parser methods used to serve the gRPC calls.
+type Source interface {
+	HeadSHA() string
+	ProposalTokens() ([]string, error)
+	WalkProposalHistory(proposalToken string, since time.Time, fn proposals.WalkFunc,
+		filters ...proposals.Filter) error
+	WalkProposalsHistory(since time.Time, fn proposals.WalkFunc, filters ...proposals.Filter) error
+	Subscribe(buffer int) (<-chan *proposals.Update, func())
+}
+
+// Server implements the piparserpb.PiparserServer interface.
+type Server struct {
+	pb.UnimplementedPiparserServer
+	source Source
+}
+
+// New returns a Server that serves the votes data queried from the source.
+func New(source Source) *Server {
+	return &Server{source: source}
+}
+
+// Register registers the Piparser service backed by the source on s.
+func Register(s *grpc.Server, source Source) {
+	pb.RegisterPiparserServer(s, New(source))
+}
+
+// ListProposals lists the tokens of the proposals found in the repository.
+func (s *Server) ListProposals(ctx context.Context, req *pb.ListProposalsRequest) (*pb.ListProposalsResponse, error) {
+	headSHA := s.source.HeadSHA()
+
+	tokens, err := s.source.ProposalTokens()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "listing the proposals failed: %v", err)
+	}
+
+	return &pb.ListProposalsResponse{HeadSha: headSHA, Tokens: tokens}, nil
+}
+
+// ProposalHistory streams the commits with votes of the requested proposal or
+// of all the proposals if no token is set.
+func (s *Server) ProposalHistory(req *pb.ProposalHistoryRequest, stream pb.Piparser_ProposalHistoryServer) error {
+	if req.Token != "" && !types.IsToken(req.Token) {
+		return status.Errorf(codes.InvalidArgument, "invalid proposal token %q", req.Token)
+	}
+
+	filters := dateFilters(req.Until)
+	if req.Version != "" {
+		filters = append(filters, proposals.VersionFilter(req.Version))
+	}
+
+	// The commits are sent from a separate goroutine so that the history
+	// keeps being parsed while the client catches up.
+	send, wait := proposals.BufferedWalk(func(h *types.History) error {
+		if err := stream.Context().Err(); err != nil {
+			return err
+		}
+		return stream.Send(commit(h))
+	}, historyBuffer)
+
+	var err error
+	if req.Token == "" {
+		err = s.source.WalkProposalsHistory(timeOf(req.Since), send, filters...)
+	} else {
+		err = s.source.WalkProposalHistory(req.Token, timeOf(req.Since), send, filters...)
+	}
+
+	if sendErr := wait(); sendErr != nil {
+		return sendErr
+	}
+
+	if err != nil {
+		return status.Errorf(codes.Internal, "fetching the proposal history failed: %v", err)
+	}
+	return nil
+}
+
+// Tally counts the votes cast per vote option on the requested proposal.
+func (s *Server) Tally(ctx context.Context, req *pb.TallyRequest) (*pb.TallyResponse, error) {
+	if !types.IsToken(req.Token) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid proposal token %q", req.Token)
+	}
+
+	var history []*types.History
+	err := s.source.WalkProposalHistory(req.Token, timeOf(req.Since), func(h *types.History) error {
+		history = append(history, h)
+		return ctx.Err()
+	}, dateFilters(req.Until)...)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "fetching the proposal history failed: %v", err)
+	}
+
+	resp := &pb.TallyResponse{Token: req.Token, Options: make(map[string]int64)}
+	if tally, ok := types.TallyVotes(history)[req.Token]; ok {
+		for option, count := range tally.Options {
+			resp.Options[option] = int64(count)
+		}
+		resp.Total = int64(tally.Total)
+	}
+
+	return resp, nil
+}
+
+// TicketVotes lists the votes cast by the requested ticket.
+func (s *Server) TicketVotes(ctx context.Context, req *pb.TicketVotesRequest) (*pb.TicketVotesResponse, error) {
+	if !types.IsTicket(req.Ticket) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid ticket %q", req.Ticket)
+	}
+
+	filters := append(dateFilters(req.Until), proposals.TicketFilter(req.Ticket))
+
+	resp := new(pb.TicketVotesResponse)
+	err := s.source.WalkProposalsHistory(timeOf(req.Since), func(h *types.History) error {
+		for _, row := range export.Rows(h) {
+			resp.Votes = append(resp.Votes, voteRecord(row))
+		}
+		return ctx.Err()
+	}, filters...)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "fetching the ticket votes failed: %v", err)
+	}
+
+	return resp, nil
+}
+
+// StreamVotes streams the commits fetched by each repository update until the
+// client cancels the call. Only the votes of the requested tokens are sent if
+// any tokens are set. The commits dropped by a force-push are sent as the
+// update rollback, with or without new commits.
+func (s *Server) StreamVotes(req *pb.StreamVotesRequest, stream pb.Piparser_StreamVotesServer) error {
+	tokens := make(map[string]bool)
+	for _, token := range req.Tokens {
+		if !types.IsToken(token) {
+			return status.Errorf(codes.InvalidArgument, "invalid proposal token %q", token)
+		}
+		tokens[token] = true
+	}
+
+	updates, cancel := s.source.Subscribe(updatesBuffer)
+	defer cancel()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+
+		case u, ok := <-updates:
+			if !ok {
+				return status.Errorf(codes.Unavailable, "the updates subscription was closed")
+			}
+
+			msg := &pb.VotesUpdate{PreviousSha: u.PreviousSHA, HeadSha: u.HeadSHA}
+			for _, h := range u.History {
+				if c := commit(h); len(tokens) == 0 || filterFiles(c, tokens) {
+					msg.Commits = append(msg.Commits, c)
+				}
+			}
+
+			if r := u.Rollback; r != nil {
+				msg.Rollback = &pb.Rollback{ForkPoint: r.ForkPoint,
+					DroppedShas: r.Commits, Unknown: r.Unknown}
+			}
+
+			if len(msg.Commits) == 0 && msg.Rollback == nil {
+				continue
+			}
+
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// filterFiles drops the commit files of the tokens not set. It returns false
+// if no file is left.
+func filterFiles(c *pb.Commit, tokens map[string]bool) bool {
+	var files []*pb.FileVotes
+	for _, f := range c.Files {
+		if tokens[f.Token] {
+			files = append(files, f)
+		}
+	}
+
+	c.Files = files
+	return len(files) > 0
+}
+
+// dateFilters returns the query filters of the until date if it is set.
+func dateFilters(until *timestamppb.Timestamp) []proposals.Filter {
+	if until == nil {
+		return nil
+	}
+	return []proposals.Filter{proposals.UntilFilter(until.AsTime())}
+}
+
+// timeOf returns the time of the timestamp or a zero time if it is not set.
+func timeOf(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
+
+// timestamp returns the timestamp of t or nil if t is zero.
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// commit converts the history into its protobuf message.
+func commit(h *types.History) *pb.Commit {
+	c := &pb.Commit{Sha: h.CommitSHA, Author: h.Author, Date: timestamp(h.Date),
+		Parents: h.Parents}
+
+	for _, f := range h.Patch {
+		file := &pb.FileVotes{Token: f.Token, Version: f.Version,
+			JournalType: f.JournalType, Change: string(f.Change)}
+
+		for _, v := range f.VotesInfo {
+			if v.PiVote == nil {
+				continue
+			}
+
+			file.Votes = append(file.Votes, &pb.Vote{
+				Ticket:    v.Ticket,
+				Option:    string(v.VoteBit),
+				FlushedAt: timestamp(v.FlushedAt),
+				EstimatedCastAt: &pb.TimeRange{
+					Earliest: timestamp(v.EstimatedCastAt.Earliest),
+					Latest:   timestamp(v.EstimatedCastAt.Latest),
+					Estimate: timestamp(v.EstimatedCastAt.Estimate),
+				},
+			})
+		}
+
+		c.Files = append(c.Files, file)
+	}
+
+	return c
+}
+
+// voteRecord converts the export row into its protobuf message.
+func voteRecord(row *export.Row) *pb.VoteRecord {
+	return &pb.VoteRecord{
+		Token:     row.Token,
+		Version:   row.Version,
+		Ticket:    row.Ticket,
+		Option:    row.Option,
+		CommitSha: row.CommitSHA,
+		Timestamp: timestamp(row.Timestamp),
+		Author:    row.Author,
+	}
+}
@@ -0,0 +1,329 @@
+package rpcserver
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dmigwi/go-piparser/proposals"
+	"github.com/dmigwi/go-piparser/proposals/types"
+	"github.com/dmigwi/go-piparser/v1/data"
+	pb "github.com/dmigwi/go-piparser/v1/piparserpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const (
+	testToken  = "27f87171d98b7923a1bd2bee6affed929fa2d2a6e178b5c80a9971a92a5c7f50"
+	testTicket = "e272d314b1f6a15c4480145ab286a54bb9b6735718b776755fea7c77eba030b8"
+	testSHA    = "62f715e00c50e7c506acc4b6e33eb86d02bab6d1"
+)
+
+// testSource serves the history fixtures in place of a proposals.Parser. The
+// filters cannot be applied without a Parser thus they are ignored.
+type testSource struct {
+	history []*types.History
+	updates chan *proposals.Update
+}
+
+func (s *testSource) HeadSHA() string { return testSHA }
+
+func (s *testSource) ProposalTokens() ([]string, error) {
+	return []string{testToken}, nil
+}
+
+func (s *testSource) WalkProposalHistory(proposalToken string, since time.Time,
+	fn proposals.WalkFunc, filters ...proposals.Filter) error {
+	for _, h := range s.history {
+		item := *h
+		item.Patch = nil
+		for _, f := range h.Patch {
+			if f.Token == proposalToken {
+				item.Patch = append(item.Patch, f)
+			}
+		}
+
+		if len(item.Patch) == 0 {
+			continue
+		}
+
+		if err := fn(&item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *testSource) WalkProposalsHistory(since time.Time, fn proposals.WalkFunc,
+	filters ...proposals.Filter) error {
+	for _, h := range s.history {
+		if err := fn(h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *testSource) Subscribe(buffer int) (<-chan *proposals.Update, func()) {
+	return s.updates, func() {}
+}
+
+// lockingSource only holds the read lock while copying the snapshot, then
+// walks the history without it like the proposals.Parser does. It counts the
+// commits walked.
+type lockingSource struct {
+	sync.RWMutex
+	testSource
+	walked int64
+}
+
+func (s *lockingSource) WalkProposalsHistory(since time.Time, fn proposals.WalkFunc,
+	filters ...proposals.Filter) error {
+	s.RLock()
+	snapshot := s.testSource
+	s.RUnlock()
+
+	return snapshot.WalkProposalsHistory(since, func(h *types.History) error {
+		atomic.AddInt64(&s.walked, 1)
+		return fn(h)
+	}, filters...)
+}
+
+// newTestClient serves the service over an in-memory connection and returns
+// its client. The optional dial options are set on the client connection.
+func newTestClient(t *testing.T, source Source, opts ...grpc.DialOption) pb.PiparserClient {
+	lis := bufconn.Listen(1 << 20)
+
+	s := grpc.NewServer()
+	Register(s, source)
+	go s.Serve(lis)
+
+	opts = append(opts,
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+
+	conn, err := grpc.NewClient("passthrough:///bufnet", opts...)
+	if err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	t.Cleanup(func() {
+		conn.Close()
+		s.Stop()
+	})
+
+	return pb.NewPiparserClient(conn)
+}
+
+// TestQueries tests the unary and the history streaming calls.
+func TestQueries(t *testing.T) {
+	client := newTestClient(t, &testSource{history: data.AllTokensVotesData})
+	ctx := context.Background()
+
+	list, err := client.ListProposals(ctx, &pb.ListProposalsRequest{})
+	if err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if list.HeadSha != testSHA || len(list.Tokens) != 1 || list.Tokens[0] != testToken {
+		t.Fatalf("expected token %s at %s but found %v", testToken, testSHA, list)
+	}
+
+	stream, err := client.ProposalHistory(ctx, &pb.ProposalHistoryRequest{Token: testToken})
+	if err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	votes := 0
+	for {
+		c, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatalf("expected no error but found: %v", err)
+		}
+
+		for _, f := range c.Files {
+			votes += len(f.Votes)
+		}
+	}
+
+	if votes != 12 {
+		t.Fatalf("expected 12 votes but found %d", votes)
+	}
+
+	tally, err := client.Tally(ctx, &pb.TallyRequest{Token: testToken})
+	if err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if tally.Total == 0 || tally.Total != tally.Options["Yes"]+tally.Options["No"] {
+		t.Fatalf("expected the Yes and No votes to add up to the total but found %v", tally)
+	}
+
+	tickets, err := client.TicketVotes(ctx, &pb.TicketVotesRequest{Ticket: testTicket})
+	if err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if len(tickets.Votes) == 0 {
+		t.Fatalf("expected to find the ticket votes but found none")
+	}
+}
+
+// TestStalledClient tests that a client that stops reading the history streamed
+// does not hold the read lock, thus the updates keep going through, and that
+// the walk waits for it rather than queuing the rest of the history.
+func TestStalledClient(t *testing.T) {
+	source := new(lockingSource)
+	for i := 0; i < 200; i++ {
+		source.history = append(source.history, data.AllTokensVotesData...)
+	}
+
+	// Fixed flow control windows stop the server once the client stops reading.
+	client := newTestClient(t, source, grpc.WithInitialWindowSize(1<<16),
+		grpc.WithInitialConnWindowSize(1<<16))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.ProposalHistory(ctx, &pb.ProposalHistoryRequest{})
+	if err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if _, err = stream.Recv(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	// An update pins the new snapshot while the client is not reading.
+	locked := make(chan struct{})
+	go func() {
+		source.Lock()
+		source.Unlock()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the update to go through while the client is not reading")
+	}
+
+	// Give the walk time to fill the queue.
+	time.Sleep(100 * time.Millisecond)
+	if walked := atomic.LoadInt64(&source.walked); walked >= int64(len(source.history)) {
+		t.Fatalf("expected the walk to wait for the client but all %d commits were walked",
+			walked)
+	}
+
+	commits := 1
+	for {
+		_, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatalf("expected no error but found: %v", err)
+		}
+		commits++
+	}
+
+	if commits != len(source.history) {
+		t.Fatalf("expected %d commits but found %d", len(source.history), commits)
+	}
+}
+
+// TestInvalidArguments tests that the invalid requests are rejected.
+func TestInvalidArguments(t *testing.T) {
+	client := newTestClient(t, &testSource{history: data.AllTokensVotesData})
+	ctx := context.Background()
+
+	_, err := client.Tally(ctx, &pb.TallyRequest{Token: "invalid"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected an invalid argument error but found: %v", err)
+	}
+
+	stream, err := client.ProposalHistory(ctx, &pb.ProposalHistoryRequest{Token: "invalid"})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected an invalid argument error but found: %v", err)
+	}
+
+	for _, ticket := range []string{"", "invalid", strings.Repeat("A", 64)} {
+		_, err = client.TicketVotes(ctx, &pb.TicketVotesRequest{Ticket: ticket})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("expected an invalid argument error for %q but found: %v", ticket, err)
+		}
+	}
+}
+
+// TestStreamVotes tests that the votes of the requested tokens are streamed as
+// the updates are received.
+func TestStreamVotes(t *testing.T) {
+	source := &testSource{updates: make(chan *proposals.Update, 1)}
+	client := newTestClient(t, source)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.StreamVotes(ctx, &pb.StreamVotesRequest{Tokens: []string{testToken}})
+	if err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	source.updates <- &proposals.Update{PreviousSHA: "a1", HeadSHA: testSHA,
+		History: data.AllTokensVotesData}
+
+	u, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if u.HeadSha != testSHA || len(u.Commits) != 1 || u.Commits[0].Files[0].Token != testToken {
+		t.Fatalf("expected a single commit with the votes of %s but found %v", testToken, u)
+	}
+
+	// The rollbacks are sent even if no new votes were found.
+	source.updates <- &proposals.Update{PreviousSHA: testSHA, HeadSHA: "b2",
+		Rollback: &proposals.Rollback{ForkPoint: "a1", Commits: []string{testSHA}}}
+
+	u, err = stream.Recv()
+	if err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	r := u.Rollback
+	if len(u.Commits) != 0 || r == nil || r.ForkPoint != "a1" || r.Unknown ||
+		len(r.DroppedShas) != 1 || r.DroppedShas[0] != testSHA {
+		t.Fatalf("expected the rollback of %s but found %v", testSHA, u)
+	}
+
+	// The complete history follows the unknown rollbacks.
+	source.updates <- &proposals.Update{PreviousSHA: "b2", HeadSHA: testSHA,
+		History: data.AllTokensVotesData, Rollback: &proposals.Rollback{Unknown: true}}
+
+	if u, err = stream.Recv(); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if u.Rollback == nil || !u.Rollback.Unknown || len(u.Commits) != 1 {
+		t.Fatalf("expected an unknown rollback with the complete history but found %v", u)
+	}
+}
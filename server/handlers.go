@@ -0,0 +1,263 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dmigwi/go-piparser/proposals"
+	"github.com/dmigwi/go-piparser/proposals/types"
+	"github.com/dmigwi/go-piparser/v1/export"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// dateFormat is the short date format accepted by the since and until
+	// query parameters. RFC3339 dates are also accepted.
+	dateFormat = "2006-01-02"
+)
+
+// ProposalItem describes a proposal listed.
+type ProposalItem struct {
+	Token string `json:"token"`
+}
+
+// TimeseriesPoint describes the votes flushed by a single commit.
+type TimeseriesPoint struct {
+	Timestamp  time.Time      `json:"timestamp"`
+	CommitSHA  string         `json:"commit_sha"`
+	Votes      map[string]int `json:"votes"`
+	Cumulative map[string]int `json:"cumulative"`
+}
+
+// handleProposals handles the /proposals route.
+func (s *Server) handleProposals(w http.ResponseWriter, r *http.Request) {
+	if s.notModified(w, r) {
+		return
+	}
+
+	page, err := pagination(r)
+	if err != nil {
+		writeError(s.logger, w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	tokens, err := s.source.ProposalTokens()
+	if err != nil {
+		writeError(s.logger, w, http.StatusInternalServerError, "listing the proposals failed: %v", err)
+		return
+	}
+
+	items := make([]interface{}, 0, len(tokens))
+	for _, token := range tokens {
+		items = append(items, ProposalItem{Token: token})
+	}
+
+	writeJSON(s.logger, w, http.StatusOK, paginate(items, page))
+}
+
+// handleVotes handles the /proposals/{token}/votes route.
+func (s *Server) handleVotes(w http.ResponseWriter, r *http.Request) {
+	history, ok := s.proposalHistory(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := pagination(r)
+	if err != nil {
+		writeError(s.logger, w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	writeJSON(s.logger, w, http.StatusOK, paginate(rows(history), page))
+}
+
+// handleTally handles the /proposals/{token}/tally route.
+func (s *Server) handleTally(w http.ResponseWriter, r *http.Request) {
+	history, ok := s.proposalHistory(w, r)
+	if !ok {
+		return
+	}
+
+	token := mux.Vars(r)["token"]
+	tally, ok := types.TallyVotes(history)[token]
+	if !ok {
+		tally = &types.Tally{Token: token, Options: map[string]int{}}
+	}
+
+	writeJSON(s.logger, w, http.StatusOK, tally)
+}
+
+// handleTimeseries handles the /proposals/{token}/timeseries route.
+func (s *Server) handleTimeseries(w http.ResponseWriter, r *http.Request) {
+	history, ok := s.proposalHistory(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := pagination(r)
+	if err != nil {
+		writeError(s.logger, w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	counted := make(map[string]bool)
+	cumulative := make(map[string]int)
+	items := make([]interface{}, 0, len(history))
+
+	for _, h := range history {
+		point := TimeseriesPoint{Timestamp: h.Date, CommitSHA: h.CommitSHA,
+			Votes: make(map[string]int), Cumulative: make(map[string]int)}
+
+		// Only the first vote cast by each ticket is counted as in the tally.
+		for _, f := range h.Patch {
+			for _, v := range f.VotesInfo {
+				if v.PiVote == nil || counted[v.Ticket] {
+					continue
+				}
+
+				counted[v.Ticket] = true
+				point.Votes[string(v.VoteBit)]++
+				cumulative[string(v.VoteBit)]++
+			}
+		}
+
+		for option, count := range cumulative {
+			point.Cumulative[option] = count
+		}
+
+		items = append(items, point)
+	}
+
+	writeJSON(s.logger, w, http.StatusOK, paginate(items, page))
+}
+
+// handleTicket handles the /tickets/{ticket} route.
+func (s *Server) handleTicket(w http.ResponseWriter, r *http.Request) {
+	ticket := mux.Vars(r)["ticket"]
+	if !types.IsTicket(ticket) {
+		writeError(s.logger, w, http.StatusBadRequest, "invalid ticket %q", ticket)
+		return
+	}
+
+	if s.notModified(w, r) {
+		return
+	}
+
+	since, filters, err := dateFilters(r)
+	if err != nil {
+		writeError(s.logger, w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	page, err := pagination(r)
+	if err != nil {
+		writeError(s.logger, w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	filters = append(filters, proposals.TicketFilter(ticket))
+	history, err := s.source.ProposalsHistorySince(since, filters...)
+	if err != nil {
+		writeError(s.logger, w, http.StatusInternalServerError, "fetching the ticket votes failed: %v", err)
+		return
+	}
+
+	writeJSON(s.logger, w, http.StatusOK, paginate(rows(history), page))
+}
+
+// proposalHistory validates the proposal token and returns its history. An
+// error response is written and false returned if the history could not be
+// fetched or if the response was not modified.
+func (s *Server) proposalHistory(w http.ResponseWriter, r *http.Request) ([]*types.History, bool) {
+	token := mux.Vars(r)["token"]
+	if !types.IsToken(token) {
+		writeError(s.logger, w, http.StatusBadRequest, "invalid proposal token %q", token)
+		return nil, false
+	}
+
+	if s.notModified(w, r) {
+		return nil, false
+	}
+
+	since, filters, err := dateFilters(r)
+	if err != nil {
+		writeError(s.logger, w, http.StatusBadRequest, "%v", err)
+		return nil, false
+	}
+
+	history, err := s.source.ProposalHistorySince(token, since, filters...)
+	if err != nil {
+		writeError(s.logger, w, http.StatusInternalServerError, "fetching the proposal history failed: %v", err)
+		return nil, false
+	}
+
+	return history, true
+}
+
+// notModified sets the ETag header keyed on the HEAD SHA of the snapshot that
+// the queries are run against. It writes the not modified response and returns
+// true if the client already holds the current version.
+func (s *Server) notModified(w http.ResponseWriter, r *http.Request) bool {
+	sha := s.source.HeadSHA()
+	if sha == "" {
+		return false
+	}
+
+	etag := fmt.Sprintf("%q", sha)
+	w.Header().Set("ETag", etag)
+
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// dateFilters reads the since and until query parameters.
+func dateFilters(r *http.Request) (time.Time, []proposals.Filter, error) {
+	since, err := parseDate(r.URL.Query().Get("since"))
+	if err != nil {
+		return since, nil, fmt.Errorf("invalid since date: %v", err)
+	}
+
+	until, err := parseDate(r.URL.Query().Get("until"))
+	if err != nil {
+		return since, nil, fmt.Errorf("invalid until date: %v", err)
+	}
+
+	var filters []proposals.Filter
+	if !until.IsZero() {
+		filters = append(filters, proposals.UntilFilter(until))
+	}
+
+	return since, filters, nil
+}
+
+// parseDate parses the provided date in either the short date format or the
+// RFC3339 format. An empty string returns a zero time.
+func parseDate(str string) (time.Time, error) {
+	if str == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(dateFormat, str); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339, str)
+}
+
+// rows returns the vote rows found in the provided history.
+func rows(history []*types.History) []interface{} {
+	items := make([]interface{}, 0)
+	for _, h := range history {
+		for _, row := range export.Rows(h) {
+			items = append(items, row)
+		}
+	}
+	return items
+}
@@ -0,0 +1,55 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/dmigwi/go-piparser/proposals"
+)
+
+const (
+	// HealthPath and ReadyPath are the paths of the health and readiness
+	// probes. They are served outside the API prefix.
+	HealthPath = "/healthz"
+	ReadyPath  = "/readyz"
+)
+
+// StatusReporter defines the proposals.Parser status used to serve the health
+// and readiness probes.
+type StatusReporter interface {
+	Status() *proposals.Status
+}
+
+// HealthStatus is the body of the health and readiness probe responses.
+type HealthStatus struct {
+	Ready bool `json:"ready"`
+	*proposals.Status
+}
+
+// HealthHandler returns the handler of the health probe. It responds with the
+// Parser status and the status code 200 as long as the process is serving
+// requests. The failures to write the responses are dropped.
+func HealthHandler(reporter StatusReporter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s := reporter.Status()
+		writeJSON(proposals.DiscardLogger{}, w, http.StatusOK, HealthStatus{Ready: s.Ready(), Status: s})
+	}
+}
+
+// ReadyHandler returns the handler of the readiness probe. It responds with
+// the status code 503 until the Parser's first successful update and 200
+// afterwards. The failures to write the responses are dropped.
+func ReadyHandler(reporter StatusReporter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s := reporter.Status()
+
+		code := http.StatusOK
+		if !s.Ready() {
+			code = http.StatusServiceUnavailable
+		}
+
+		writeJSON(proposals.DiscardLogger{}, w, code, HealthStatus{Ready: s.Ready(), Status: s})
+	}
+}
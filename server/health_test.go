@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dmigwi/go-piparser/proposals"
+	"github.com/dmigwi/go-piparser/v1/data"
+)
+
+// testReporter is a testSource that also reports the set status.
+type testReporter struct {
+	testSource
+	status *proposals.Status
+}
+
+func (s *testReporter) Status() *proposals.Status { return s.status }
+
+// TestHealthProbes tests the health and readiness probes responses before and
+// after the first successful update.
+func TestHealthProbes(t *testing.T) {
+	reporter := &testReporter{testSource(data.AllTokensVotesData), &proposals.Status{
+		LastUpdateAttempt: time.Now(),
+		LastUpdateError:   "exit status 128",
+	}}
+	srv := New(reporter)
+
+	synced := &proposals.Status{HeadSHA: testSHA, LastUpdateSuccess: time.Now()}
+
+	td := []struct {
+		status *proposals.Status
+		path   string
+		code   int
+		ready  bool
+	}{
+		{nil, HealthPath, http.StatusOK, false},
+		{nil, ReadyPath, http.StatusServiceUnavailable, false},
+		{synced, HealthPath, http.StatusOK, true},
+		{synced, ReadyPath, http.StatusOK, true},
+	}
+
+	for i, val := range td {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			if val.status != nil {
+				reporter.status = val.status
+			}
+
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, val.path, nil))
+
+			if rec.Code != val.code {
+				t.Fatalf("expected the status code %d but found %d", val.code, rec.Code)
+			}
+
+			var body HealthStatus
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("expected no error but found: %v", err)
+			}
+
+			if body.Ready != val.ready {
+				t.Fatalf("expected ready to be %v but found %v", val.ready, body.Ready)
+			}
+		})
+	}
+
+	// The probes are not served if the source does not report its status.
+	rec := httptest.NewRecorder()
+	New(testSource(data.AllTokensVotesData)).ServeHTTP(rec,
+		httptest.NewRequest(http.MethodGet, ReadyPath, nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected the status code %d but found %d", http.StatusNotFound, rec.Code)
+	}
+}
@@ -0,0 +1,337 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dmigwi/go-piparser/proposals"
+	"github.com/dmigwi/go-piparser/proposals/types"
+	"github.com/dmigwi/go-piparser/v1/export"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// updatesBuffer is the number of parser updates buffered while the hub
+	// pushes the previous update to the clients.
+	updatesBuffer = 16
+
+	// clientBuffer is the number of events buffered per client. The events
+	// are dropped for the clients that fall behind.
+	clientBuffer = 16
+
+	// keepAliveInterval is the interval at which the idle push connections
+	// are pinged to keep them open.
+	keepAliveInterval = 30 * time.Second
+
+	// writeTimeout is the time allowed to write a WebSocket message.
+	writeTimeout = 10 * time.Second
+
+	// votesEventName is the type of the events holding the new votes. It is
+	// the SSE event name too.
+	votesEventName = "votes"
+
+	// rollbackEventName is the type of the events holding the votes retracted
+	// after the repository was force-pushed.
+	rollbackEventName = "rollback"
+)
+
+// Subscriber defines the proposals.Parser updates subscription used to push
+// the new votes to the clients.
+type Subscriber interface {
+	Subscribe(buffer int) (<-chan *proposals.Update, func())
+}
+
+// VotesEvent holds the new votes cast on a proposal found by a single update if
+// its type is "votes". If its type is "rollback", it holds the votes retracted
+// since their commits were dropped from the history by a force-push. The
+// rollback events are pushed before the votes events of the same update.
+type VotesEvent struct {
+	Type    string        `json:"type"`
+	Token   string        `json:"token"`
+	HeadSHA string        `json:"head_sha"`
+	Votes   []*export.Row `json:"votes"`
+
+	// The fields below are only set on the rollback events. If Unknown is
+	// set, the dropped commits could not be listed thus all the votes pushed
+	// so far must be discarded and the votes event that follows holds the
+	// complete history.
+	ForkPoint   string   `json:"fork_point,omitempty"`
+	DroppedSHAs []string `json:"dropped_shas,omitempty"`
+	Unknown     bool     `json:"unknown,omitempty"`
+}
+
+// upgrader upgrades the push requests to WebSocket connections. The votes data
+// is public and read-only thus the requests from any origin are accepted.
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// client holds the events pushed to a single connection.
+type client struct {
+	token  string
+	events chan *VotesEvent
+}
+
+// hub fans out the votes found by the parser updates to the clients of each
+// proposal token.
+type hub struct {
+	mtx     sync.Mutex
+	clients map[*client]struct{}
+	cancel  func()
+	closed  bool
+	logger  proposals.Logger
+}
+
+// newHub subscribes to the parser updates and starts pushing them to the
+// clients. The clients falling behind are reported to the logger.
+func newHub(sub Subscriber, logger proposals.Logger) *hub {
+	updates, cancel := sub.Subscribe(updatesBuffer)
+	h := &hub{clients: make(map[*client]struct{}), cancel: cancel, logger: logger}
+
+	go func() {
+		for u := range updates {
+			h.broadcast(u)
+		}
+		h.close()
+	}()
+
+	return h
+}
+
+// broadcast sends the votes found in the update to the clients of each token.
+// The votes retracted by the update rollback, if any, are sent first.
+func (h *hub) broadcast(u *proposals.Update) {
+	votes := tokenEvents(votesEventName, u.HeadSHA, u.History)
+
+	var rollbacks map[string]*VotesEvent
+	if r := u.Rollback; r != nil {
+		rollbacks = tokenEvents(rollbackEventName, u.HeadSHA, r.History)
+		for _, ev := range rollbacks {
+			ev.ForkPoint = r.ForkPoint
+			ev.DroppedSHAs = r.Commits
+		}
+	}
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	for c := range h.clients {
+		var events []*VotesEvent
+		if ev, ok := rollbacks[c.token]; ok {
+			events = append(events, ev)
+		} else if u.Rollback != nil && u.Rollback.Unknown {
+			// All the votes pushed so far are retracted.
+			events = append(events, &VotesEvent{Type: rollbackEventName, Token: c.token,
+				HeadSHA: u.HeadSHA, ForkPoint: u.Rollback.ForkPoint, Unknown: true})
+		}
+
+		if ev, ok := votes[c.token]; ok {
+			events = append(events, ev)
+		}
+
+		for _, ev := range events {
+			select {
+			case c.events <- ev:
+			default:
+				h.logger.Warn("push client fell behind: dropping the event",
+					"token", c.token, "head_sha", u.HeadSHA, "type", ev.Type)
+			}
+		}
+	}
+}
+
+// tokenEvents returns the events of the provided type holding the votes found
+// in the history, mapped by the proposal token.
+func tokenEvents(eventType, headSHA string, history []*types.History) map[string]*VotesEvent {
+	events := make(map[string]*VotesEvent)
+	for _, h := range history {
+		for _, row := range export.Rows(h) {
+			ev, ok := events[row.Token]
+			if !ok {
+				ev = &VotesEvent{Type: eventType, Token: row.Token, HeadSHA: headSHA}
+				events[row.Token] = ev
+			}
+			ev.Votes = append(ev.Votes, row)
+		}
+	}
+	return events
+}
+
+// register adds a client of the provided token. It returns false if the hub
+// was closed.
+func (h *hub) register(token string) (*client, bool) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if h.closed {
+		return nil, false
+	}
+
+	c := &client{token: token, events: make(chan *VotesEvent, clientBuffer)}
+	h.clients[c] = struct{}{}
+	return c, true
+}
+
+// unregister removes the client and closes its events channel.
+func (h *hub) unregister(c *client) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if _, ok := h.clients[c]; ok {
+		delete(h.clients, c)
+		close(c.events)
+	}
+}
+
+// close cancels the updates subscription and disconnects all the clients.
+func (h *hub) close() {
+	h.cancel()
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.closed = true
+	for c := range h.clients {
+		delete(h.clients, c)
+		close(c.events)
+	}
+}
+
+// pushClient validates the proposal token and registers its push client. An
+// error response is written and false returned on failure.
+func (s *Server) pushClient(w http.ResponseWriter, r *http.Request) (*client, bool) {
+	token := mux.Vars(r)["token"]
+	if !types.IsToken(token) {
+		writeError(s.logger, w, http.StatusBadRequest, "invalid proposal token %q", token)
+		return nil, false
+	}
+
+	c, ok := s.hub.register(token)
+	if !ok {
+		writeError(s.logger, w, http.StatusServiceUnavailable, "the server is shutting down")
+		return nil, false
+	}
+
+	return c, true
+}
+
+// handleVotesStream handles the /proposals/{token}/votes/stream route. The new
+// votes are pushed as Server-Sent Events.
+func (s *Server) handleVotesStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(s.logger, w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+
+	c, ok := s.pushClient(w, r)
+	if !ok {
+		return
+	}
+	defer s.hub.unregister(c)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-ticker.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+
+		case ev, ok := <-c.events:
+			if !ok {
+				return
+			}
+
+			data, err := json.Marshal(ev)
+			if err != nil {
+				s.logger.Error("encoding the push event failed", "type", ev.Type,
+					"error", err)
+				continue
+			}
+
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data)
+		}
+
+		flusher.Flush()
+	}
+}
+
+// handleVotesWebSocket handles the /proposals/{token}/votes/ws route. The new
+// votes are pushed as WebSocket JSON text messages.
+func (s *Server) handleVotesWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !websocket.IsWebSocketUpgrade(r) {
+		writeError(s.logger, w, http.StatusBadRequest, "a websocket upgrade request is expected")
+		return
+	}
+
+	c, ok := s.pushClient(w, r)
+	if !ok {
+		return
+	}
+	defer s.hub.unregister(c)
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already written the error response.
+		s.logger.Warn("websocket upgrade failed", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	// The client messages are discarded. Reading detects the closed
+	// connections and processes the control messages.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+
+		case <-ticker.C:
+			deadline := time.Now().Add(writeTimeout)
+			if err := conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				return
+			}
+
+		case ev, ok := <-c.events:
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(
+					websocket.CloseGoingAway, ""), time.Now().Add(writeTimeout))
+				return
+			}
+
+			conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := conn.WriteJSON(ev); err != nil {
+				return
+			}
+		}
+	}
+}
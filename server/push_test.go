@@ -0,0 +1,359 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dmigwi/go-piparser/proposals"
+	"github.com/dmigwi/go-piparser/v1/data"
+	"github.com/gorilla/websocket"
+)
+
+// testSubscriber is a testSource that also pushes the updates sent on its
+// channel.
+type testSubscriber struct {
+	testSource
+	updates chan *proposals.Update
+}
+
+func (s *testSubscriber) Subscribe(buffer int) (<-chan *proposals.Update, func()) {
+	return s.updates, func() {}
+}
+
+// newTestPushServer returns a running server whose updates are sent by the
+// returned subscriber.
+func newTestPushServer(t *testing.T) (*httptest.Server, *testSubscriber) {
+	sub := &testSubscriber{testSource(data.AllTokensVotesData),
+		make(chan *proposals.Update)}
+
+	srv := New(sub)
+	ts := httptest.NewServer(srv)
+
+	t.Cleanup(func() {
+		srv.Close()
+		ts.Close()
+	})
+
+	return ts, sub
+}
+
+// votesUpdate returns an update holding the history fixtures as new votes.
+func votesUpdate() *proposals.Update {
+	return &proposals.Update{HeadSHA: testSHA, History: data.AllTokensVotesData}
+}
+
+// rollbackUpdates returns the updates retracting the history fixtures and the
+// event types expected to be pushed for each of them.
+func rollbackUpdates() ([]*proposals.Update, [][]string) {
+	dropped := make([]string, 0, len(data.AllTokensVotesData))
+	for _, h := range data.AllTokensVotesData {
+		dropped = append(dropped, h.CommitSHA)
+	}
+
+	updates := []*proposals.Update{
+		{HeadSHA: testSHA, Rollback: &proposals.Rollback{ForkPoint: testSHA,
+			Commits: dropped, History: data.AllTokensVotesData}},
+		{HeadSHA: testSHA, History: data.AllTokensVotesData,
+			Rollback: &proposals.Rollback{Unknown: true}},
+	}
+	events := [][]string{
+		{rollbackEventName},
+		{rollbackEventName, votesEventName},
+	}
+	return updates, events
+}
+
+// checkRollbackEvent checks that the event retracts the history fixtures, or
+// all the votes if the rollback is unknown.
+func checkRollbackEvent(t *testing.T, ev *VotesEvent, unknown bool) {
+	t.Helper()
+
+	if ev.Type != rollbackEventName || ev.Token != testToken || ev.Unknown != unknown {
+		t.Fatalf("expected a rollback event of token %s but found %q of %s",
+			testToken, ev.Type, ev.Token)
+	}
+
+	if unknown {
+		if len(ev.Votes) != 0 || len(ev.DroppedSHAs) != 0 {
+			t.Fatalf("expected no votes retracted but found %d", len(ev.Votes))
+		}
+		return
+	}
+
+	if len(ev.Votes) != 12 || len(ev.DroppedSHAs) != len(data.AllTokensVotesData) ||
+		ev.ForkPoint != testSHA {
+		t.Fatalf("expected 12 votes retracted but found %d", len(ev.Votes))
+	}
+}
+
+// sendUpdate pushes the update until a client of the test token receives it.
+func sendUpdate(sub *testSubscriber, u *proposals.Update, received <-chan struct{}) {
+	for {
+		select {
+		case sub.updates <- u:
+		case <-received:
+			return
+		}
+
+		select {
+		case <-received:
+			return
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// TestVotesStream tests that the new votes are pushed as Server-Sent Events.
+func TestVotesStream(t *testing.T) {
+	ts, sub := newTestPushServer(t)
+
+	resp, err := http.Get(ts.URL + "/api/v1/proposals/" + testToken + "/votes/stream")
+	if err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected an event stream but found %q", ct)
+	}
+
+	received := make(chan struct{})
+	go sendUpdate(sub, votesUpdate(), received)
+
+	var ev VotesEvent
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "data: ") {
+			if err = json.Unmarshal([]byte(line[len("data: "):]), &ev); err != nil {
+				t.Fatalf("expected no error but found: %v", err)
+			}
+			break
+		}
+	}
+	close(received)
+
+	if ev.Token != testToken || ev.HeadSHA != testSHA || len(ev.Votes) != 12 {
+		t.Fatalf("expected 12 votes of token %s but found %d of %s", testToken,
+			len(ev.Votes), ev.Token)
+	}
+}
+
+// TestVotesWebSocket tests that the new votes are pushed as WebSocket messages.
+func TestVotesWebSocket(t *testing.T) {
+	ts, sub := newTestPushServer(t)
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/v1/proposals/" +
+		testToken + "/votes/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+	defer conn.Close()
+
+	received := make(chan struct{})
+	go sendUpdate(sub, votesUpdate(), received)
+
+	var ev VotesEvent
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	err = conn.ReadJSON(&ev)
+	close(received)
+
+	if err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if ev.Token != testToken || len(ev.Votes) != 12 {
+		t.Fatalf("expected 12 votes of token %s but found %d of %s", testToken,
+			len(ev.Votes), ev.Token)
+	}
+}
+
+// readEvent reads the next Server-Sent Event and returns its name.
+func readEvent(t *testing.T, scanner *bufio.Scanner) (string, *VotesEvent) {
+	t.Helper()
+
+	var name string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "event: ") {
+			name = line[len("event: "):]
+		}
+
+		if strings.HasPrefix(line, "data: ") {
+			ev := new(VotesEvent)
+			if err := json.Unmarshal([]byte(line[len("data: "):]), ev); err != nil {
+				t.Fatalf("expected no error but found: %v", err)
+			}
+			return name, ev
+		}
+	}
+
+	t.Fatalf("expected an event but found: %v", scanner.Err())
+	return "", nil
+}
+
+// TestRollbackStream tests that the retracted votes are pushed as Server-Sent
+// Events before the new votes.
+func TestRollbackStream(t *testing.T) {
+	updates, events := rollbackUpdates()
+
+	for i, u := range updates {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			ts, sub := newTestPushServer(t)
+
+			resp, err := http.Get(ts.URL + "/api/v1/proposals/" + testToken + "/votes/stream")
+			if err != nil {
+				t.Fatalf("expected no error but found: %v", err)
+			}
+			defer resp.Body.Close()
+
+			received := make(chan struct{})
+			go sendUpdate(sub, u, received)
+
+			scanner := bufio.NewScanner(resp.Body)
+			scanner.Buffer(nil, 1<<20)
+
+			for j, expected := range events[i] {
+				name, ev := readEvent(t, scanner)
+				if j == 0 {
+					close(received)
+				}
+
+				if name != expected || ev.Type != expected {
+					t.Fatalf("expected event %q but found %q of type %q", expected,
+						name, ev.Type)
+				}
+
+				if expected == rollbackEventName {
+					checkRollbackEvent(t, ev, u.Rollback.Unknown)
+				} else if len(ev.Votes) != 12 {
+					t.Fatalf("expected 12 votes but found %d", len(ev.Votes))
+				}
+			}
+		})
+	}
+}
+
+// TestRollbackWebSocket tests that the retracted votes are pushed as WebSocket
+// messages before the new votes.
+func TestRollbackWebSocket(t *testing.T) {
+	updates, events := rollbackUpdates()
+
+	for i, u := range updates {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			ts, sub := newTestPushServer(t)
+
+			url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/v1/proposals/" +
+				testToken + "/votes/ws"
+			conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+			if err != nil {
+				t.Fatalf("expected no error but found: %v", err)
+			}
+			defer conn.Close()
+
+			received := make(chan struct{})
+			go sendUpdate(sub, u, received)
+
+			for j, expected := range events[i] {
+				var ev VotesEvent
+				conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+				err = conn.ReadJSON(&ev)
+				if j == 0 {
+					close(received)
+				}
+
+				if err != nil {
+					t.Fatalf("expected no error but found: %v", err)
+				}
+
+				if ev.Type != expected {
+					t.Fatalf("expected an event of type %q but found %q", expected, ev.Type)
+				}
+
+				if expected == rollbackEventName {
+					checkRollbackEvent(t, &ev, u.Rollback.Unknown)
+				} else if len(ev.Votes) != 12 {
+					t.Fatalf("expected 12 votes but found %d", len(ev.Votes))
+				}
+			}
+		})
+	}
+}
+
+// TestVotesPushInvalidToken tests that the push routes validate the token.
+func TestVotesPushInvalidToken(t *testing.T) {
+	ts, _ := newTestPushServer(t)
+
+	for _, route := range []string{"stream", "ws"} {
+		resp, err := http.Get(ts.URL + "/api/v1/proposals/invalid/votes/" + route)
+		if err != nil {
+			t.Fatalf("expected no error but found: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected status %d but found %d", http.StatusBadRequest, resp.StatusCode)
+		}
+	}
+}
+
+// testLogger records the messages of the events logged.
+type testLogger struct {
+	mtx  sync.Mutex
+	msgs []string
+}
+
+func (l *testLogger) record(msg string) {
+	l.mtx.Lock()
+	l.msgs = append(l.msgs, msg)
+	l.mtx.Unlock()
+}
+
+func (l *testLogger) Debug(msg string, _ ...interface{}) { l.record(msg) }
+func (l *testLogger) Info(msg string, _ ...interface{})  { l.record(msg) }
+func (l *testLogger) Warn(msg string, _ ...interface{})  { l.record(msg) }
+func (l *testLogger) Error(msg string, _ ...interface{}) { l.record(msg) }
+
+// TestPushClientFellBehind tests that the clients falling behind are reported
+// to the set logger.
+func TestPushClientFellBehind(t *testing.T) {
+	logger := new(testLogger)
+	sub := &testSubscriber{testSource(data.AllTokensVotesData),
+		make(chan *proposals.Update)}
+
+	srv := New(sub, WithLogger(logger))
+	defer srv.Close()
+
+	// The client never reads the events pushed.
+	if _, ok := srv.hub.register(testToken); !ok {
+		t.Fatalf("expected the client to be registered")
+	}
+
+	for i := 0; i <= clientBuffer; i++ {
+		sub.updates <- votesUpdate()
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		logger.mtx.Lock()
+		n := len(logger.msgs)
+		logger.mtx.Unlock()
+
+		if n > 0 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the client falling behind to be logged")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
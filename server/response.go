@@ -0,0 +1,101 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/dmigwi/go-piparser/proposals"
+)
+
+const (
+	// defaultLimit is the number of items returned per page if no limit is set.
+	defaultLimit = 100
+
+	// maxLimit is the maximum number of items returned per page.
+	maxLimit = 1000
+)
+
+// Pagination describes the page of the items returned.
+type Pagination struct {
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+	Total  int `json:"total"`
+}
+
+// Page is the envelope of the paginated responses.
+type Page struct {
+	Data       interface{} `json:"data"`
+	Pagination Pagination  `json:"pagination"`
+}
+
+// Error is the envelope of the error responses.
+type Error struct {
+	Error ErrorInfo `json:"error"`
+}
+
+// ErrorInfo describes the error that occurred.
+type ErrorInfo struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// writeJSON writes v as the JSON response body with the provided status code.
+// The failure to write the body is logged to l.
+func writeJSON(l proposals.Logger, w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		l.Warn("writing the response failed", "error", err)
+	}
+}
+
+// writeError writes the error envelope with the provided status code.
+func writeError(l proposals.Logger, w http.ResponseWriter, status int, format string,
+	args ...interface{}) {
+	writeJSON(l, w, status, Error{ErrorInfo{Code: status, Message: fmt.Sprintf(format, args...)}})
+}
+
+// pagination reads the offset and limit query parameters.
+func pagination(r *http.Request) (Pagination, error) {
+	p := Pagination{Limit: defaultLimit}
+
+	var err error
+	if str := r.URL.Query().Get("offset"); str != "" {
+		if p.Offset, err = strconv.Atoi(str); err != nil || p.Offset < 0 {
+			return p, fmt.Errorf("invalid offset %q", str)
+		}
+	}
+
+	if str := r.URL.Query().Get("limit"); str != "" {
+		if p.Limit, err = strconv.Atoi(str); err != nil || p.Limit < 1 || p.Limit > maxLimit {
+			return p, fmt.Errorf("invalid limit %q: expected a value between 1 and %d",
+				str, maxLimit)
+		}
+	}
+
+	return p, nil
+}
+
+// paginate returns the page of the items selected by p. The total number of
+// items is set on the returned pagination.
+func paginate(items []interface{}, p Pagination) Page {
+	p.Total = len(items)
+
+	start := p.Offset
+	if start > len(items) {
+		start = len(items)
+	}
+
+	end := start + p.Limit
+	if end > len(items) {
+		end = len(items)
+	}
+
+	return Page{Data: items[start:end], Pagination: p}
+}
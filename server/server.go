@@ -0,0 +1,112 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+// Package server implements the HTTP/JSON API that serves the votes data
+// parsed by the proposals.Parser.
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/dmigwi/go-piparser/proposals"
+	"github.com/dmigwi/go-piparser/proposals/types"
+	"github.com/gorilla/mux"
+)
+
+// APIPrefix is the path prefix of all the API routes.
+const APIPrefix = "/api/v1"
+
+// Source defines the proposals.Parser queries used to serve the API requests.
+type Source interface {
+	HeadSHA() string
+	ProposalTokens() ([]string, error)
+	ProposalHistorySince(proposalToken string, since time.Time,
+		filters ...proposals.Filter) ([]*types.History, error)
+	ProposalsHistorySince(since time.Time, filters ...proposals.Filter) ([]*types.History, error)
+}
+
+// Server serves the votes data API. It implements http.Handler.
+type Server struct {
+	source Source
+	router *mux.Router
+
+	// logger receives the failures to serve the clients.
+	logger proposals.Logger
+
+	// hub pushes the new votes to the clients. It is only set if the source
+	// implements Subscriber.
+	hub *hub
+}
+
+// Option defines a function that sets an optional Server configuration.
+type Option func(*Server)
+
+// WithLogger sets the structured logger that the failures to serve the clients
+// are reported to e.g. the push clients falling behind. A *slog.Logger can be
+// set directly. By default the events are dropped.
+func WithLogger(l proposals.Logger) Option {
+	return func(s *Server) {
+		if l == nil {
+			l = proposals.DiscardLogger{}
+		}
+		s.logger = l
+	}
+}
+
+// New returns a Server that serves the votes data queried from the source. If
+// the source implements Subscriber, the new votes are also pushed to the
+// clients over WebSocket and Server-Sent Events. If it implements
+// StatusReporter, the health and readiness probes are served too. The optional
+// opts arguments customize the Server behavior.
+func New(source Source, opts ...Option) *Server {
+	s := &Server{source: source, router: mux.NewRouter(), logger: proposals.DiscardLogger{}}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	api := s.router.PathPrefix(APIPrefix).Subrouter()
+	api.HandleFunc("/proposals", s.handleProposals).Methods(http.MethodGet)
+	api.HandleFunc("/proposals/{token}/votes", s.handleVotes).Methods(http.MethodGet)
+	api.HandleFunc("/proposals/{token}/tally", s.handleTally).Methods(http.MethodGet)
+	api.HandleFunc("/proposals/{token}/timeseries", s.handleTimeseries).Methods(http.MethodGet)
+	api.HandleFunc("/tickets/{ticket}", s.handleTicket).Methods(http.MethodGet)
+
+	if sub, ok := source.(Subscriber); ok {
+		s.hub = newHub(sub, s.logger)
+		api.HandleFunc("/proposals/{token}/votes/ws", s.handleVotesWebSocket).Methods(http.MethodGet)
+		api.HandleFunc("/proposals/{token}/votes/stream", s.handleVotesStream).Methods(http.MethodGet)
+	}
+
+	if reporter, ok := source.(StatusReporter); ok {
+		s.router.HandleFunc(HealthPath, HealthHandler(reporter)).Methods(http.MethodGet)
+		s.router.HandleFunc(ReadyPath, ReadyHandler(reporter)).Methods(http.MethodGet)
+	}
+
+	s.router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(s.logger, w, http.StatusNotFound, "route %s not found", r.URL.Path)
+	})
+	s.router.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(s.logger, w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+	})
+
+	return s
+}
+
+// Router returns the router that the API routes are registered on. It allows
+// more routes to be served by the same handler.
+func (s *Server) Router() *mux.Router {
+	return s.router
+}
+
+// Close stops pushing the new votes and disconnects the push clients.
+func (s *Server) Close() {
+	if s.hub != nil {
+		s.hub.close()
+	}
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.router.ServeHTTP(w, r)
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dmigwi/go-piparser/proposals"
+	"github.com/dmigwi/go-piparser/proposals/types"
+	"github.com/dmigwi/go-piparser/v1/data"
+)
+
+const (
+	testToken  = "27f87171d98b7923a1bd2bee6affed929fa2d2a6e178b5c80a9971a92a5c7f50"
+	testTicket = "e272d314b1f6a15c4480145ab286a54bb9b6735718b776755fea7c77eba030b8"
+	testSHA    = "62f715e00c50e7c506acc4b6e33eb86d02bab6d1"
+)
+
+// testSource serves the history fixtures in place of a proposals.Parser. The
+// filters cannot be applied without a Parser thus the ticket query returns the
+// votes of all the tickets.
+type testSource []*types.History
+
+func (s testSource) HeadSHA() string { return testSHA }
+
+func (s testSource) ProposalTokens() ([]string, error) {
+	return []string{testToken}, nil
+}
+
+func (s testSource) ProposalHistorySince(proposalToken string, since time.Time,
+	filters ...proposals.Filter) ([]*types.History, error) {
+	var history []*types.History
+	for _, h := range s {
+		item := *h
+		item.Patch = nil
+		for _, f := range h.Patch {
+			if f.Token == proposalToken {
+				item.Patch = append(item.Patch, f)
+			}
+		}
+
+		if len(item.Patch) > 0 {
+			history = append(history, &item)
+		}
+	}
+	return history, nil
+}
+
+func (s testSource) ProposalsHistorySince(since time.Time,
+	filters ...proposals.Filter) ([]*types.History, error) {
+	return s, nil
+}
+
+// TestServer tests the API routes responses.
+func TestServer(t *testing.T) {
+	srv := New(testSource(data.AllTokensVotesData))
+
+	td := []struct {
+		path   string
+		status int
+		total  int
+	}{
+		{"/api/v1/proposals", http.StatusOK, 1},
+		{"/api/v1/proposals/" + testToken + "/votes", http.StatusOK, 12},
+		{"/api/v1/proposals/" + testToken + "/votes?offset=10&limit=5", http.StatusOK, 12},
+		{"/api/v1/proposals/" + testToken + "/timeseries", http.StatusOK, 1},
+		{"/api/v1/tickets/" + testTicket, http.StatusOK, 50},
+		{"/api/v1/proposals/invalid/votes", http.StatusBadRequest, 0},
+		{"/api/v1/proposals/" + testToken + "/votes?limit=0", http.StatusBadRequest, 0},
+		{"/api/v1/proposals/" + testToken + "/votes?since=yesterday", http.StatusBadRequest, 0},
+		{"/api/v1/tickets/xyz", http.StatusBadRequest, 0},
+		{"/api/v1/unknown", http.StatusNotFound, 0},
+	}
+
+	for i, val := range td {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, val.path, nil))
+
+			if w.Code != val.status {
+				t.Fatalf("expected status %d but found %d: %s", val.status, w.Code, w.Body)
+			}
+
+			if val.status != http.StatusOK {
+				var e Error
+				if err := json.Unmarshal(w.Body.Bytes(), &e); err != nil || e.Error.Code != val.status {
+					t.Fatalf("expected an error envelope but found: %s", w.Body)
+				}
+				return
+			}
+
+			var page Page
+			if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+				t.Fatalf("expected no error but found: %v", err)
+			}
+
+			if page.Pagination.Total != val.total {
+				t.Fatalf("expected a total of %d items but found %d", val.total,
+					page.Pagination.Total)
+			}
+
+			items := page.Data.([]interface{})
+			expected := val.total - page.Pagination.Offset
+			if expected > page.Pagination.Limit {
+				expected = page.Pagination.Limit
+			}
+
+			if len(items) != expected {
+				t.Fatalf("expected %d items in the page but found %d", expected, len(items))
+			}
+		})
+	}
+}
+
+// TestServerTally tests the tally route response.
+func TestServerTally(t *testing.T) {
+	srv := New(testSource(data.AllTokensVotesData))
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet,
+		"/api/v1/proposals/"+testToken+"/tally", nil))
+
+	var tally types.Tally
+	if err := json.Unmarshal(w.Body.Bytes(), &tally); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if tally.Token != testToken || tally.Total == 0 ||
+		tally.Total != tally.Options["Yes"]+tally.Options["No"] {
+		t.Fatalf("expected the tally of token %s but found %v", testToken, tally)
+	}
+}
+
+// TestServerETag tests that the responses are not resent if the client holds
+// the version of the current HEAD SHA.
+func TestServerETag(t *testing.T) {
+	srv := New(testSource(data.AllTokensVotesData))
+	path := "/api/v1/proposals/" + testToken + "/votes"
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+	etag := w.Header().Get("ETag")
+	if etag != strconv.Quote(testSHA) {
+		t.Fatalf("expected the ETag %q but found %q", strconv.Quote(testSHA), etag)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	r.Header.Set("If-None-Match", etag)
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("expected a not modified response but found %d: %s", w.Code, w.Body)
+	}
+}
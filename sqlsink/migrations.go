@@ -0,0 +1,220 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+package sqlsink
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// migrations lists the schema changes in the order they are applied. Each
+// migration is a list of statements run in a single transaction. The applied
+// migrations are recorded in the schema_migrations table by their position.
+// New migrations must only be appended. The times are stored as unix
+// timestamps and the zero time as 0.
+var migrations = [][]string{
+	// 1: the initial schema.
+	{
+		`CREATE TABLE IF NOT EXISTS commits (
+			sha          TEXT PRIMARY KEY,
+			author       TEXT NOT NULL,
+			committer    TEXT NOT NULL,
+			authored_at  BIGINT NOT NULL,
+			committed_at BIGINT NOT NULL,
+			message      TEXT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS proposals (
+			token           TEXT PRIMARY KEY,
+			version         TEXT NOT NULL,
+			last_commit_sha TEXT NOT NULL REFERENCES commits (sha),
+			last_vote_at    BIGINT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS votes (
+			commit_sha    TEXT NOT NULL REFERENCES commits (sha),
+			token         TEXT NOT NULL REFERENCES proposals (token),
+			ticket        TEXT NOT NULL,
+			vote_bit      TEXT NOT NULL,
+			flushed_at    BIGINT NOT NULL,
+			cast_earliest BIGINT NOT NULL,
+			cast_latest   BIGINT NOT NULL,
+			PRIMARY KEY (commit_sha, token, ticket)
+		)`,
+		`CREATE INDEX IF NOT EXISTS votes_token_idx ON votes (token)`,
+		`CREATE INDEX IF NOT EXISTS votes_ticket_idx ON votes (ticket)`,
+		`CREATE TABLE IF NOT EXISTS sync_state (
+			name             TEXT PRIMARY KEY,
+			head_sha         TEXT NOT NULL,
+			head_commit_time BIGINT NOT NULL,
+			synced_at        BIGINT NOT NULL
+		)`,
+	},
+
+	// 2: the proposal comments. A censored comment keeps its text and
+	// references the commit that censored it.
+	{
+		`CREATE TABLE IF NOT EXISTS comments (
+			token               TEXT NOT NULL,
+			comment_id          TEXT NOT NULL,
+			version             TEXT NOT NULL,
+			parent_id           TEXT NOT NULL,
+			comment             TEXT NOT NULL,
+			public_key          TEXT NOT NULL,
+			signature           TEXT NOT NULL,
+			receipt             TEXT NOT NULL,
+			created_at          BIGINT NOT NULL,
+			commit_sha          TEXT NOT NULL REFERENCES commits (sha),
+			censored_commit_sha TEXT REFERENCES commits (sha),
+			censor_reason       TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (token, comment_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS comments_commit_idx ON comments (commit_sha)`,
+		`CREATE INDEX IF NOT EXISTS comments_censored_commit_idx ON comments (censored_commit_sha)`,
+	},
+}
+
+const (
+	// createMigrationsTable creates the table that records the applied
+	// migrations.
+	createMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at BIGINT NOT NULL
+	)`
+
+	selectMigrationVersion = `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`
+	insertMigrationVersion = `INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`
+
+	// The upserts are keyed by the commit SHA, the proposal token and the
+	// ticket thus writing the same history again has no effect.
+	upsertCommit = `INSERT INTO commits
+		(sha, author, committer, authored_at, committed_at, message)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (sha) DO UPDATE SET
+			author = excluded.author,
+			committer = excluded.committer,
+			authored_at = excluded.authored_at,
+			committed_at = excluded.committed_at,
+			message = excluded.message`
+
+	// The proposal keeps the version and the commit of its latest votes.
+	upsertProposal = `INSERT INTO proposals
+		(token, version, last_commit_sha, last_vote_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (token) DO UPDATE SET
+			version = excluded.version,
+			last_commit_sha = excluded.last_commit_sha,
+			last_vote_at = excluded.last_vote_at
+		WHERE excluded.last_vote_at >= proposals.last_vote_at`
+
+	upsertVote = `INSERT INTO votes
+		(commit_sha, token, ticket, vote_bit, flushed_at, cast_earliest, cast_latest)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (commit_sha, token, ticket) DO UPDATE SET
+			vote_bit = excluded.vote_bit,
+			flushed_at = excluded.flushed_at,
+			cast_earliest = excluded.cast_earliest,
+			cast_latest = excluded.cast_latest`
+
+	upsertComment = `INSERT INTO comments
+		(token, comment_id, version, parent_id, comment, public_key, signature,
+			receipt, created_at, commit_sha)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (token, comment_id) DO UPDATE SET
+			version = excluded.version,
+			parent_id = excluded.parent_id,
+			comment = excluded.comment,
+			public_key = excluded.public_key,
+			signature = excluded.signature,
+			receipt = excluded.receipt,
+			created_at = excluded.created_at,
+			commit_sha = excluded.commit_sha`
+
+	censorComment = `UPDATE comments SET censored_commit_sha = ?, censor_reason = ?
+		WHERE token = ? AND comment_id = ?`
+
+	upsertCheckpoint = `INSERT INTO sync_state
+		(name, head_sha, head_commit_time, synced_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET
+			head_sha = excluded.head_sha,
+			head_commit_time = excluded.head_commit_time,
+			synced_at = excluded.synced_at`
+
+	// The rows of a commit dropped from the history are deleted in the
+	// order below. The proposals whose latest votes were dropped are moved
+	// to their latest votes left, or deleted if none is left.
+	deleteCommitVotes = `DELETE FROM votes WHERE commit_sha = ?`
+
+	deleteCommitComments = `DELETE FROM comments WHERE commit_sha = ?`
+
+	uncensorComments = `UPDATE comments SET censored_commit_sha = NULL, censor_reason = ''
+		WHERE censored_commit_sha = ?`
+
+	deleteDroppedProposals = `DELETE FROM proposals WHERE last_commit_sha = ?
+		AND NOT EXISTS (SELECT 1 FROM votes WHERE votes.token = proposals.token)`
+
+	updateDroppedProposals = `UPDATE proposals SET
+		last_commit_sha = (SELECT c.sha FROM votes v JOIN commits c ON c.sha = v.commit_sha
+			WHERE v.token = proposals.token ORDER BY c.authored_at DESC, c.sha LIMIT 1),
+		last_vote_at = (SELECT MAX(c.authored_at) FROM votes v JOIN commits c
+			ON c.sha = v.commit_sha WHERE v.token = proposals.token)
+		WHERE last_commit_sha = ?`
+
+	deleteCommit = `DELETE FROM commits WHERE sha = ?`
+
+	// The tables are emptied in the order below if the dropped commits are
+	// unknown.
+	deleteAllVotes     = `DELETE FROM votes`
+	deleteAllComments  = `DELETE FROM comments`
+	deleteAllProposals = `DELETE FROM proposals`
+	deleteAllCommits   = `DELETE FROM commits`
+
+	selectCheckpoint = `SELECT head_sha, head_commit_time, synced_at FROM sync_state
+		WHERE name = ?`
+)
+
+// Migrate applies the migrations that have not been applied yet. Each one is
+// applied in its own transaction and recorded on success.
+func (s *Sink) Migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, createMigrationsTable); err != nil {
+		return fmt.Errorf("creating the migrations table failed: %v", err)
+	}
+
+	var version int
+	if err := s.db.QueryRowContext(ctx, selectMigrationVersion).Scan(&version); err != nil {
+		return fmt.Errorf("reading the schema version failed: %v", err)
+	}
+
+	for ; version < len(migrations); version++ {
+		if err := s.migrate(ctx, version+1, migrations[version]); err != nil {
+			return fmt.Errorf("applying the migration %d failed: %v", version+1, err)
+		}
+	}
+
+	return nil
+}
+
+// migrate runs the statements of a migration and records its version in a
+// single transaction.
+func (s *Sink) migrate(ctx context.Context, version int, statements []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, stmt := range statements {
+		if _, err = tx.ExecContext(ctx, stmt); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, s.rebind(insertMigrationVersion), version, unix(time.Now()))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,429 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+// Package sqlsink mirrors the votes and the comments parsed by the
+// proposals.Parser into normalized SQLite or PostgreSQL tables via
+// database/sql. The writes are
+// idempotent upserts thus the same history can be written several times. The
+// applications register the database driver of their choice.
+package sqlsink
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dmigwi/go-piparser/proposals"
+	"github.com/dmigwi/go-piparser/proposals/types"
+)
+
+// Dialect defines the SQL dialect of the database written to.
+type Dialect int
+
+const (
+	// SQLite is the dialect of the SQLite databases. Version 3.24 or later
+	// is required for the upserts.
+	SQLite Dialect = iota
+
+	// Postgres is the dialect of the PostgreSQL databases. Version 9.5 or
+	// later is required for the upserts.
+	Postgres
+)
+
+// String is the default stringer for the Dialect data type.
+func (d Dialect) String() string {
+	switch d {
+	case SQLite:
+		return "sqlite"
+	case Postgres:
+		return "postgres"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	// checkpointName is the sync_state row that holds the sync checkpoint.
+	checkpointName = "votes"
+
+	// batchSize is the number of commits written per transaction while
+	// syncing.
+	batchSize = 500
+
+	// updatesBuffer is the number of parser updates buffered while the
+	// previous update is being written.
+	updatesBuffer = 16
+)
+
+// Source defines the proposals.Parser methods used to sync the votes and the
+// comments.
+type Source interface {
+	HeadSHA() string
+	HeadCommitTime() time.Time
+	WalkProposalsHistory(since time.Time, fn proposals.WalkFunc,
+		filters ...proposals.Filter) error
+	WalkCommentsHistory(since time.Time, fn proposals.CommentsWalkFunc) error
+	Subscribe(buffer int) (<-chan *proposals.Update, func())
+}
+
+// Checkpoint is the snapshot of the repository that the database was last
+// synced to.
+type Checkpoint struct {
+	HeadSHA        string
+	HeadCommitTime time.Time
+	SyncedAt       time.Time
+}
+
+// Sink writes the parsed votes to the database.
+type Sink struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+// New returns a Sink that writes to the provided database. Migrate must be run
+// before the first write.
+func New(db *sql.DB, dialect Dialect) *Sink {
+	return &Sink{db: db, dialect: dialect}
+}
+
+// rebind converts the "?" placeholders of the query to the dialect ones.
+func (s *Sink) rebind(query string) string {
+	if s.dialect != Postgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Write upserts the commits in the history, their votes and the proposals
+// voted on in a single transaction. If the checkpoint is set, the sync
+// checkpoint is moved to it in the same transaction.
+func (s *Sink) Write(ctx context.Context, history []*types.History, checkpoint *Checkpoint) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		return s.write(ctx, tx, history, checkpoint)
+	})
+}
+
+// WriteComments upserts the commits in the comments history and their
+// comments in a single transaction. The censored comments are marked as such.
+func (s *Sink) WriteComments(ctx context.Context, history []*types.CommentsHistory) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		return s.writeComments(ctx, tx, history)
+	})
+}
+
+// Retract deletes the commits dropped from the history by the rollback, their
+// votes and comments, and the proposals only voted on by them in a single
+// transaction. The comments censored by the dropped commits are uncensored.
+// All the rows are deleted if the dropped commits are unknown. The sync
+// checkpoint is not moved.
+func (s *Sink) Retract(ctx context.Context, r *proposals.Rollback) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		return s.retract(ctx, tx, r)
+	})
+}
+
+// inTx runs fn in a transaction that is committed if fn succeeds and rolled
+// back otherwise.
+func (s *Sink) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err = fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// retract runs the deletes in the provided transaction.
+func (s *Sink) retract(ctx context.Context, tx *sql.Tx, r *proposals.Rollback) error {
+	if r.Unknown {
+		queries := []string{deleteAllVotes, deleteAllComments, deleteAllProposals,
+			deleteAllCommits}
+		for _, query := range queries {
+			if _, err := tx.ExecContext(ctx, query); err != nil {
+				return fmt.Errorf("retracting the history failed: %v", err)
+			}
+		}
+		return nil
+	}
+
+	for _, sha := range r.Commits {
+		queries := []string{deleteCommitVotes, deleteDroppedProposals,
+			updateDroppedProposals, deleteCommitComments, uncensorComments, deleteCommit}
+		for _, query := range queries {
+			if _, err := tx.ExecContext(ctx, s.rebind(query), sha); err != nil {
+				return fmt.Errorf("retracting the commit %s failed: %v", sha, err)
+			}
+		}
+	}
+	return nil
+}
+
+// write runs the upserts in the provided transaction.
+func (s *Sink) write(ctx context.Context, tx *sql.Tx, history []*types.History,
+	checkpoint *Checkpoint) error {
+	for _, h := range history {
+		// The History objects with empty fields are not stored.
+		if h.CommitSHA == "" {
+			continue
+		}
+
+		_, err := tx.ExecContext(ctx, s.rebind(upsertCommit), h.CommitSHA, h.Author,
+			h.Committer, unix(h.Date), unix(h.CommitDate), h.Message)
+		if err != nil {
+			return fmt.Errorf("writing the commit %s failed: %v", h.CommitSHA, err)
+		}
+
+		for _, f := range h.Patch {
+			_, err = tx.ExecContext(ctx, s.rebind(upsertProposal), f.Token, f.Version,
+				h.CommitSHA, unix(h.Date))
+			if err != nil {
+				return fmt.Errorf("writing the proposal %s failed: %v", f.Token, err)
+			}
+
+			for _, v := range f.VotesInfo {
+				if v.PiVote == nil {
+					continue
+				}
+
+				_, err = tx.ExecContext(ctx, s.rebind(upsertVote), h.CommitSHA, f.Token,
+					v.Ticket, string(v.VoteBit), unix(v.FlushedAt),
+					unix(v.EstimatedCastAt.Earliest), unix(v.EstimatedCastAt.Latest))
+				if err != nil {
+					return fmt.Errorf("writing the vote of %s failed: %v", v.Ticket, err)
+				}
+			}
+		}
+	}
+
+	if checkpoint == nil || checkpoint.HeadSHA == "" {
+		return nil
+	}
+
+	_, err := tx.ExecContext(ctx, s.rebind(upsertCheckpoint), checkpointName,
+		checkpoint.HeadSHA, unix(checkpoint.HeadCommitTime), unix(time.Now()))
+	if err != nil {
+		return fmt.Errorf("writing the sync checkpoint failed: %v", err)
+	}
+	return nil
+}
+
+// writeComments runs the comments upserts in the provided transaction.
+func (s *Sink) writeComments(ctx context.Context, tx *sql.Tx, history []*types.CommentsHistory) error {
+	for _, h := range history {
+		_, err := tx.ExecContext(ctx, s.rebind(upsertCommit), h.CommitSHA, h.Author,
+			h.Committer, unix(h.Date), unix(h.CommitDate), h.Message)
+		if err != nil {
+			return fmt.Errorf("writing the commit %s failed: %v", h.CommitSHA, err)
+		}
+
+		for _, c := range h.Comments {
+			if c.Action == types.CommentCensored {
+				_, err = tx.ExecContext(ctx, s.rebind(censorComment), h.CommitSHA,
+					c.Reason, c.Token, c.CommentID)
+			} else {
+				_, err = tx.ExecContext(ctx, s.rebind(upsertComment), c.Token, c.CommentID,
+					c.Version, c.ParentID, c.Comment, c.PublicKey, c.Signature, c.Receipt,
+					unix(c.Timestamp), h.CommitSHA)
+			}
+			if err != nil {
+				return fmt.Errorf("writing the comment %s of %s failed: %v", c.CommentID,
+					c.Token, err)
+			}
+		}
+	}
+	return nil
+}
+
+// syncComments writes the comments added after the since time, if set.
+func (s *Sink) syncComments(ctx context.Context, src Source, since time.Time) error {
+	var batch []*types.CommentsHistory
+	err := src.WalkCommentsHistory(since, func(h *types.CommentsHistory) error {
+		if batch = append(batch, h); len(batch) < batchSize {
+			return nil
+		}
+
+		err := s.WriteComments(ctx, batch)
+		batch = batch[:0]
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("syncing the comments failed: %v", err)
+	}
+
+	return s.WriteComments(ctx, batch)
+}
+
+// Checkpoint returns the snapshot that the database was last synced to. A nil
+// checkpoint is returned if it has never been synced.
+func (s *Sink) Checkpoint(ctx context.Context) (*Checkpoint, error) {
+	var sha string
+	var headTime, syncedAt int64
+
+	err := s.db.QueryRowContext(ctx, s.rebind(selectCheckpoint), checkpointName).
+		Scan(&sha, &headTime, &syncedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("reading the sync checkpoint failed: %v", err)
+	}
+
+	return &Checkpoint{
+		HeadSHA:        sha,
+		HeadCommitTime: fromUnix(headTime),
+		SyncedAt:       fromUnix(syncedAt),
+	}, nil
+}
+
+// Sync writes the votes and the comments history made since the checkpoint
+// commit date, or the complete history if the database has never been synced,
+// and then moves the checkpoint to the source HEAD. The commits written before
+// are upserted again which has no effect.
+func (s *Sink) Sync(ctx context.Context, src Source) error {
+	checkpoint, err := s.Checkpoint(ctx)
+	if err != nil {
+		return err
+	}
+
+	var since time.Time
+	if checkpoint != nil {
+		since = checkpoint.HeadCommitTime
+	}
+
+	// The HEAD is read before the walk thus the checkpoint never gets ahead
+	// of the history written.
+	next := &Checkpoint{HeadSHA: src.HeadSHA(), HeadCommitTime: src.HeadCommitTime()}
+
+	var batch []*types.History
+	err = src.WalkProposalsHistory(since, func(h *types.History) error {
+		if batch = append(batch, h); len(batch) < batchSize {
+			return nil
+		}
+
+		err := s.Write(ctx, batch, nil)
+		batch = batch[:0]
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("syncing the votes failed: %v", err)
+	}
+
+	// The comments are written before the checkpoint is moved.
+	if err = s.syncComments(ctx, src, since); err != nil {
+		return err
+	}
+
+	return s.Write(ctx, batch, next)
+}
+
+// Run syncs the database and then writes the history fetched by every update
+// made by the source until the context is cancelled. The commits dropped by
+// the update rollbacks are retracted in the same transaction. The comments
+// added since the previous update are written after each update. An update
+// that does not follow the HEAD last written, i.e. the subscription dropped
+// the updates in between, is replaced by a sync from the checkpoint.
+func (s *Sink) Run(ctx context.Context, src Source) error {
+	// Subscribe before syncing so that no update is missed. The commits
+	// written twice are only stored once.
+	updates, cancel := src.Subscribe(updatesBuffer)
+	defer cancel()
+
+	// headSHA is the HEAD last written while commentsSince is the commit
+	// date of the HEAD whose comments were last written. Both are read
+	// before the sync thus they never get ahead of the rows written.
+	headSHA, commentsSince := src.HeadSHA(), src.HeadCommitTime()
+	if err := s.Sync(ctx, src); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case u, ok := <-updates:
+			if !ok {
+				return nil
+			}
+
+			if u.PreviousSHA != headSHA {
+				headSHA, commentsSince = src.HeadSHA(), src.HeadCommitTime()
+				if err := s.Sync(ctx, src); err != nil {
+					return err
+				}
+				continue
+			}
+
+			// The checkpoint is only moved if the date of the history
+			// written is known. The history is listed oldest first.
+			var checkpoint *Checkpoint
+			if n := len(u.History); n > 0 {
+				checkpoint = &Checkpoint{HeadSHA: u.HeadSHA,
+					HeadCommitTime: u.History[n-1].CommitDate}
+			}
+
+			err := s.inTx(ctx, func(tx *sql.Tx) error {
+				if u.Rollback != nil {
+					if err := s.retract(ctx, tx, u.Rollback); err != nil {
+						return err
+					}
+				}
+				return s.write(ctx, tx, u.History, checkpoint)
+			})
+			if err != nil {
+				return err
+			}
+
+			// The comments of the rewritten history are all walked again
+			// since the commits replacing the dropped ones may be older.
+			if u.Rollback != nil {
+				commentsSince = time.Time{}
+			}
+
+			next := src.HeadCommitTime()
+			if err = s.syncComments(ctx, src, commentsSince); err != nil {
+				return err
+			}
+			headSHA, commentsSince = u.HeadSHA, next
+		}
+	}
+}
+
+// unix returns the unix timestamp of the provided time. Zero is returned for
+// the zero time.
+func unix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// fromUnix returns the time of the provided unix timestamp. The zero time is
+// returned for zero.
+func fromUnix(secs int64) time.Time {
+	if secs == 0 {
+		return time.Time{}
+	}
+	return time.Unix(secs, 0).UTC()
+}
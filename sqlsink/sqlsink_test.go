@@ -0,0 +1,438 @@
+package sqlsink
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dmigwi/go-piparser/proposals"
+	"github.com/dmigwi/go-piparser/proposals/types"
+	"github.com/dmigwi/go-piparser/v1/data"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newTestSink returns a migrated sink writing to a new SQLite database.
+func newTestSink(t *testing.T) *Sink {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "votes.db"))
+	if err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	s := New(db, SQLite)
+	if err = s.Migrate(context.Background()); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+	return s
+}
+
+// count returns the number of rows in the table.
+func count(t *testing.T, s *Sink, table string) int {
+	var n int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+	return n
+}
+
+// expectedRows returns the number of commits, proposals and votes stored for
+// the provided history.
+func expectedRows(history []*types.History) (commits, proposals, votes int) {
+	tokens := make(map[string]bool)
+	for _, h := range history {
+		if h.CommitSHA == "" {
+			continue
+		}
+
+		commits++
+		for _, f := range h.Patch {
+			tokens[f.Token] = true
+			votes += len(f.VotesInfo)
+		}
+	}
+	return commits, len(tokens), votes
+}
+
+// testToken is the token of the proposal commented on in the comments fixtures.
+const testToken = "27f87171d98b7923a1bd2bee6affed929fa2d2a6e178b5c80a9971a92a5c7f50"
+
+// testComments returns a commit adding a comment and a reply to it, followed by
+// a commit censoring the comment.
+func testComments() []*types.CommentsHistory {
+	date := time.Date(2019, 3, 6, 11, 58, 1, 0, time.UTC)
+	comment := func(action types.CommentAction, id, parentID string) *types.Comment {
+		return &types.Comment{Action: action, Token: testToken, Version: "1",
+			CommentID: id, ParentID: parentID, Comment: "comment " + id,
+			PublicKey: "3e4d", Signature: "1f23", Receipt: "9f1c", Timestamp: date}
+	}
+
+	censored := comment(types.CommentCensored, "1", "")
+	censored.Reason = "spam"
+
+	return []*types.CommentsHistory{
+		{CommitSHA: "comments", Author: "politeia", Date: date, Committer: "politeia",
+			CommitDate: date, Message: "Add comments",
+			Comments: []*types.Comment{comment(types.CommentAdded, "1", "0"),
+				comment(types.CommentAdded, "2", "1")}},
+		{CommitSHA: "censor", Author: "politeia", Date: date.Add(time.Hour),
+			Committer: "politeia", CommitDate: date.Add(time.Hour), Message: "Censor",
+			Comments: []*types.Comment{censored}},
+	}
+}
+
+// TestMigrate tests that the migrations are only applied once.
+func TestMigrate(t *testing.T) {
+	s := newTestSink(t)
+
+	if err := s.Migrate(context.Background()); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	if n := count(t, s, "schema_migrations"); n != len(migrations) {
+		t.Fatalf("expected %d migrations applied but found %d", len(migrations), n)
+	}
+}
+
+// TestWrite tests that writing the same history several times stores it once.
+func TestWrite(t *testing.T) {
+	s := newTestSink(t)
+	commits, tokens, votes := expectedRows(data.AllTokensVotesData)
+
+	for i := 0; i < 2; i++ {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			err := s.Write(context.Background(), data.AllTokensVotesData, nil)
+			if err != nil {
+				t.Fatalf("expected no error but found: %v", err)
+			}
+
+			if n := count(t, s, "commits"); n != commits {
+				t.Fatalf("expected %d commits but found %d", commits, n)
+			}
+
+			if n := count(t, s, "proposals"); n != tokens {
+				t.Fatalf("expected %d proposals but found %d", tokens, n)
+			}
+
+			if n := count(t, s, "votes"); n != votes {
+				t.Fatalf("expected %d votes but found %d", votes, n)
+			}
+		})
+	}
+
+	var bit string
+	err := s.db.QueryRow("SELECT vote_bit FROM votes WHERE ticket = ?",
+		"e272d314b1f6a15c4480145ab286a54bb9b6735718b776755fea7c77eba030b8").Scan(&bit)
+	if err != nil || bit != "Yes" {
+		t.Fatalf("expected the vote bit Yes but found %q: %v", bit, err)
+	}
+}
+
+// TestRetract tests that the rows of the dropped commits are deleted.
+func TestRetract(t *testing.T) {
+	s := newTestSink(t)
+	history := data.AllTokensVotesData
+
+	if err := s.Write(context.Background(), history, nil); err != nil {
+		t.Fatalf("expected no error but found: %v", err)
+	}
+
+	td := []struct {
+		rollback *proposals.Rollback
+		kept     []*types.History
+	}{
+		{&proposals.Rollback{Commits: []string{history[len(history)-1].CommitSHA}},
+			history[:len(history)-1]},
+		{&proposals.Rollback{Unknown: true}, nil},
+	}
+
+	for i, val := range td {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			if err := s.Retract(context.Background(), val.rollback); err != nil {
+				t.Fatalf("expected no error but found: %v", err)
+			}
+
+			commits, tokens, votes := expectedRows(val.kept)
+			if n := count(t, s, "commits"); n != commits {
+				t.Fatalf("expected %d commits but found %d", commits, n)
+			}
+
+			if n := count(t, s, "proposals"); n != tokens {
+				t.Fatalf("expected %d proposals but found %d", tokens, n)
+			}
+
+			if n := count(t, s, "votes"); n != votes {
+				t.Fatalf("expected %d votes but found %d", votes, n)
+			}
+
+			// The proposals left reference the commits left.
+			var dangling int
+			err := s.db.QueryRow(`SELECT COUNT(*) FROM proposals WHERE last_commit_sha
+				NOT IN (SELECT sha FROM commits)`).Scan(&dangling)
+			if err != nil || dangling != 0 {
+				t.Fatalf("expected no dangling proposals but found %d: %v", dangling, err)
+			}
+		})
+	}
+}
+
+// TestWriteComments tests that the comments and their censoring are written
+// once and retracted with their commits.
+func TestWriteComments(t *testing.T) {
+	s := newTestSink(t)
+	history := testComments()
+
+	for i := 0; i < 2; i++ {
+		if err := s.WriteComments(context.Background(), history); err != nil {
+			t.Fatalf("expected no error but found: %v", err)
+		}
+	}
+
+	censoredBy := func() (sha sql.NullString, reason string) {
+		err := s.db.QueryRow(`SELECT censored_commit_sha, censor_reason FROM comments
+			WHERE token = ? AND comment_id = ?`, testToken, "1").Scan(&sha, &reason)
+		if err != nil {
+			t.Fatalf("expected no error but found: %v", err)
+		}
+		return sha, reason
+	}
+
+	if n := count(t, s, "comments"); n != 2 {
+		t.Fatalf("expected 2 comments but found %d", n)
+	}
+
+	if sha, reason := censoredBy(); sha.String != "censor" || reason != "spam" {
+		t.Fatalf("expected the comment censored by censor but found %v %q", sha, reason)
+	}
+
+	td := []struct {
+		rollback *proposals.Rollback
+		comments int
+		commits  int
+	}{
+		{&proposals.Rollback{Commits: []string{"censor"}}, 2, 1},
+		{&proposals.Rollback{Commits: []string{"comments"}}, 0, 0},
+	}
+
+	for i, val := range td {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			if err := s.Retract(context.Background(), val.rollback); err != nil {
+				t.Fatalf("expected no error but found: %v", err)
+			}
+
+			if n := count(t, s, "comments"); n != val.comments {
+				t.Fatalf("expected %d comments but found %d", val.comments, n)
+			}
+
+			if n := count(t, s, "commits"); n != val.commits {
+				t.Fatalf("expected %d commits but found %d", val.commits, n)
+			}
+
+			if val.comments > 0 {
+				if sha, reason := censoredBy(); sha.Valid || reason != "" {
+					t.Fatalf("expected the comment uncensored but found %v %q", sha, reason)
+				}
+			}
+		})
+	}
+}
+
+// TestCheckpoint tests that the checkpoint written is read back.
+func TestCheckpoint(t *testing.T) {
+	s := newTestSink(t)
+
+	checkpoint, err := s.Checkpoint(context.Background())
+	if err != nil || checkpoint != nil {
+		t.Fatalf("expected no checkpoint but found %v: %v", checkpoint, err)
+	}
+
+	headTime := time.Date(2019, 3, 24, 10, 58, 1, 0, time.UTC)
+	for i, sha := range []string{"first", "second"} {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			err := s.Write(context.Background(), nil, &Checkpoint{HeadSHA: sha,
+				HeadCommitTime: headTime})
+			if err != nil {
+				t.Fatalf("expected no error but found: %v", err)
+			}
+
+			checkpoint, err := s.Checkpoint(context.Background())
+			if err != nil {
+				t.Fatalf("expected no error but found: %v", err)
+			}
+
+			if checkpoint.HeadSHA != sha || !checkpoint.HeadCommitTime.Equal(headTime) ||
+				checkpoint.SyncedAt.IsZero() {
+				t.Fatalf("expected the checkpoint %s at %v but found %+v", sha,
+					headTime, checkpoint)
+			}
+		})
+	}
+}
+
+// testSource walks the history fixtures in place of a proposals.Parser.
+type testSource struct {
+	history       []*types.History
+	comments      []*types.CommentsHistory
+	since         []time.Time
+	commentsSince []time.Time
+	updates       chan *proposals.Update
+}
+
+func (s *testSource) HeadSHA() string { return "head" }
+
+func (s *testSource) HeadCommitTime() time.Time {
+	return time.Date(2019, 3, 24, 10, 58, 1, 0, time.UTC)
+}
+
+func (s *testSource) WalkProposalsHistory(since time.Time, fn proposals.WalkFunc,
+	filters ...proposals.Filter) error {
+	s.since = append(s.since, since)
+	for _, h := range s.history {
+		if err := fn(h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *testSource) WalkCommentsHistory(since time.Time,
+	fn proposals.CommentsWalkFunc) error {
+	s.commentsSince = append(s.commentsSince, since)
+	for _, h := range s.comments {
+		if err := fn(h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *testSource) Subscribe(buffer int) (<-chan *proposals.Update, func()) {
+	return s.updates, func() {}
+}
+
+// TestSync tests that the sync resumes from the checkpoint.
+func TestSync(t *testing.T) {
+	s := newTestSink(t)
+	src := &testSource{history: data.AllTokensVotesData, comments: testComments()}
+	commits, _, _ := expectedRows(data.AllTokensVotesData)
+	commits += len(src.comments)
+
+	for i := 0; i < 2; i++ {
+		if err := s.Sync(context.Background(), src); err != nil {
+			t.Fatalf("expected no error but found: %v", err)
+		}
+	}
+
+	if !src.since[0].IsZero() || !src.since[1].Equal(src.HeadCommitTime()) {
+		t.Fatalf("expected the second sync to resume from the checkpoint but found %v",
+			src.since)
+	}
+
+	if !src.commentsSince[0].IsZero() || !src.commentsSince[1].Equal(src.HeadCommitTime()) {
+		t.Fatalf("expected the comments sync to resume from the checkpoint but found %v",
+			src.commentsSince)
+	}
+
+	if n := count(t, s, "commits"); n != commits {
+		t.Fatalf("expected %d commits but found %d", commits, n)
+	}
+
+	if n := count(t, s, "comments"); n != 2 {
+		t.Fatalf("expected 2 comments but found %d", n)
+	}
+}
+
+// TestRun tests that the updates are written until the context is cancelled.
+func TestRun(t *testing.T) {
+	s := newTestSink(t)
+	src := &testSource{comments: testComments(), updates: make(chan *proposals.Update)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx, src) }()
+
+	src.updates <- &proposals.Update{PreviousSHA: "head", HeadSHA: "update",
+		History: data.AllTokensVotesData}
+	src.updates <- &proposals.Update{PreviousSHA: "update", HeadSHA: "empty"}
+	cancel()
+
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("expected the context canceled error but found: %v", err)
+	}
+
+	// The comments are synced once then after each update, from the HEAD
+	// commit date seen before the previous sync.
+	if len(src.commentsSince) != 3 || !src.commentsSince[2].Equal(src.HeadCommitTime()) {
+		t.Fatalf("expected 3 comments syncs since the HEAD but found %v", src.commentsSince)
+	}
+
+	commits, _, _ := expectedRows(data.AllTokensVotesData)
+	if n := count(t, s, "commits"); n != commits+len(src.comments) {
+		t.Fatalf("expected %d commits but found %d", commits+len(src.comments), n)
+	}
+
+	// The update without history does not move the checkpoint.
+	checkpoint, err := s.Checkpoint(context.Background())
+	if err != nil || checkpoint.HeadSHA != "update" {
+		t.Fatalf("expected the checkpoint update but found %+v: %v", checkpoint, err)
+	}
+}
+
+// TestRunMissedUpdates tests that an update that does not follow the HEAD last
+// written is replaced by a sync from the checkpoint.
+func TestRunMissedUpdates(t *testing.T) {
+	s := newTestSink(t)
+	src := &testSource{updates: make(chan *proposals.Update)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx, src) }()
+
+	src.updates <- &proposals.Update{PreviousSHA: "missed", HeadSHA: "update",
+		History: data.AllTokensVotesData}
+	// The next update follows the HEAD synced thus it is written.
+	src.updates <- &proposals.Update{PreviousSHA: "head", HeadSHA: "empty"}
+	cancel()
+
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("expected the context canceled error but found: %v", err)
+	}
+
+	if len(src.since) != 2 || !src.since[1].Equal(src.HeadCommitTime()) {
+		t.Fatalf("expected a second sync from the checkpoint but found %v", src.since)
+	}
+
+	// The history of the missed update is not written.
+	if n := count(t, s, "commits"); n != 0 {
+		t.Fatalf("expected no commits but found %d", n)
+	}
+
+	checkpoint, err := s.Checkpoint(context.Background())
+	if err != nil || checkpoint.HeadSHA != src.HeadSHA() {
+		t.Fatalf("expected the checkpoint %s but found %+v: %v", src.HeadSHA(),
+			checkpoint, err)
+	}
+}
+
+// TestRebind tests the placeholders conversion.
+func TestRebind(t *testing.T) {
+	td := []struct {
+		dialect  Dialect
+		query    string
+		expected string
+	}{
+		{SQLite, "SELECT a FROM b WHERE c = ? AND d = ?", "SELECT a FROM b WHERE c = ? AND d = ?"},
+		{Postgres, "SELECT a FROM b WHERE c = ? AND d = ?", "SELECT a FROM b WHERE c = $1 AND d = $2"},
+		{Postgres, "SELECT a FROM b", "SELECT a FROM b"},
+	}
+
+	for i, val := range td {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			if q := New(nil, val.dialect).rebind(val.query); q != val.expected {
+				t.Fatalf("expected the query %q but found %q", val.expected, q)
+			}
+		})
+	}
+}
@@ -2,7 +2,7 @@ package main
 
 import (
 	"html/template"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -11,12 +11,18 @@ import (
 	"time"
 
 	"github.com/dmigwi/go-piparser/proposals"
+	"github.com/dmigwi/go-piparser/v1/server"
 	"github.com/gorilla/mux"
 )
 
 var tmpl *template.Template
 var parser *proposals.Parser
 
+// logger writes the test client and the Parser events to the std error.
+var logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+	Level: slog.LevelDebug,
+}))
+
 // chartData defines the charts data to be used by charts js.
 type chartData struct {
 	Yes  []int       // Yes
@@ -33,13 +39,13 @@ func init() {
 func handleProposal(w http.ResponseWriter, r *http.Request) {
 	proposalToken := mux.Vars(r)["token"]
 
-	log.Printf("Retrieving details for %s ...\n", proposalToken)
+	logger.Info("retrieving the proposal details", "token", proposalToken)
 	data, err := parser.ProposalHistory(proposalToken)
 	if err != nil {
-		log.Printf("unexpected error occured: %v", err)
+		logger.Error("unexpected error occured", "error", err)
 	}
 
-	log.Printf("Processing charts data for %s ...\n", proposalToken)
+	logger.Info("processing the charts data", "token", proposalToken)
 	var graph chartData
 	var y, n int
 
@@ -57,7 +63,7 @@ func handleProposal(w http.ResponseWriter, r *http.Request) {
 					y++
 
 				default:
-					log.Printf("Invalid vote bit found: %v", vote.VoteBit)
+					logger.Warn("invalid vote bit found", "votebit", vote.VoteBit)
 				}
 			}
 		}
@@ -78,7 +84,7 @@ func handleProposal(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if y > 0 {
-		log.Printf("Found Yes: %d No: %d and Total %d \n", y, n, n+y)
+		logger.Info("votes found", "yes", y, "no", n, "total", n+y)
 	}
 
 	if err != nil {
@@ -86,10 +92,11 @@ func handleProposal(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := tmpl.Execute(w, payload); err != nil {
-		log.Fatalf("error found: %v", err)
+		logger.Error("executing the template failed", "error", err)
+		os.Exit(1)
 	}
 
-	log.Println("Done.")
+	logger.Info("done", "token", proposalToken)
 }
 
 // fetch the home directory
@@ -107,11 +114,11 @@ func main() {
 	var err error
 	cloneDir := filepath.Join(homeDir(), "playground")
 
-	log.Println("Setting up the environment. Please Wait...")
+	logger.Info("setting up the environment, please wait...")
 
-	parser, err = proposals.NewParser("", "", cloneDir)
+	parser, err = proposals.NewParser("", "", cloneDir, proposals.WithLogger(logger))
 	if err != nil {
-		log.Printf("unexpected error occured: %v", err)
+		logger.Error("unexpected error occured", "error", err)
 		return
 	}
 
@@ -119,7 +126,11 @@ func main() {
 	rtr.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
 		tmpl.Execute(w, nil)
 	})
-	rtr.HandleFunc("/{token:[A-z0-9]{64}}", handleProposal).Methods("GET")
+	// The API pushes the new votes to the charts as they are fetched.
+	rtr.PathPrefix(server.APIPrefix).Handler(server.New(parser))
+	rtr.HandleFunc(server.HealthPath, server.HealthHandler(parser)).Methods("GET")
+	rtr.HandleFunc(server.ReadyPath, server.ReadyHandler(parser)).Methods("GET")
+	rtr.HandleFunc("/{token:[A-z0-9]{64}|[0-9a-f]{16}}", handleProposal).Methods("GET")
 	fs := http.FileServer(http.Dir("public"))
 
 	http.Handle("/", rtr)
@@ -130,10 +141,11 @@ func main() {
 	wg.Add(1)
 
 	go func() {
-		log.Println("Serving on http://127.0.0.1:8080")
+		logger.Info("serving on http://127.0.0.1:8080")
 
 		if err := http.ListenAndServe(":8080", nil); err != nil {
-			log.Fatalf("error occured: %v ", err)
+			logger.Error("error occured", "error", err)
+			os.Exit(1)
 
 			wg.Done()
 		}
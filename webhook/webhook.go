@@ -0,0 +1,156 @@
+// Copyright 2019 Migwi Ndung'u.
+// License that can be found in the LICENSE file.
+
+// Package webhook implements the http handler that receives the GitHub and
+// Gitea push webhooks of the proposals repository and requests an immediate
+// Parser update for each push.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	// maxPayloadSize is the maximum webhook payload size read. GitHub caps
+	// the payloads at 25MB.
+	maxPayloadSize = 25 << 20
+
+	// githubEventHeader and githubSignatureHeader are the GitHub webhook
+	// event name and the "sha256=" prefixed payload signature headers.
+	githubEventHeader     = "X-GitHub-Event"
+	githubSignatureHeader = "X-Hub-Signature-256"
+	githubSignaturePrefix = "sha256="
+
+	// giteaEventHeader and giteaSignatureHeader are the Gitea webhook event
+	// name and the payload signature headers.
+	giteaEventHeader     = "X-Gitea-Event"
+	giteaSignatureHeader = "X-Gitea-Signature"
+
+	// pushEvent and pingEvent are the handled webhook event names.
+	pushEvent = "push"
+	pingEvent = "ping"
+)
+
+// Updater defines the proposals.Parser method used to request an immediate
+// update.
+type Updater interface {
+	RequestUpdate()
+}
+
+// Config defines how the webhooks are validated.
+type Config struct {
+	// Secret is the webhook secret that the payloads are signed with using
+	// HMAC-SHA256. It is required.
+	Secret []byte
+
+	// Repository is the full name (owner/name) of the tracked repository. The
+	// pushes to the other repositories are ignored. If empty, the pushes to
+	// any repository are accepted.
+	Repository string
+}
+
+// pushPayload defines the push webhook payload fields used. GitHub and Gitea
+// share them.
+type pushPayload struct {
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// Handler returns the http handler that validates the push webhooks signature
+// and requests an update from the updater for every push made to the tracked
+// repository. It responds with:
+//   - 202 if an update was requested.
+//   - 200 if the event was valid but ignored e.g. a ping or another repository.
+//   - 401 if the signature is missing or invalid.
+//   - 400 if the payload cannot be read.
+func Handler(cfg Config, updater Updater) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxPayloadSize))
+		if err != nil {
+			http.Error(w, "reading the payload failed", http.StatusBadRequest)
+			return
+		}
+
+		event, signature := eventHeaders(r)
+		if !validSignature(cfg.Secret, body, signature) {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		if event != pushEvent {
+			// Pings are sent when the webhook is created.
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		var payload pushPayload
+		if err = json.Unmarshal(body, &payload); err != nil {
+			http.Error(w, "invalid push payload", http.StatusBadRequest)
+			return
+		}
+
+		if cfg.Repository != "" &&
+			!strings.EqualFold(payload.Repository.FullName, cfg.Repository) {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		updater.RequestUpdate()
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// eventHeaders returns the event name and the hex encoded payload signature
+// sent by either GitHub or Gitea.
+func eventHeaders(r *http.Request) (event, signature string) {
+	if event = r.Header.Get(giteaEventHeader); event != "" {
+		return event, r.Header.Get(giteaSignatureHeader)
+	}
+
+	signature = r.Header.Get(githubSignatureHeader)
+	if !strings.HasPrefix(signature, githubSignaturePrefix) {
+		return r.Header.Get(githubEventHeader), ""
+	}
+
+	return r.Header.Get(githubEventHeader), strings.TrimPrefix(signature, githubSignaturePrefix)
+}
+
+// validSignature returns true if the hex encoded signature is the HMAC-SHA256
+// of the payload keyed with the secret. An empty secret never validates.
+func validSignature(secret, payload []byte, signature string) bool {
+	if len(secret) == 0 || signature == "" {
+		return false
+	}
+
+	sig, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	return hmac.Equal(sig, sum(secret, payload))
+}
+
+// Sign returns the hex encoded HMAC-SHA256 signature of the payload keyed with
+// the secret, as sent by Gitea. GitHub prefixes it with "sha256=".
+func Sign(secret, payload []byte) string {
+	return hex.EncodeToString(sum(secret, payload))
+}
+
+// sum returns the HMAC-SHA256 of the payload keyed with the secret.
+func sum(secret, payload []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
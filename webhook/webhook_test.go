@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testUpdater counts the updates requested.
+type testUpdater int
+
+func (u *testUpdater) RequestUpdate() { *u++ }
+
+// TestHandler tests the responses to the GitHub and Gitea webhooks.
+func TestHandler(t *testing.T) {
+	secret := []byte("s3cr3t")
+	push := `{"ref":"refs/heads/master","repository":{"full_name":"decred/politeia"}}`
+	otherPush := `{"ref":"refs/heads/master","repository":{"full_name":"decred/dcrd"}}`
+
+	headers := func(eventKey, event, signatureKey, signature string) http.Header {
+		h := make(http.Header)
+		h.Set(eventKey, event)
+		if signature != "" {
+			h.Set(signatureKey, signature)
+		}
+		return h
+	}
+
+	github := func(event, payload string) http.Header {
+		return headers(githubEventHeader, event, githubSignatureHeader,
+			githubSignaturePrefix+Sign(secret, []byte(payload)))
+	}
+
+	gitea := func(event, payload string) http.Header {
+		return headers(giteaEventHeader, event, giteaSignatureHeader,
+			Sign(secret, []byte(payload)))
+	}
+
+	td := []struct {
+		method   string
+		headers  http.Header
+		payload  string
+		code     int
+		requests int
+	}{
+		{http.MethodPost, github(pushEvent, push), push, http.StatusAccepted, 1},
+		{http.MethodPost, gitea(pushEvent, push), push, http.StatusAccepted, 1},
+		{http.MethodPost, github(pingEvent, "{}"), "{}", http.StatusOK, 0},
+		{http.MethodPost, github(pushEvent, otherPush), otherPush, http.StatusOK, 0},
+		{http.MethodPost, github(pushEvent, "{}"), push, http.StatusUnauthorized, 0},
+		{http.MethodPost, gitea(pushEvent, "{}"), push, http.StatusUnauthorized, 0},
+		{http.MethodPost, headers(githubEventHeader, pushEvent, "", ""), push,
+			http.StatusUnauthorized, 0},
+		{http.MethodPost, headers(githubEventHeader, pushEvent, githubSignatureHeader,
+			Sign(secret, []byte(push))), push, http.StatusUnauthorized, 0},
+		{http.MethodPost, github(pushEvent, "{"), "{", http.StatusBadRequest, 0},
+		{http.MethodGet, github(pushEvent, push), push, http.StatusMethodNotAllowed, 0},
+	}
+
+	for i, val := range td {
+		t.Run("Test_#"+strconv.Itoa(i), func(t *testing.T) {
+			updater := new(testUpdater)
+			handler := Handler(Config{Secret: secret, Repository: "Decred/Politeia"}, updater)
+
+			r := httptest.NewRequest(val.method, "/webhook", strings.NewReader(val.payload))
+			r.Header = val.headers
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+
+			if rec.Code != val.code {
+				t.Fatalf("expected the status code %d but found %d", val.code, rec.Code)
+			}
+
+			if int(*updater) != val.requests {
+				t.Fatalf("expected %d update requests but found %d", val.requests, *updater)
+			}
+		})
+	}
+}
+
+// TestEmptySecret tests that no webhook is accepted if the secret is not set.
+func TestEmptySecret(t *testing.T) {
+	payload := []byte(`{"repository":{"full_name":"decred/politeia"}}`)
+	if validSignature(nil, payload, Sign(nil, payload)) {
+		t.Fatalf("expected the signature to be invalid without a secret")
+	}
+}